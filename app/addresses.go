@@ -4,10 +4,42 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
 	"github.com/TrueBlocks/trueblocks-core/src/apps/chifra/pkg/base"
 	sdk "github.com/TrueBlocks/trueblocks-sdk/v5"
 )
 
+// AddressesSection is the key under which a project stores its address lists
+const AddressesSection = "addresses"
+
+// AddressList is a named group of addresses kept in a project
+type AddressList struct {
+	Name      string         `json:"name"`
+	Addresses []base.Address `json:"addresses"`
+}
+
+func init() {
+	project.RegisterSection(project.Section[[]AddressList]{
+		Key:      AddressesSection,
+		Validate: validateAddressLists,
+	})
+}
+
+func validateAddressLists(lists []AddressList) error {
+	for _, list := range lists {
+		if strings.TrimSpace(list.Name) == "" {
+			return validation.ValidationError{Field: "address list", Problem: "name cannot be empty"}
+		}
+		for _, addr := range list.Addresses {
+			if addr == base.ZeroAddr {
+				return validation.ValidationError{Field: "address list", Problem: "cannot contain the zero address"}
+			}
+		}
+	}
+	return nil
+}
+
 var ensLock sync.Mutex
 
 func (a *App) ConvertToAddress(addr string) (base.Address, bool) {
@@ -12,10 +12,15 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/automation"
+	_ "github.com/TrueBlocks/trueblocks-codegen/pkg/dalle" // registers the series project section
 	"github.com/TrueBlocks/trueblocks-codegen/pkg/fileserver"
 	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	_ "github.com/TrueBlocks/trueblocks-codegen/pkg/openai" // registers the images project section
 	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
 	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/secrets"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/tbconfig"
 	"github.com/TrueBlocks/trueblocks-core/src/apps/chifra/pkg/base"
 	"github.com/TrueBlocks/trueblocks-core/src/apps/chifra/pkg/config"
 	"github.com/TrueBlocks/trueblocks-core/src/apps/chifra/pkg/file"
@@ -27,18 +32,31 @@ import (
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
+// appPrefsDelay is how long changes to the app preferences are collected
+// before they are written
+const appPrefsDelay = time.Second
+
+// prefsReloadDelay is how long the preference files must be quiet after an
+// outside change before they are reloaded
+const prefsReloadDelay = 250 * time.Millisecond
+
 type App struct {
-	Assets      embed.FS
-	Preferences *preferences.Preferences
-	Projects    *project.Manager
-	ChainList   *utils.ChainList
-	Names       map[base.Address]types.Name
-	fileServer  *fileserver.FileServer
-	locked      int32
-	ctx         context.Context
-	apiKeys     map[string]string
-	ensMap      map[string]base.Address
-	renderCtxs  map[base.Address][]*output.RenderCtx
+	Assets       embed.FS
+	Preferences  *preferences.Preferences
+	Projects     *project.Manager
+	ChainList    *utils.ChainList
+	Names        map[base.Address]types.Name
+	tbConfig     string // The TrueBlocks config file RPC providers are written back to
+	fileServer   *fileserver.FileServer
+	automation   *automation.Server
+	appWriter    *preferences.AppWriter
+	prefsWatch   *preferences.Watcher
+	locked       int32
+	sessionSaved bool // Set by FileQuit once it has recorded the session for good
+	ctx          context.Context
+	keystore     *secrets.Store
+	ensMap       map[string]base.Address
+	renderCtxs   map[base.Address][]*output.RenderCtx
 }
 
 func NewApp(assets embed.FS) (*App, *menu.Menu) {
@@ -51,18 +69,17 @@ func NewApp(assets embed.FS) (*App, *menu.Menu) {
 			App:  preferences.AppPreferences{},
 		},
 		Assets:     assets,
-		apiKeys:    make(map[string]string),
 		renderCtxs: make(map[base.Address][]*output.RenderCtx),
 		ensMap:     make(map[string]base.Address),
 	}
 
 	app.ChainList, _ = utils.UpdateChainList(config.PathToRootConfig())
+	app.tbConfig = tbconfig.Path(config.PathToRootConfig())
 
+	// Keys in a .env file are moved into the keystore on startup
 	if file.FileExists(".env") {
 		if err := godotenv.Load(); err != nil {
-			log.Fatal("Error loading .env file")
-		} else if app.apiKeys["openAi"] = os.Getenv("OPENAI_API_KEY"); app.apiKeys["openAi"] == "" {
-			log.Fatal("No OPENAI_API_KEY key found")
+			log.Printf("Error loading .env file: %v", err)
 		}
 	}
 
@@ -72,7 +89,7 @@ func NewApp(assets embed.FS) (*App, *menu.Menu) {
 
 func (a App) String() string {
 	bytes, _ := json.MarshalIndent(a, "", "  ")
-	return string(bytes)
+	return secrets.Redact(string(bytes))
 }
 
 func (a *App) GetContext() context.Context {
@@ -84,50 +101,79 @@ func (a *App) Startup(ctx context.Context) {
 
 	msgs.InitializeContext(ctx)
 
-	org, err := preferences.GetOrgPreferences()
-	if err != nil {
-		msgs.EmitError("Loading org preferences failed", err)
+	// Before the preferences, whose RPC credentials move into it
+	a.openKeystore()
+	if err := a.loadPreferences(); err != nil {
 		return
 	}
+	a.appWriter = preferences.NewAppWriter(appPrefsDelay, func(err error) {
+		msgs.EmitError("Saving app preferences failed", err)
+	})
+	a.applyBackupCount()
 
-	user, err := preferences.GetUserPreferences()
-	if err != nil {
-		msgs.EmitError("Loading user preferences failed", err)
-		return
+	a.fileServer = fileserver.NewFileServer()
+	if err := a.fileServer.Start(); err != nil {
+		msgs.EmitError("Failed to start file server", err)
+	}
+	go a.watchImagesDir()
+
+	a.Projects.StartAutosave(getRecoveryFolder(), autosaveInterval)
+	a.Projects.SetMemoryLimit(a.maxResidentProjects(), getSpillFolder())
+	if err := a.Projects.StartWatching(); err != nil {
+		msgs.EmitError("Failed to watch project files", err)
+	}
+	a.watchPreferences()
+
+	a.restoreSession()
+	a.offerRecovery()
+	a.startAutomation()
+}
+
+// loadPreferences reads the org, app and user preferences at startup. A broken
+// org policy must not leave the app half started, nor unlock anything, so the
+// org and user preferences then start from the defaults, and saving them
+// keeps failing until the policy is fixed.
+func (a *App) loadPreferences() error {
+	_, policyErr := preferences.LoadPolicy()
+	if policyErr != nil {
+		msgs.EmitWarning("Org policy", fmt.Sprintf("using the default preferences until the policy is fixed: %v", policyErr))
+	}
+
+	org, err := preferences.GetOrgPreferences()
+	if err != nil && policyErr != nil {
+		org = *preferences.NewOrgPreferences()
+	} else if err != nil {
+		msgs.EmitError("Loading org preferences failed", err)
+		return err
 	}
 
 	appPrefs, err := preferences.GetAppPreferences()
 	if err != nil {
 		msgs.EmitError("Loading app preferences failed", err)
-		return
+		return err
 	}
+	a.startInLastProfile(&appPrefs)
 
-	a.Preferences.Org = org
-	a.Preferences.User = user
-	a.Preferences.App = appPrefs
-
-	a.fileServer = fileserver.NewFileServer()
-	if err := a.fileServer.Start(); err != nil {
-		msgs.EmitError("Failed to start file server", err)
+	user, err := preferences.GetUserPreferences()
+	if err != nil && policyErr != nil {
+		user = *preferences.NewUserPreferences()
+	} else if err != nil {
+		msgs.EmitError("Loading user preferences failed", err)
+		return err
 	}
-	go a.watchImagesDir()
 
-	if len(a.Preferences.App.RecentProjects) > 0 {
-		mostRecentPath := a.Preferences.App.RecentProjects[0]
-		if file.FileExists(mostRecentPath) {
-			_, err := a.Projects.Open(mostRecentPath)
-			if err != nil {
-				msgs.EmitError("Failed to open recent project", err)
-			}
-		}
-	}
+	a.Preferences.SetOrg(org)
+	a.Preferences.SetUser(user)
+	a.Preferences.SetApp(appPrefs)
+	return nil
 }
 
 func (a *App) DomReady(ctx context.Context) {
 	a.ctx = ctx
 	if a.IsReady() {
-		runtime.WindowSetSize(ctx, a.Preferences.App.Bounds.Width, a.Preferences.App.Bounds.Height)
-		runtime.WindowSetPosition(ctx, a.Preferences.App.Bounds.X, a.Preferences.App.Bounds.Y)
+		bounds := a.Preferences.GetApp().Bounds
+		runtime.WindowSetSize(ctx, bounds.Width, bounds.Height)
+		runtime.WindowSetPosition(ctx, bounds.X, bounds.Y)
 		runtime.WindowShow(ctx)
 		go a.watchWindowBounds() // if the window moves or resizes, we want to know
 	}
@@ -137,6 +183,25 @@ func (a *App) BeforeClose(ctx context.Context) bool {
 	x, y := runtime.WindowGetPosition(ctx)
 	w, h := runtime.WindowGetSize(ctx)
 	a.SaveBounds(x, y, w, h)
+	a.shutdown()
+	return false // allow window to close
+}
+
+// shutdown records the session, unless FileQuit already has, and stops
+// everything Startup started
+func (a *App) shutdown() {
+	if !a.sessionSaved {
+		a.saveSession()
+	}
+
+	// Anything still unsaved can be recovered on the next launch
+	if err := a.Projects.Autosave(); err != nil {
+		log.Printf("Error autosaving projects: %v", err)
+	}
+	a.Projects.StopAutosave()
+	a.Projects.StopWatching()
+	a.stopWatchingPreferences()
+	a.stopAutomation()
 
 	if a.fileServer != nil {
 		if err := a.fileServer.Stop(); err != nil {
@@ -144,7 +209,13 @@ func (a *App) BeforeClose(ctx context.Context) bool {
 		}
 	}
 
-	return false // allow window to close
+	// Anything queued above is written before the window goes away
+	if a.appWriter != nil {
+		if err := a.appWriter.Close(); err != nil {
+			log.Printf("Error saving app preferences: %v", err)
+		}
+		a.appWriter = nil
+	}
 }
 
 func (a *App) watchWindowBounds() {
@@ -170,14 +241,14 @@ func (a *App) SaveBounds(x, y, w, h int) {
 		return
 	}
 
-	a.Preferences.App.Bounds = preferences.Bounds{
-		X:      x,
-		Y:      y,
-		Width:  w,
-		Height: h,
-	}
-
-	_ = preferences.SetAppPreferences(&a.Preferences.App)
+	_ = a.updateAppPreferences(func(app *preferences.AppPreferences) {
+		app.Bounds = preferences.Bounds{
+			X:      x,
+			Y:      y,
+			Width:  w,
+			Height: h,
+		}
+	})
 }
 
 func (a *App) IsReady() bool {
@@ -206,37 +277,64 @@ func (a *App) SetInitialized(isInit bool) error {
 }
 
 func (a *App) SetAppPreferences(appPrefs *preferences.AppPreferences) error {
-	a.Preferences.App = *appPrefs
-	return preferences.SetAppPreferences(appPrefs)
+	return a.updateAppPreferences(func(app *preferences.AppPreferences) {
+		*app = *appPrefs
+	})
+}
+
+// saveAppPreferences queues the app preferences to be written
+func (a *App) saveAppPreferences() error {
+	return a.updateAppPreferences(func(*preferences.AppPreferences) {})
+}
+
+// updateAppPreferences changes the app preferences with fn under their lock
+// and queues them to be written, so the last change is always the last one
+// queued. Without a writer, before startup or after closing, they are written
+// right away.
+func (a *App) updateAppPreferences(fn func(app *preferences.AppPreferences)) error {
+	var err error
+	a.Preferences.UpdateApp(func(app *preferences.AppPreferences) {
+		fn(app)
+		if a.appWriter == nil {
+			err = preferences.SetAppPreferences(app)
+		} else {
+			err = a.appWriter.Queue(app)
+		}
+	})
+	return err
 }
 
 func (a *App) GetAppPreferences() *preferences.AppPreferences {
-	return &a.Preferences.App
+	app := a.Preferences.GetApp()
+	return &app
 }
 
 func (a *App) SetMenuCollapsed(collapse bool) {
-	a.Preferences.App.MenuCollapsed = collapse
-	_ = preferences.SetAppPreferences(&a.Preferences.App)
+	_ = a.updateAppPreferences(func(app *preferences.AppPreferences) {
+		app.MenuCollapsed = collapse
+	})
 }
 
 func (a *App) SetHelpCollapsed(collapse bool) {
-	a.Preferences.App.HelpCollapsed = collapse
-	_ = preferences.SetAppPreferences(&a.Preferences.App)
+	_ = a.updateAppPreferences(func(app *preferences.AppPreferences) {
+		app.HelpCollapsed = collapse
+	})
 }
 
 func (a *App) SetLastView(view string) {
-	a.Preferences.App.LastView = view
-	if view != "/wizard" {
-		a.Preferences.App.LastViewNoWizard = view
-	}
-	_ = preferences.SetAppPreferences(&a.Preferences.App)
+	_ = a.updateAppPreferences(func(app *preferences.AppPreferences) {
+		app.LastView = view
+		if view != "/wizard" {
+			app.LastViewNoWizard = view
+		}
+	})
 }
 
 func (a *App) GetWizardReturn() string {
-	if a.Preferences.App.LastViewNoWizard == "" {
-		return "/"
+	if view := a.Preferences.GetApp().LastViewNoWizard; view != "" {
+		return view
 	}
-	return a.Preferences.App.LastViewNoWizard
+	return "/"
 }
 
 func (a *App) GetAppId() preferences.Id {
@@ -249,29 +347,32 @@ func (a *App) SetLastTab(route string, tab string) {
 	}
 	defer atomic.StoreInt32(&a.locked, 0)
 
-	a.Preferences.App.LastTab[route] = tab
-	_ = preferences.SetAppPreferences(&a.Preferences.App)
+	_ = a.updateAppPreferences(func(app *preferences.AppPreferences) {
+		if app.LastTab == nil {
+			app.LastTab = make(map[string]string)
+		}
+		app.LastTab[route] = tab
+	})
 }
 
 func (a *App) GetLastTab(route string) string {
-	return a.Preferences.App.LastTab[route]
+	return a.Preferences.GetApp().LastTab[route]
 }
 
 func (a *App) GetOpenProjects() []map[string]interface{} {
-	projectIDs := a.Projects.GetOpenProjectIDs()
-	result := make([]map[string]interface{}, 0, len(projectIDs))
+	projects, activeID := a.Projects.Snapshot()
+	result := make([]map[string]interface{}, 0, len(projects))
 
-	for _, id := range projectIDs {
-		project := a.Projects.GetProjectByID(id)
-		if project == nil {
+	for _, id := range a.Projects.GetOpenProjectIDs() {
+		project, exists := projects[id]
+		if !exists {
 			continue
 		}
-
 		projectInfo := map[string]interface{}{
 			"id":         id,
 			"name":       project.GetName(),
 			"path":       project.GetPath(),
-			"isActive":   id == a.Projects.ActiveID,
+			"isActive":   id == activeID,
 			"isDirty":    project.IsDirty(),
 			"lastOpened": project.LastOpened,
 			// "createdAt":  project.CreatedAt,
@@ -288,21 +389,38 @@ func (a *App) Logger(msg string) {
 }
 
 func (a *App) GetUserPreferences() *preferences.UserPreferences {
-	return &a.Preferences.User
+	user := a.Preferences.GetUser()
+	return &user
 }
 
+// SetUserPreferences saves the user preferences unless the org policy rejects them
 func (a *App) SetUserPreferences(userPrefs *preferences.UserPreferences) error {
-	a.Preferences.User = *userPrefs
-	return preferences.SetUserPreferences(userPrefs)
+	if err := preferences.SetUserPreferences(userPrefs); err != nil {
+		return err
+	}
+	a.Preferences.SetUser(*userPrefs)
+	return nil
 }
 
 func (a *App) GetOrgPreferences() *preferences.OrgPreferences {
-	return &a.Preferences.Org
+	org := a.Preferences.GetOrg()
+	return &org
 }
 
+// SetOrgPreferences saves the org preferences unless the org policy rejects them
 func (a *App) SetOrgPreferences(orgPrefs *preferences.OrgPreferences) error {
-	a.Preferences.Org = *orgPrefs
-	return preferences.SetOrgPreferences(orgPrefs)
+	if err := preferences.SetOrgPreferences(orgPrefs); err != nil {
+		return err
+	}
+	a.Preferences.SetOrg(*orgPrefs)
+	a.applyBackupCount()
+	return nil
+}
+
+// GetPolicy returns the org policy, so settings screens can show which
+// preferences are locked
+func (a *App) GetPolicy() (*preferences.Policy, error) {
+	return preferences.LoadPolicy()
 }
 
 func (app *App) GetChainList() *utils.ChainList {
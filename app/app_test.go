@@ -2,8 +2,12 @@ package app
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
 	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
 )
 
@@ -43,3 +47,34 @@ func TestSaveBounds_SavesToPreferences(t *testing.T) {
 			loaded.Bounds.X, loaded.Bounds.Y, loaded.Bounds.Width, loaded.Bounds.Height)
 	}
 }
+
+func TestLoadPreferencesSurvivesBrokenPolicy(t *testing.T) {
+	defer preferences.SetConfigBaseForTest(t, t.TempDir())()
+	policy := filepath.Join(t.TempDir(), "policy.json")
+	t.Setenv(preferences.EnvName("policy"), policy)
+	if err := os.WriteFile(policy, []byte(`{"locked": {"theme": 7}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var warnings []string
+	msgs.SetEmitter(func(messageType msgs.EventType, msgText string) {
+		warnings = append(warnings, msgText)
+	})
+	defer msgs.SetEmitter(nil)
+
+	app := &App{Preferences: &preferences.Preferences{}}
+	if err := app.loadPreferences(); err != nil {
+		t.Fatalf("Expected startup to go on without the policy, got %v", err)
+	}
+	if app.Preferences.User.Theme != preferences.NewUserPreferences().Theme {
+		t.Errorf("Expected the default user preferences, got %+v", app.Preferences.User)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "policy") {
+		t.Errorf("Expected a warning about the policy, got %v", warnings)
+	}
+
+	// The defaults never replace what is on disk while the policy is broken
+	if err := app.SetUserPreferences(&app.Preferences.User); err == nil {
+		t.Error("Expected saving to fail until the policy is fixed")
+	}
+}
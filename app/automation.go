@@ -0,0 +1,260 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/automation"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/openai"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+	sdk "github.com/TrueBlocks/trueblocks-sdk/v5"
+)
+
+// AutomationInfo tells local tools where the automation server listens and
+// which token to send. It is written to the app folder while the server runs.
+type AutomationInfo struct {
+	URL   string `json:"url"`
+	Token string `json:"token"`
+}
+
+func getAutomationInfoPath() string {
+	_, appFolder := preferences.GetConfigFolders()
+	return filepath.Join(appFolder, "automation.json")
+}
+
+// startAutomation starts the automation server if the app preferences enable it
+func (a *App) startAutomation() {
+	appPrefs := a.Preferences.GetApp()
+	if !appPrefs.Automation || a.automation != nil {
+		return
+	}
+
+	token, err := automation.NewToken()
+	if err != nil {
+		msgs.EmitError("Failed to start automation server", err)
+		return
+	}
+
+	server := automation.NewServer(token)
+	a.registerAutomation(server)
+	if err := server.Start(appPrefs.AutomationPort); err != nil {
+		msgs.EmitError("Failed to start automation server", err)
+		return
+	}
+	a.automation = server
+
+	// Only the user may read the token
+	info, _ := json.MarshalIndent(AutomationInfo{URL: server.URL(), Token: token}, "", "  ")
+	path := getAutomationInfoPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+		err = os.WriteFile(path, info, 0600)
+	}
+	if err != nil {
+		msgs.EmitError("Failed to publish automation server", err)
+	}
+}
+
+func (a *App) stopAutomation() {
+	if a.automation == nil {
+		return
+	}
+	if err := a.automation.Stop(); err != nil {
+		msgs.EmitError("Failed to stop automation server", err)
+	}
+	a.automation = nil
+	_ = os.Remove(getAutomationInfoPath())
+}
+
+// SetAutomationEnabled turns the local automation server on or off and
+// remembers the choice
+func (a *App) SetAutomationEnabled(enabled bool) error {
+	if err := a.updateAppPreferences(func(app *preferences.AppPreferences) {
+		app.Automation = enabled
+	}); err != nil {
+		return err
+	}
+	if enabled {
+		a.startAutomation()
+	} else {
+		a.stopAutomation()
+	}
+	return nil
+}
+
+// GetAutomationURL returns the automation server's URL, or "" if it is not running
+func (a *App) GetAutomationURL() string {
+	if a.automation == nil {
+		return ""
+	}
+	return a.automation.URL()
+}
+
+type idParams struct {
+	ID string `json:"id"`
+}
+
+type pathParams struct {
+	Path      string `json:"path"`
+	Overwrite bool   `json:"overwrite"`
+}
+
+type prefsParams struct {
+	Which string          `json:"which"` // app, user or org
+	Value json.RawMessage `json:"value"`
+}
+
+type imageParams struct {
+	Series  string `json:"series"`
+	File    string `json:"file"`
+	Prompt  string `json:"prompt"`
+	Caption string `json:"caption"`
+	Enhance bool   `json:"enhance"`
+}
+
+// registerAutomation exposes the app's project, preferences, names and image
+// operations on server. Operations that would show a dialog in the app fail
+// instead, so a tool is never left waiting on the user.
+func (a *App) registerAutomation(server *automation.Server) {
+	server.Register("project.list", automation.Method(func(struct{}) (interface{}, error) {
+		return a.GetOpenProjects(), nil
+	}))
+	server.Register("project.new", automation.Method(func(params struct {
+		Template string `json:"template"`
+	}) (interface{}, error) {
+		var err error
+		if params.Template == "" {
+			err = a.fileNew()
+		} else {
+			err = a.fileNewFromTemplate(params.Template)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return a.Projects.Active().GetID(), nil
+	}))
+	server.Register("project.open", automation.Method(func(params pathParams) (interface{}, error) {
+		if err := a.fileOpen(params.Path); err != nil {
+			return nil, err
+		}
+		return a.Projects.Active().GetID(), nil
+	}))
+	server.Register("project.save", automation.Method(func(struct{}) (interface{}, error) {
+		return nil, a.fileSave()
+	}))
+	server.Register("project.saveAs", automation.Method(func(params pathParams) (interface{}, error) {
+		return nil, a.fileSaveAs(params.Path, params.Overwrite)
+	}))
+	server.Register("project.export", automation.Method(func(params pathParams) (interface{}, error) {
+		return a.fileExportBundle(params.Path)
+	}))
+	server.Register("project.switch", automation.Method(func(params idParams) (interface{}, error) {
+		return nil, a.SwitchToProject(params.ID)
+	}))
+	server.Register("project.close", automation.Method(func(params struct {
+		ID      string `json:"id"`
+		Discard bool   `json:"discard"`
+	}) (interface{}, error) {
+		project := a.Projects.GetProjectByID(params.ID)
+		if project == nil {
+			return nil, fmt.Errorf("%w: no project with ID %s exists", automation.ErrInvalidParams, params.ID)
+		}
+		if project.IsDirty() && !params.Discard {
+			return nil, ErrUnsavedChanges
+		}
+		if err := a.Projects.Close(params.ID); err != nil {
+			return nil, err
+		}
+		a.saveSession()
+		return nil, nil
+	}))
+
+	server.Register("preferences.get", automation.Method(func(params prefsParams) (interface{}, error) {
+		switch params.Which {
+		case "app":
+			return a.GetAppPreferences(), nil
+		case "user":
+			return a.GetUserPreferences(), nil
+		case "org":
+			return a.GetOrgPreferences(), nil
+		}
+		return nil, fmt.Errorf("%w: unknown preferences %q", automation.ErrInvalidParams, params.Which)
+	}))
+	server.Register("preferences.set", automation.Method(func(params prefsParams) (interface{}, error) {
+		switch params.Which {
+		case "app":
+			var prefs preferences.AppPreferences
+			if err := json.Unmarshal(params.Value, &prefs); err != nil {
+				return nil, fmt.Errorf("%w: %v", automation.ErrInvalidParams, err)
+			}
+			return nil, a.SetAppPreferences(&prefs)
+		case "user":
+			var prefs preferences.UserPreferences
+			if err := json.Unmarshal(params.Value, &prefs); err != nil {
+				return nil, fmt.Errorf("%w: %v", automation.ErrInvalidParams, err)
+			}
+			return nil, a.SetUserPreferences(&prefs)
+		case "org":
+			var prefs preferences.OrgPreferences
+			if err := json.Unmarshal(params.Value, &prefs); err != nil {
+				return nil, fmt.Errorf("%w: %v", automation.ErrInvalidParams, err)
+			}
+			return nil, a.SetOrgPreferences(&prefs)
+		}
+		return nil, fmt.Errorf("%w: unknown preferences %q", automation.ErrInvalidParams, params.Which)
+	}))
+
+	server.Register("names.search", automation.Method(func(params struct {
+		Terms []string `json:"terms"`
+	}) (interface{}, error) {
+		if len(params.Terms) == 0 {
+			return nil, fmt.Errorf("%w: expected at least one term", automation.ErrInvalidParams)
+		}
+		opts := sdk.NamesOptions{
+			Terms: params.Terms,
+		}
+		names, _, err := opts.Names()
+		return names, err
+	}))
+
+	server.Register("images.generate", automation.Method(func(params imageParams) (interface{}, error) {
+		if params.Series == "" || params.File == "" || strings.TrimSpace(params.Prompt) == "" {
+			return nil, fmt.Errorf("%w: expected series, file and prompt", automation.ErrInvalidParams)
+		}
+		imageData := openai.ImageData{
+			EnhancedPrompt: params.Prompt,
+			TersePrompt:    params.Prompt,
+			SeriesName:     params.Series,
+			Filename:       params.File,
+		}
+		// The names become paths under the output folder
+		if err := imageData.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", automation.ErrInvalidParams, err)
+		}
+		if params.Caption != "" {
+			imageData.TersePrompt = params.Caption
+		}
+		if params.Enhance {
+			enhanced, err := openai.EnhancePrompt(params.Prompt, "")
+			if err != nil {
+				return nil, err
+			}
+			imageData.EnhancedPrompt = enhanced
+		}
+		if err := openai.RequestImage(&imageData); err != nil {
+			return nil, err
+		}
+		if active := a.Projects.Active(); active != nil {
+			if err := openai.AddImage(active, &imageData); err != nil {
+				return nil, err
+			}
+		}
+		return filepath.Join("output", params.Series, "annotated", params.File+".png"), nil
+	}))
+	server.Register("images.url", automation.Method(func(params pathParams) (interface{}, error) {
+		return a.GetImageURL(params.Path), nil
+	}))
+}
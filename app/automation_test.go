@@ -0,0 +1,164 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/automation"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+)
+
+func callAutomation(t *testing.T, url, method, params string) (json.RawMessage, int) {
+	t.Helper()
+	body := `{"jsonrpc": "2.0", "id": 1, "method": "` + method + `", "params": ` + params + `}`
+	req, _ := http.NewRequest(http.MethodPost, url+"/rpc", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.Error != nil {
+		return nil, result.Error.Code
+	}
+	return result.Result, 0
+}
+
+func TestAutomation(t *testing.T) {
+	app, dir, filePath := getTestApp(t, false)
+	defer preferences.SetConfigBaseForTest(t, dir)()
+
+	server := automation.NewServer("token")
+	app.registerAutomation(server)
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	if _, code := callAutomation(t, httpServer.URL, "project.new", `{}`); code != 0 {
+		t.Fatalf("project.new failed with %d", code)
+	}
+	result, code := callAutomation(t, httpServer.URL, "project.list", `{}`)
+	var projects []map[string]interface{}
+	if code != 0 || json.Unmarshal(result, &projects) != nil || len(projects) != 2 {
+		t.Errorf("Expected two open projects, got %s (%d)", result, code)
+	}
+
+	// A dirty project is not closed without saying so, since no one can answer a dialog
+	active := app.Projects.Active()
+	active.SetDirty(true)
+	if _, code := callAutomation(t, httpServer.URL, "project.close", `{"id": "`+active.GetID()+`"}`); code != automation.CodeServerError {
+		t.Errorf("Expected closing a dirty project to fail, got %d", code)
+	}
+	if _, code := callAutomation(t, httpServer.URL, "project.close", `{"id": "`+active.GetID()+`", "discard": true}`); code != 0 {
+		t.Errorf("Expected closing with discard to succeed, got %d", code)
+	}
+
+	if _, code := callAutomation(t, httpServer.URL, "project.open", `{"path": "`+filePath+`"}`); code != 0 {
+		t.Errorf("project.open failed with %d", code)
+	}
+	if _, code := callAutomation(t, httpServer.URL, "project.open", `{"path": "missing.json"}`); code == 0 {
+		t.Error("Expected opening a missing file to fail")
+	}
+
+	if _, code := callAutomation(t, httpServer.URL, "preferences.set", `{"which": "user", "value": {"theme": "light"}}`); code != 0 {
+		t.Fatalf("preferences.set failed with %d", code)
+	}
+	result, _ = callAutomation(t, httpServer.URL, "preferences.get", `{"which": "user"}`)
+	if !strings.Contains(string(result), `"theme":"light"`) {
+		t.Errorf("Expected the new theme, got %s", result)
+	}
+	if _, code := callAutomation(t, httpServer.URL, "preferences.get", `{"which": "team"}`); code != automation.CodeInvalidParams {
+		t.Errorf("Expected unknown preferences to be invalid params, got %d", code)
+	}
+
+	// Names that would write outside the output folder are refused before any request
+	for _, params := range []string{
+		`{"series": "../../etc", "file": "x", "prompt": "p"}`,
+		`{"series": "demo", "file": "../escape", "prompt": "p"}`,
+		`{"series": "demo/sub", "file": "x", "prompt": "p"}`,
+		`{"series": "demo", "file": "..", "prompt": "p"}`,
+	} {
+		if _, code := callAutomation(t, httpServer.URL, "images.generate", params); code != automation.CodeInvalidParams {
+			t.Errorf("Expected %s to be invalid params, got %d", params, code)
+		}
+	}
+}
+
+// The automation server sets the app preferences from its own goroutines
+// while the bindings set them from the UI's. Run with -race.
+func TestAutomationPreferencesWhileBindings(t *testing.T) {
+	app, dir, _ := getTestApp(t, false)
+	defer preferences.SetConfigBaseForTest(t, dir)()
+
+	server := automation.NewServer("token")
+	app.registerAutomation(server)
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			callAutomation(t, httpServer.URL, "preferences.set", `{"which": "app", "value": {"lastView": "/names"}}`)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			app.SetLastTab("/names", "list")
+			app.SetMenuCollapsed(i%2 == 0)
+			_ = app.GetAppPreferences()
+		}
+	}()
+	wg.Wait()
+
+	if view := app.GetAppPreferences().LastView; view != "/names" {
+		t.Errorf("Expected the view set through automation, got %q", view)
+	}
+}
+
+func TestAutomationOptIn(t *testing.T) {
+	app, dir, _ := getTestApp(t, false)
+	defer preferences.SetConfigBaseForTest(t, dir)()
+
+	app.startAutomation()
+	if app.GetAutomationURL() != "" {
+		t.Fatal("The automation server should not start unless enabled")
+	}
+
+	if err := app.SetAutomationEnabled(true); err != nil {
+		t.Fatalf("Enabling automation failed: %v", err)
+	}
+	defer app.stopAutomation()
+
+	data, err := os.ReadFile(getAutomationInfoPath())
+	if err != nil {
+		t.Fatalf("Expected the server's details to be published: %v", err)
+	}
+	var info AutomationInfo
+	if err := json.Unmarshal(data, &info); err != nil || info.URL != app.GetAutomationURL() || info.Token == "" {
+		t.Errorf("Expected the URL and token, got %s", data)
+	}
+
+	if err := app.SetAutomationEnabled(false); err != nil {
+		t.Fatalf("Disabling automation failed: %v", err)
+	}
+	if _, err := os.Stat(getAutomationInfoPath()); !os.IsNotExist(err) {
+		t.Error("The server's details should be removed when it stops")
+	}
+}
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/secrets"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/tbconfig"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
+)
+
+// configChains returns the chains in the TrueBlocks config file. A machine
+// without chifra has no config file and so no chains.
+func (a *App) configChains() ([]preferences.Chain, error) {
+	if a.tbConfig == "" {
+		return nil, nil
+	}
+	chains, err := tbconfig.ReadChains(a.tbConfig)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	converted := make([]preferences.Chain, len(chains))
+	for i, chain := range chains {
+		converted[i] = preferences.Chain(chain)
+	}
+	return converted, nil
+}
+
+// chainListChains returns the chains in chifra's copy of the public chain
+// list. The list has no TrueBlocks names, so each chain is named after its
+// short name. Its RPC providers are public endpoints, often needing a key,
+// and are left out.
+func (a *App) chainListChains() []preferences.Chain {
+	if a.ChainList == nil {
+		return nil
+	}
+	chains := make([]preferences.Chain, 0, len(a.ChainList.Chains))
+	for _, item := range a.ChainList.Chains {
+		chain := preferences.Chain{
+			Chain:   strings.ToLower(item.ShortName),
+			ChainId: uint64(item.ChainID),
+			Symbol:  item.NativeCurrency.Symbol,
+		}
+		if len(item.Explorers) > 0 {
+			chain.RemoteExplorer = item.Explorers[0].URL
+		}
+		chains = append(chains, chain)
+	}
+	return chains
+}
+
+// GetChainOptions returns the chains the wizard and settings offer, with
+// their name, chain ID, symbol and explorer filled in. Chains in the
+// TrueBlocks config keep its names and RPC providers. Other chains come from
+// the public chain list.
+func (a *App) GetChainOptions() ([]preferences.Chain, error) {
+	configured, err := a.configChains()
+	if err != nil {
+		return nil, err
+	}
+
+	options := make(map[uint64]preferences.Chain)
+	for _, chain := range a.chainListChains() {
+		options[chain.ChainId] = chain
+	}
+	for _, chain := range configured {
+		listed := options[chain.ChainId]
+		listed.Chain = ""
+		options[chain.ChainId] = chain.Prefill(listed)
+	}
+
+	sorted := make([]preferences.Chain, 0, len(options))
+	for _, chain := range options {
+		sorted = append(sorted, chain)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ChainId < sorted[j].ChainId
+	})
+	return sorted, nil
+}
+
+// GetChainConflicts returns where the user's chains disagree with the
+// TrueBlocks config, with the public chain list, or with each other
+func (a *App) GetChainConflicts() ([]preferences.ChainConflict, error) {
+	configured, err := a.configChains()
+	if err != nil {
+		return nil, err
+	}
+
+	// Names in the chain list are not TrueBlocks names, so only the config's count
+	listed := a.chainListChains()
+	for i := range listed {
+		listed[i].Chain = ""
+	}
+
+	user := a.Preferences.GetUser().Chains
+	conflicts := preferences.DuplicateChains(user)
+	conflicts = append(conflicts, preferences.ChainConflicts(user, preferences.ChainSourceConfig, configured)...)
+	conflicts = append(conflicts, preferences.ChainConflicts(user, preferences.ChainSourceChainList, listed)...)
+	return conflicts, nil
+}
+
+// prefillChain fills a chain's missing name, symbol and explorer from the
+// chain options with the same chain ID
+func (a *App) prefillChain(ch preferences.Chain) preferences.Chain {
+	options, err := a.GetChainOptions()
+	if err != nil {
+		return ch
+	}
+	for _, option := range options {
+		if option.ChainId == ch.ChainId {
+			return ch.Prefill(option)
+		}
+	}
+	return ch
+}
+
+// SaveChainToConfig writes the RPC providers of one of the user's chains to
+// the TrueBlocks config file, so chifra uses them too
+func (a *App) SaveChainToConfig(chainId uint64) error {
+	if a.tbConfig == "" {
+		return fmt.Errorf("no TrueBlocks config: %w", os.ErrNotExist)
+	}
+
+	configured, err := a.configChains()
+	if err != nil {
+		return err
+	}
+
+	for _, chain := range a.Preferences.GetUser().Chains {
+		if chain.ChainId != chainId {
+			continue
+		}
+		if len(chain.RpcProviders) == 0 {
+			return validation.ValidationError{Field: "rpcProviders", Problem: "cannot be empty"}
+		}
+		// chifra knows the chain by the name in its config, whatever the user calls it
+		for _, known := range configured {
+			if known.ChainId == chainId {
+				chain.Chain = known.Chain
+			}
+		}
+		// chifra cannot read the keystore, so it gets the credentials themselves
+		resolved := make([]string, len(chain.RpcProviders))
+		for i, rpc := range chain.RpcProviders {
+			resolved[i] = secrets.ResolveURL(rpc)
+		}
+		chain.RpcProviders = resolved
+		if err := tbconfig.SetRpcProviders(a.tbConfig, tbconfig.Chain(chain)); err != nil {
+			return err
+		}
+		msgs.EmitStatus(fmt.Sprintf("Saved the RPC providers for %s to %s", chain.Chain, tbconfig.FileName))
+		return nil
+	}
+	return validation.ValidationError{Field: "chainId", Problem: fmt.Sprintf("%d is not one of your chains", chainId)}
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/tbconfig"
+	"github.com/TrueBlocks/trueblocks-core/src/apps/chifra/pkg/utils"
+)
+
+func newChainsTestApp(t *testing.T) *App {
+	t.Helper()
+	t.Setenv(preferences.EnvName("policy"), filepath.Join(t.TempDir(), "policy.json"))
+
+	path := filepath.Join(t.TempDir(), tbconfig.FileName)
+	config := "[chains.mainnet]\n  chain = \"mainnet\"\n  chainId = \"1\"\n  rpcProviders = [\"http://localhost:8545\"]\n  symbol = \"ETH\"\n"
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	list := &utils.ChainList{Chains: []utils.ChainListItem{
+		{ShortName: "gno", ChainID: 100, NativeCurrency: utils.NativeCurrency{Symbol: "XDAI"}, Explorers: []utils.Explorer{{URL: "https://gnosisscan.io"}}},
+		{ShortName: "eth", ChainID: 1, NativeCurrency: utils.NativeCurrency{Symbol: "ETH"}, Explorers: []utils.Explorer{{URL: "https://etherscan.io"}}},
+	}}
+	return &App{Preferences: &preferences.Preferences{}, ChainList: list, tbConfig: path}
+}
+
+func TestGetChainOptions(t *testing.T) {
+	app := newChainsTestApp(t)
+
+	options, err := app.GetChainOptions()
+	if err != nil {
+		t.Fatalf("GetChainOptions failed: %v", err)
+	}
+	expected := []preferences.Chain{
+		{Chain: "mainnet", ChainId: 1, RemoteExplorer: "https://etherscan.io", RpcProviders: []string{"http://localhost:8545"}, Symbol: "ETH"},
+		{Chain: "gno", ChainId: 100, RemoteExplorer: "https://gnosisscan.io", Symbol: "XDAI"},
+	}
+	if !reflect.DeepEqual(options, expected) {
+		t.Errorf("Unexpected options\n got: %+v\nwant: %+v", options, expected)
+	}
+
+	// Without chifra there is only the chain list
+	app.tbConfig = filepath.Join(t.TempDir(), tbconfig.FileName)
+	if options, err := app.GetChainOptions(); err != nil || len(options) != 2 || options[0].Chain != "eth" {
+		t.Errorf("Expected the chain list alone, got %+v and %v", options, err)
+	}
+}
+
+func TestChainConflictsAndSaveToConfig(t *testing.T) {
+	dir := t.TempDir()
+	defer preferences.SetConfigBaseForTest(t, dir)()
+	app := newChainsTestApp(t)
+
+	if err := app.SetChain(preferences.Chain{ChainId: 100, RpcProviders: []string{"https://gnosis.example.com"}}); err != nil {
+		t.Fatalf("SetChain failed: %v", err)
+	}
+	if err := app.SetChain(preferences.Chain{Chain: "ethereum", ChainId: 1, Symbol: "ETH", RpcProviders: []string{"https://rpc.example.com"}}); err != nil {
+		t.Fatalf("SetChain failed: %v", err)
+	}
+	gnosis := app.Preferences.User.Chains[1]
+	if gnosis.Chain != "gno" || gnosis.Symbol != "XDAI" || gnosis.RemoteExplorer != "https://gnosisscan.io" {
+		t.Errorf("Expected the chain to be filled in from the chain list, got %+v", gnosis)
+	}
+
+	conflicts, err := app.GetChainConflicts()
+	if err != nil {
+		t.Fatalf("GetChainConflicts failed: %v", err)
+	}
+	fields := make([]string, len(conflicts))
+	for i, conflict := range conflicts {
+		fields[i] = conflict.Source + ":" + conflict.Field
+	}
+	if !reflect.DeepEqual(fields, []string{"trueBlocks.toml:chain", "trueBlocks.toml:rpcProviders"}) {
+		t.Errorf("Unexpected conflicts %+v", conflicts)
+	}
+
+	if err := app.SaveChainToConfig(1); err != nil {
+		t.Fatalf("SaveChainToConfig failed: %v", err)
+	}
+	chains, _ := tbconfig.ReadChains(app.tbConfig)
+	if len(chains) != 1 || chains[0].RpcProviders[0] != "https://rpc.example.com" {
+		t.Errorf("Expected the RPC provider in the config, got %+v", chains)
+	}
+	if err := app.SaveChainToConfig(5); err == nil {
+		t.Error("Expected an error for a chain the user does not have")
+	}
+}
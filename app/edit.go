@@ -0,0 +1,73 @@
+package app
+
+import (
+	"errors"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+)
+
+var ErrNothingToUndo = errors.New("nothing to undo")
+var ErrNothingToRedo = errors.New("nothing to redo")
+
+// HistoryStatus tells the frontend what the Edit menu's undo and redo items would do
+type HistoryStatus struct {
+	CanUndo   bool   `json:"canUndo"`
+	CanRedo   bool   `json:"canRedo"`
+	UndoLabel string `json:"undoLabel"`
+	RedoLabel string `json:"redoLabel"`
+}
+
+func (a *App) editUndo() (string, error) {
+	active := a.Projects.Active()
+	if active == nil {
+		return "", errors.New("no active project")
+	}
+
+	label := active.UndoLabel()
+	if !active.Undo() {
+		return "", ErrNothingToUndo
+	}
+
+	msgs.EmitManager(project.ProjectUndone)
+	return label, nil
+}
+
+func (a *App) editRedo() (string, error) {
+	active := a.Projects.Active()
+	if active == nil {
+		return "", errors.New("no active project")
+	}
+
+	label := active.RedoLabel()
+	if !active.Redo() {
+		return "", ErrNothingToRedo
+	}
+
+	msgs.EmitManager(project.ProjectRedone)
+	return label, nil
+}
+
+func (a *App) Undo() error {
+	_, err := a.editUndo()
+	return err
+}
+
+func (a *App) Redo() error {
+	_, err := a.editRedo()
+	return err
+}
+
+func (a *App) GetHistoryStatus() HistoryStatus {
+	active := a.Projects.Active()
+	if active == nil {
+		return HistoryStatus{}
+	}
+
+	return HistoryStatus{
+		CanUndo:   active.CanUndo(),
+		CanRedo:   active.CanRedo(),
+		UndoLabel: active.UndoLabel(),
+		RedoLabel: active.RedoLabel(),
+	}
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+)
+
+func TestEditUndoRedo(t *testing.T) {
+	app, dir, _ := getTestApp(t, false)
+	defer preferences.SetConfigBaseForTest(t, dir)()
+
+	if err := app.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Fatalf("Expected ErrNothingToUndo, got: %v", err)
+	}
+
+	activeProject := app.Projects.Active()
+	activeProject.SetName("Renamed Project")
+
+	if status := app.GetHistoryStatus(); !status.CanUndo || status.CanRedo {
+		t.Fatalf("Unexpected history status after change: %+v", status)
+	}
+
+	if err := app.Undo(); err != nil {
+		t.Fatalf("Undo() returned an error: %v", err)
+	}
+	if activeProject.GetName() != "Test Project" {
+		t.Fatalf("Expected name 'Test Project' after undo, got %s", activeProject.GetName())
+	}
+	if activeProject.IsDirty() {
+		t.Fatalf("Expected project to be clean after undoing to the saved state")
+	}
+
+	if err := app.Redo(); err != nil {
+		t.Fatalf("Redo() returned an error: %v", err)
+	}
+	if activeProject.GetName() != "Renamed Project" {
+		t.Fatalf("Expected name 'Renamed Project' after redo, got %s", activeProject.GetName())
+	}
+
+	if err := app.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Fatalf("Expected ErrNothingToRedo, got: %v", err)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
 	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
 	"github.com/TrueBlocks/trueblocks-core/src/apps/chifra/pkg/file"
 )
@@ -97,19 +99,77 @@ func (a *App) fileOpen(path string) error {
 	return nil
 }
 
-func (a *App) updateRecentProjects() {
-	activeProject := a.Projects.Active()
-	if activeProject == nil || activeProject.GetPath() == "" {
-		return
+// assetRoot is the folder generated images and series are stored under, and
+// so the folder templates lay out folders in
+const assetRoot = "."
+
+func getTemplatesFolder() string {
+	_, appFolder := preferences.GetConfigFolders()
+	return filepath.Join(appFolder, "templates")
+}
+
+func (a *App) fileNewFromTemplate(templateID string) error {
+	template, err := project.FindTemplate(getTemplatesFolder(), templateID)
+	if err != nil {
+		return err
+	}
+
+	created, err := a.Projects.NewFromTemplate(a.uniqueProjectName("New Project"), template, assetRoot)
+	if err != nil {
+		return err
+	}
+
+	// As with an empty project, nothing has been changed by the user yet
+	created.SetDirty(false)
+
+	a.updateRecentProjects()
+	return nil
+}
+
+func (a *App) fileExportBundle(dest string) (*project.BundleManifest, error) {
+	active := a.Projects.Active()
+	if active == nil {
+		return nil, errors.New("no active project")
 	}
 
-	path := activeProject.GetPath()
+	if dest == "" {
+		return nil, ErrEmptyFilePath
+	}
+
+	return project.ExportBundle(active, dest)
+}
+
+func (a *App) fileImportBundle(src, projectPath string, overwriteConfirmed bool) error {
+	if src == "" || projectPath == "" {
+		return ErrEmptyFilePath
+	}
+
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return ErrFileNotFound
+	}
+
+	if file.FileExists(projectPath) && !overwriteConfirmed {
+		return ErrOverwriteNotConfirmed
+	}
+
+	if _, _, err := project.ImportBundle(src, projectPath); err != nil {
+		return err
+	}
 
-	if err := a.Preferences.AddRecentProject(path); err != nil {
-		msgs.EmitError("add recent project failed", err)
+	return a.fileOpen(projectPath)
+}
+
+func (a *App) updateRecentProjects() {
+	activeProject := a.Projects.Active()
+	if activeProject == nil || activeProject.GetPath() == "" {
 		return
 	}
 
+	// Saving the session writes the updated list too
+	a.Preferences.UpdateApp(func(app *preferences.AppPreferences) {
+		app.AddRecentProject(activeProject.GetPath())
+	})
+	a.saveSession()
 	msgs.EmitMessage(msgs.EventProjectsUpdated, "")
 }
 
@@ -119,9 +179,9 @@ func (a *App) GetFilename() *project.Project {
 
 func (a *App) uniqueProjectName(baseName string) string {
 	projectExists := func(name string) bool {
-		for _, project := range a.Projects.GetOpenProjectIDs() {
-			projectObj := a.Projects.GetProjectByID(project)
-			if projectObj.Name == name {
+		projects, _ := a.Projects.Snapshot()
+		for _, projectObj := range projects {
+			if projectObj.GetName() == name {
 				return true
 			}
 		}
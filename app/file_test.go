@@ -182,7 +182,7 @@ func TestFileOpen(t *testing.T) {
 			Version: "1.0",
 			Name:    strings.ToLower(preferences.GetAppId().OrgName) + "-project",
 		}
-		data, _ := json.Marshal(proj)
+		data, _ := json.Marshal(&proj)
 		_ = os.WriteFile(filePath, data, 0644)
 
 		err := app.fileOpen(filePath)
@@ -199,8 +199,8 @@ func TestFileOpen(t *testing.T) {
 			t.Fatalf("Expected file path %s, got %s", filePath, activeProject.GetPath())
 		}
 
-		if activeProject.Version != "1.0" {
-			t.Fatalf("Expected version '1.0', got %s", activeProject.Version)
+		if activeProject.Version != project.CurrentVersion {
+			t.Fatalf("Expected version '%s', got %s", project.CurrentVersion, activeProject.Version)
 		}
 	})
 
@@ -224,7 +224,7 @@ func TestFileOpen(t *testing.T) {
 			Version: "1.0",
 			Name:    strings.ToLower(preferences.GetAppId().OrgName) + "-project",
 		}
-		data, _ := json.Marshal(proj)
+		data, _ := json.Marshal(&proj)
 		_ = os.WriteFile(filePath, data, 0644)
 
 		// Open the file
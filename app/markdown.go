@@ -7,7 +7,7 @@ import (
 )
 
 func (a *App) GetMarkdown(folder, route, tab string) string {
-	lang := a.Preferences.User.Language
+	lang := a.Preferences.GetUser().Language
 	if md, err := markdown.LoadMarkdown(a.Assets, filepath.Join("frontend", "src", "assets", folder), lang, route, tab); err != nil {
 		return err.Error()
 	} else {
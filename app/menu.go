@@ -1,10 +1,12 @@
 package app
 
 import (
-	"os"
+	"errors"
+	"strings"
 
 	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
 	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
 	"github.com/wailsapp/wails/v2/pkg/menu"
 	"github.com/wailsapp/wails/v2/pkg/menu/keys"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
@@ -38,7 +40,12 @@ func (a *App) FileOpen(data *menu.CallbackData) {
 }
 
 func (a *App) FileSave(data *menu.CallbackData) {
-	if err := a.fileSave(); err != nil {
+	err := a.fileSave()
+	if errors.Is(err, project.ErrModifiedOnDisk) {
+		a.resolveModifiedOnDisk(a.Projects.ActiveID())
+		return
+	}
+	if err != nil {
 		msgs.EmitError("Save failed", err)
 		return
 	}
@@ -62,9 +69,81 @@ func (a *App) FileSaveAs(data *menu.CallbackData) {
 	msgs.EmitStatus("File saved as")
 }
 
+var bundleFilters = []runtime.FileFilter{
+	{DisplayName: "Project Bundles (*.zip, *.tar.gz)", Pattern: "*.zip;*.tar.gz;*.tgz"},
+}
+
+func (a *App) FileExportBundle(_ *menu.CallbackData) {
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:   "Export Bundle",
+		Filters: bundleFilters,
+	})
+	if err != nil || path == "" {
+		msgs.EmitStatus("Export canceled")
+		return
+	}
+
+	manifest, err := a.fileExportBundle(path)
+	if err != nil {
+		msgs.EmitError("Export Bundle failed", err)
+		return
+	}
+
+	if len(manifest.Missing) > 0 {
+		msgs.EmitWarning("Export Bundle", "missing files left out: "+strings.Join(manifest.Missing, ", "))
+		return
+	}
+	msgs.EmitStatus("Bundle exported")
+}
+
+func (a *App) FileImportBundle(_ *menu.CallbackData) {
+	src, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:   "Import Bundle",
+		Filters: bundleFilters,
+	})
+	if err != nil || src == "" {
+		msgs.EmitStatus("Import canceled")
+		return
+	}
+
+	projectPath, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title: "Save Imported Project As",
+	})
+	if err != nil || projectPath == "" {
+		msgs.EmitStatus("Import canceled")
+		return
+	}
+
+	if err := a.fileImportBundle(src, projectPath, true); err != nil {
+		msgs.EmitError("Import Bundle failed", err)
+		return
+	}
+	msgs.EmitStatus("Bundle imported")
+}
+
+func (a *App) EditUndo(_ *menu.CallbackData) {
+	label, err := a.editUndo()
+	if err != nil {
+		msgs.EmitError("Edit → Undo failed", err)
+		return
+	}
+	msgs.EmitStatus("Undid " + label)
+}
+
+func (a *App) EditRedo(_ *menu.CallbackData) {
+	label, err := a.editRedo()
+	if err != nil {
+		msgs.EmitError("Edit → Redo failed", err)
+		return
+	}
+	msgs.EmitStatus("Redid " + label)
+}
+
 func (a *App) FileQuit(_ *menu.CallbackData) {
+	response := ""
 	if a.Projects.HasUnsavedChanges() {
-		response, err := runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
+		var err error
+		response, err = runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
 			Title:   "Unsaved Changes",
 			Message: "Do you want to save changes before quitting?",
 			Buttons: []string{"Yes", "No", "Cancel"},
@@ -74,21 +153,37 @@ func (a *App) FileQuit(_ *menu.CallbackData) {
 			msgs.EmitError("Dialog error", err)
 			return
 		}
+	}
 
-		switch response {
-		case "Yes":
-			if err := a.fileSave(); err != nil {
-				msgs.EmitError("Save failed", err)
-				return // Don't quit if save fails
-			}
-			// Continue to quit after successful save
-		case "Cancel":
-			return // Don't quit if user cancels
-		}
+	if !a.prepareQuit(response) {
+		return
 	}
 
+	// Quitting through the runtime runs BeforeClose, which writes the bounds,
+	// session and queued app preferences before the window goes away
 	msgs.EmitStatus("Quitting application")
-	os.Exit(0)
+	runtime.Quit(a.ctx)
+}
+
+// prepareQuit acts on the answer to the unsaved changes question, empty if
+// nothing was unsaved, and reports whether to go on quitting. Answering "No"
+// closes the abandoned projects, so the session is recorded before that and
+// BeforeClose leaves it alone.
+func (a *App) prepareQuit(response string) bool {
+	switch response {
+	case "Yes":
+		if err := a.fileSave(); err != nil {
+			msgs.EmitError("Save failed", err)
+			return false // Don't quit if save fails
+		}
+	case "No":
+		a.saveSession()
+		a.sessionSaved = true
+		a.Projects.CloseAll() // discards autosaved copies of the abandoned changes
+	case "Cancel":
+		return false // Don't quit if user cancels
+	}
+	return true
 }
 
 func (a *App) buildAppMenu() *menu.Menu {
@@ -110,9 +205,16 @@ func (a *App) buildAppMenu() *menu.Menu {
 	file.AddText("Open", keys.CmdOrCtrl("o"), a.FileOpen)
 	file.AddText("Save", keys.CmdOrCtrl("s"), a.FileSave)
 	file.AddText("Save As", keys.CmdOrCtrl("shift+s"), a.FileSaveAs)
+	file.AddSeparator()
+	file.AddText("Export Bundle...", nil, a.FileExportBundle)
+	file.AddText("Import Bundle...", nil, a.FileImportBundle)
 
 	// Edit Menu
 	edit := appMenu.AddSubmenu("Edit")
+	// Project history uses Alt so Cmd/Ctrl+Z keeps undoing typing in text fields
+	edit.AddText("Undo Project Change", keys.Combo("z", keys.CmdOrCtrlKey, keys.OptionOrAltKey), a.EditUndo)
+	edit.AddText("Redo Project Change", keys.Combo("z", keys.CmdOrCtrlKey, keys.OptionOrAltKey, keys.ShiftKey), a.EditRedo)
+	edit.AddSeparator()
 	edit.AddText("Cut", keys.CmdOrCtrl("x"), nil)        // menu.EditCut)
 	edit.AddText("Copy", keys.CmdOrCtrl("c"), nil)       // menu.EditCopy)
 	edit.AddText("Paste", keys.CmdOrCtrl("v"), nil)      // menu.EditPaste)
@@ -125,14 +227,20 @@ func (a *App) buildAppMenu() *menu.Menu {
 
 	// Help Menu
 	help := appMenu.AddSubmenu("Help")
-	// TODO: add applicastion name to this menu item
-	aboutLink := "https://" + preferences.GetAppId().Domain + "/about"
-	help.AddText("About", nil, func(_ *menu.CallbackData) {
-		runtime.BrowserOpenURL(a.ctx, aboutLink)
-	})
-	help.AddText("Report Issue", nil, func(_ *menu.CallbackData) {
-		runtime.BrowserOpenURL(a.ctx, preferences.GetAppId().Github+"/issues")
-	})
+	// Links the app's identity has no place for are left out
+	id := preferences.GetAppId()
+	if id.Domain != "" {
+		aboutLink := "https://" + id.Domain + "/about"
+		help.AddText("About "+id.AppName, nil, func(_ *menu.CallbackData) {
+			runtime.BrowserOpenURL(a.ctx, aboutLink)
+		})
+	}
+	if id.Github != "" {
+		issuesLink := strings.TrimSuffix(id.Github, "/") + "/issues"
+		help.AddText("Report Issue", nil, func(_ *menu.CallbackData) {
+			runtime.BrowserOpenURL(a.ctx, issuesLink)
+		})
+	}
 
 	return appMenu
 }
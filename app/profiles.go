@@ -0,0 +1,89 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+)
+
+// startInLastProfile selects the profile the app last used. If it has been
+// deleted since, the app starts in the default profile instead.
+func (a *App) startInLastProfile(appPrefs *preferences.AppPreferences) {
+	if err := preferences.SetProfile(appPrefs.Profile); err != nil {
+		msgs.EmitWarning("Profiles", fmt.Sprintf("profile %s is gone, using the default profile", appPrefs.Profile))
+		missing := appPrefs.Profile
+		appPrefs.SwitchRecentProjects(preferences.DefaultProfile)
+		delete(appPrefs.ProfileRecents, missing)
+		_ = preferences.SetProfile(preferences.DefaultProfile)
+	}
+}
+
+// GetProfiles returns the default profile followed by the named profiles
+func (a *App) GetProfiles() ([]string, error) {
+	return preferences.ListProfiles()
+}
+
+// GetActiveProfile returns the profile in use
+func (a *App) GetActiveProfile() string {
+	return preferences.GetProfile()
+}
+
+// CreateProfile adds a profile that starts with the default user preferences
+func (a *App) CreateProfile(name string) error {
+	return preferences.CreateProfile(name)
+}
+
+// DeleteProfile removes a profile other than the default or the one in use
+func (a *App) DeleteProfile(name string) error {
+	if err := preferences.DeleteProfile(name); err != nil {
+		return err
+	}
+	if _, ok := a.Preferences.GetApp().ProfileRecents[name]; ok {
+		return a.updateAppPreferences(func(app *preferences.AppPreferences) {
+			delete(app.ProfileRecents, name)
+		})
+	}
+	return nil
+}
+
+// SwitchProfile changes to another profile's user preferences and recent
+// projects. Open projects stay open. The new profile is used on the next
+// launch too.
+func (a *App) SwitchProfile(name string) error {
+	if name == "" {
+		name = preferences.DefaultProfile
+	}
+	previous := preferences.GetProfile()
+	if name == previous {
+		return nil
+	}
+
+	if err := preferences.SetProfile(name); err != nil {
+		return err
+	}
+	user, err := preferences.GetUserPreferences()
+	if err != nil {
+		_ = preferences.SetProfile(previous)
+		return err
+	}
+
+	a.Preferences.SetUser(user)
+	if err := a.updateAppPreferences(func(app *preferences.AppPreferences) {
+		app.SwitchRecentProjects(name)
+	}); err != nil {
+		msgs.EmitError("Saving app preferences failed", err)
+	}
+
+	// The watcher follows the user preferences of the profile it started with
+	if a.prefsWatch != nil {
+		a.stopWatchingPreferences()
+		a.watchPreferences()
+	}
+
+	sections, _ := json.Marshal([]preferences.PrefsFile{preferences.UserFile, preferences.AppFile})
+	msgs.EmitPreferencesChanged(string(sections))
+	msgs.EmitStatus(fmt.Sprintf("Switched to profile %s", name))
+	return nil
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+)
+
+func TestSwitchProfile(t *testing.T) {
+	defer preferences.SetConfigBaseForTest(t, t.TempDir())()
+
+	user, _ := preferences.GetUserPreferences()
+	user.Email = "research@example.com"
+	_ = preferences.SetUserPreferences(&user)
+
+	app := &App{Preferences: &preferences.Preferences{
+		User: user,
+		App:  preferences.AppPreferences{RecentProjects: []string{"mainnet.json"}},
+	}}
+
+	var changed []string
+	msgs.SetEmitter(func(messageType msgs.EventType, msgText string) {
+		if messageType == msgs.EventPreferencesChanged {
+			changed = append(changed, msgText)
+		}
+	})
+	defer msgs.SetEmitter(nil)
+
+	if err := app.SwitchProfile("dev"); err == nil {
+		t.Error("Expected switching to a missing profile to fail")
+	}
+	if err := app.CreateProfile("dev"); err != nil {
+		t.Fatalf("CreateProfile failed: %v", err)
+	}
+	if err := app.SwitchProfile("dev"); err != nil {
+		t.Fatalf("SwitchProfile failed: %v", err)
+	}
+	if app.Preferences.User.Email != "" || len(app.Preferences.App.RecentProjects) != 0 {
+		t.Errorf("Expected the new profile's empty preferences, got %+v and %v", app.Preferences.User, app.Preferences.App.RecentProjects)
+	}
+	if len(changed) != 1 || changed[0] != `["user","app"]` {
+		t.Errorf("Expected one preferences changed event, got %v", changed)
+	}
+
+	// The next launch starts in the profile last used
+	saved, _ := preferences.GetAppPreferences()
+	if saved.Profile != "dev" {
+		t.Errorf("Expected the profile to be remembered, got %q", saved.Profile)
+	}
+	_ = preferences.SetProfile(preferences.DefaultProfile)
+	app.startInLastProfile(&saved)
+	if preferences.GetProfile() != "dev" {
+		t.Errorf("Expected to start in the dev profile, got %s", preferences.GetProfile())
+	}
+
+	if err := app.SwitchProfile(preferences.DefaultProfile); err != nil {
+		t.Fatalf("SwitchProfile failed: %v", err)
+	}
+	if app.Preferences.User.Email != "research@example.com" || app.Preferences.App.RecentProjects[0] != "mainnet.json" {
+		t.Errorf("Expected the default profile back, got %+v and %v", app.Preferences.User, app.Preferences.App.RecentProjects)
+	}
+}
@@ -1,29 +1,52 @@
 package app
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
+	"strings"
 
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
+// defaultMaxResident is the number of open projects kept fully in memory
+// unless the app preferences say otherwise
+const defaultMaxResident = 8
+
+func getSpillFolder() string {
+	_, appFolder := preferences.GetConfigFolders()
+	return filepath.Join(appFolder, "spill")
+}
+
+func (a *App) maxResidentProjects() int {
+	if limit := a.Preferences.GetApp().MaxResident; limit > 0 {
+		return limit
+	}
+	return defaultMaxResident
+}
+
 func (a *App) SwitchToProject(id string) error {
-	if a.Projects.GetProjectByID(id) == nil {
-		return fmt.Errorf("no project with ID %s exists", id)
+	if err := a.Projects.SetActive(id); err != nil {
+		return err
 	}
-	return a.Projects.SetActive(id)
+	a.saveSession()
+	return nil
 }
 
 func (a *App) CloseProject(id string) error {
-	project := a.Projects.GetProjectByID(id)
-	if project == nil {
+	proj := a.Projects.GetProjectByID(id)
+	if proj == nil {
 		return fmt.Errorf("no project with ID %s exists", id)
 	}
 
 	// Check if project has unsaved changes
-	if project.IsDirty() {
+	if proj.IsDirty() {
 		response, err := runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
 			Title:   "Unsaved Changes",
-			Message: fmt.Sprintf("Do you want to save changes to project '%s' before closing?", project.GetName()),
+			Message: fmt.Sprintf("Do you want to save changes to project '%s' before closing?", proj.GetName()),
 			Buttons: []string{"Yes", "No", "Cancel"},
 		})
 
@@ -34,7 +57,7 @@ func (a *App) CloseProject(id string) error {
 		switch response {
 		case "Yes":
 			// Save the project before closing
-			if project.GetPath() == "" {
+			if proj.GetPath() == "" {
 				// Project hasn't been saved before, need to use SaveAs
 				path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
 					Title: "Save Project Before Closing",
@@ -44,13 +67,20 @@ func (a *App) CloseProject(id string) error {
 				}
 
 				// Use project's SaveAs method instead of SaveProjectAs
-				if err := project.SaveAs(path); err != nil {
+				if err := proj.SaveAs(path); err != nil {
 					return err
 				}
 			} else {
 				// Project has a path, use normal save
 				// Use project's Save method instead of SaveProject
-				if err := project.Save(); err != nil {
+				err := proj.Save()
+				if errors.Is(err, project.ErrModifiedOnDisk) {
+					// Only a project left without unsaved changes is closed
+					a.resolveModifiedOnDisk(id)
+					if proj.IsDirty() {
+						return fmt.Errorf("close canceled: %w", err)
+					}
+				} else if err != nil {
 					return err
 				}
 			}
@@ -59,5 +89,122 @@ func (a *App) CloseProject(id string) error {
 		}
 	}
 
-	return a.Projects.Close(id)
+	if err := a.Projects.Close(id); err != nil {
+		return err
+	}
+	a.saveSession()
+	return nil
+}
+
+// resolveModifiedOnDisk asks what to do when saving the project with the given
+// ID would overwrite changes made to its file by another program
+func (a *App) resolveModifiedOnDisk(id string) {
+	project := a.Projects.GetProjectByID(id)
+	if project == nil {
+		return
+	}
+
+	response, err := runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
+		Title:   "File Changed on Disk",
+		Message: fmt.Sprintf("The file for project '%s' was changed by another program. Reload it and lose your changes, overwrite it, or merge both sets of changes?", project.GetName()),
+		Buttons: []string{"Reload", "Overwrite", "Merge", "Cancel"},
+	})
+	if err != nil {
+		msgs.EmitError("Save failed", err)
+		return
+	}
+
+	switch response {
+	case "Reload":
+		if err := a.ReloadProject(id); err != nil {
+			msgs.EmitError("Reload failed", err)
+			return
+		}
+		msgs.EmitStatus("Project reloaded from disk")
+	case "Overwrite":
+		if err := a.OverwriteProject(id); err != nil {
+			msgs.EmitError("Save failed", err)
+			return
+		}
+		msgs.EmitStatus("File saved")
+	case "Merge":
+		conflicts, err := a.MergeProject(id)
+		if err != nil {
+			msgs.EmitError("Merge failed", err)
+			return
+		}
+		if len(conflicts) > 0 {
+			msgs.EmitWarning("Merge", "kept your version of "+strings.Join(conflicts, ", "))
+		}
+		// The conflict may be in a project other than the active one
+		if err := a.Projects.Save(id); err != nil {
+			msgs.EmitError("Save failed", err)
+			return
+		}
+		msgs.EmitStatus("Changes merged and saved")
+	default:
+		msgs.EmitStatus("Save canceled")
+	}
+}
+
+// ReloadProject discards unsaved changes and rereads the project from its file
+func (a *App) ReloadProject(id string) error {
+	return a.Projects.Reload(id)
+}
+
+// OverwriteProject saves the project over a file that was changed by another program
+func (a *App) OverwriteProject(id string) error {
+	if err := a.Projects.Overwrite(id); err != nil {
+		return err
+	}
+	a.updateRecentProjects()
+	return nil
+}
+
+// MergeProject folds changes made to the project's file by another program
+// into the open project. It returns the keys that changed on both sides,
+// for which the open project's values were kept.
+func (a *App) MergeProject(id string) ([]string, error) {
+	return a.Projects.Merge(id)
+}
+
+// ListProjectTemplates returns the built-in and user templates a new project can start from
+func (a *App) ListProjectTemplates() []project.Template {
+	templates, err := project.ListTemplates(getTemplatesFolder())
+	if err != nil {
+		msgs.EmitError("Listing project templates failed", err)
+		return []project.Template{}
+	}
+	return templates
+}
+
+// NewProjectFromTemplate creates a new project from the template with the given ID
+func (a *App) NewProjectFromTemplate(templateID string) error {
+	return a.fileNewFromTemplate(templateID)
+}
+
+// applyBackupCount passes the org's backup setting on to project saves
+func (a *App) applyBackupCount() {
+	count := a.Preferences.GetOrg().BackupCount
+	if count == 0 {
+		count = project.DefaultBackups
+	}
+	project.SetBackupGenerations(count)
+}
+
+// GetProjectBackups lists the previous versions kept for the project's file, most recent first
+func (a *App) GetProjectBackups(id string) ([]project.Backup, error) {
+	p := a.Projects.GetProjectByID(id)
+	if p == nil {
+		return nil, fmt.Errorf("no project with ID %s exists", id)
+	}
+	if p.GetPath() == "" {
+		return []project.Backup{}, nil
+	}
+	return project.ListBackups(p.GetPath())
+}
+
+// RestoreProjectBackup rolls the project back to one of its backups, discarding unsaved changes
+func (a *App) RestoreProjectBackup(id string, generation int) error {
+	return a.Projects.RestoreBackup(id, generation)
 }
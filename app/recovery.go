@@ -0,0 +1,55 @@
+package app
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"time"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+)
+
+const autosaveInterval = 30 * time.Second
+
+func getRecoveryFolder() string {
+	_, appFolder := preferences.GetConfigFolders()
+	return filepath.Join(appFolder, "recovery")
+}
+
+// offerRecovery tells the frontend about projects left unsaved by an earlier session
+func (a *App) offerRecovery() {
+	entries, err := a.Projects.PendingRecovery()
+	if err != nil {
+		msgs.EmitError("Checking for recoverable projects failed", err)
+		return
+	}
+
+	if len(entries) == 0 {
+		return
+	}
+
+	bytes, _ := json.Marshal(entries)
+	msgs.EmitRecovery(string(bytes))
+}
+
+func (a *App) GetRecoveryEntries() []project.RecoveryEntry {
+	entries, err := a.Projects.PendingRecovery()
+	if err != nil {
+		msgs.EmitError("Checking for recoverable projects failed", err)
+		return []project.RecoveryEntry{}
+	}
+	return entries
+}
+
+func (a *App) RestoreRecovery(id string) error {
+	if _, err := a.Projects.Recover(id); err != nil {
+		return err
+	}
+	msgs.EmitMessage(msgs.EventProjectsUpdated, "")
+	return nil
+}
+
+func (a *App) DiscardRecovery(id string) error {
+	return a.Projects.DiscardRecovery(id)
+}
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/openai"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/secrets"
+)
+
+// ErrNoKeystore is returned by the secret bindings before the keystore is open
+var ErrNoKeystore = errors.New("keystore is not open")
+
+// SecretInfo describes a secret to the settings UI without revealing it
+type SecretInfo struct {
+	Name string `json:"name"`
+	Hint string `json:"hint"` // The last few characters of long secrets, otherwise empty
+}
+
+// SecretsStatus tells the settings UI how the keystore is protected
+type SecretsStatus struct {
+	Mode   secrets.Mode `json:"mode"`
+	Locked bool         `json:"locked"`
+}
+
+// openKeystore opens the keystore in the app folder and moves any keys loaded
+// from a .env file into it. A passphrase protected keystore stays locked
+// until the user unlocks it in the settings.
+func (a *App) openKeystore() {
+	_, appFolder := preferences.GetConfigFolders()
+	store, err := secrets.OpenDefault(appFolder, preferences.EnvName("passphrase"))
+	if err != nil {
+		msgs.EmitError("Failed to open keystore", err)
+		return
+	}
+	a.keystore = store
+
+	if store.Locked() {
+		msgs.EmitWarning("Keystore", "the keystore is locked, unlock it in the settings to use your API keys")
+		return
+	}
+	if imported, err := secrets.ImportEnv(store, openai.APIKeyName); err != nil {
+		msgs.EmitError("Failed to import keys into keystore", err)
+	} else if len(imported) > 0 {
+		msgs.EmitStatus(fmt.Sprintf("Moved %d key(s) from the environment into the keystore", len(imported)))
+	}
+}
+
+func (a *App) getKeystore() (*secrets.Store, error) {
+	if a.keystore == nil {
+		return nil, ErrNoKeystore
+	}
+	return a.keystore, nil
+}
+
+// GetSecretsStatus returns how the keystore is protected and whether it is locked
+func (a *App) GetSecretsStatus() (SecretsStatus, error) {
+	store, err := a.getKeystore()
+	if err != nil {
+		return SecretsStatus{}, err
+	}
+	return SecretsStatus{Mode: store.Mode(), Locked: store.Locked()}, nil
+}
+
+// UnlockSecrets unlocks a passphrase protected keystore. RPC credentials
+// that could not move into it while it was locked move now.
+func (a *App) UnlockSecrets(passphrase string) error {
+	store, err := a.getKeystore()
+	if err != nil {
+		return err
+	}
+	if err := store.Unlock(passphrase); err != nil {
+		return err
+	}
+	if user, err := preferences.GetUserPreferences(); err == nil {
+		a.Preferences.SetUser(user)
+	}
+	return nil
+}
+
+// SetSecretsPassphrase protects the keystore with passphrase, or with a key
+// derived from this machine if passphrase is empty
+func (a *App) SetSecretsPassphrase(passphrase string) error {
+	store, err := a.getKeystore()
+	if err != nil {
+		return err
+	}
+	return store.SetPassphrase(passphrase)
+}
+
+// ListSecrets describes the secrets in the keystore. Their values never
+// leave the backend.
+func (a *App) ListSecrets() ([]SecretInfo, error) {
+	store, err := a.getKeystore()
+	if err != nil {
+		return nil, err
+	}
+	names, err := store.Names()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]SecretInfo, 0, len(names))
+	for _, name := range names {
+		info := SecretInfo{Name: name}
+		if value, err := store.Get(name); err == nil && len(value) >= 16 {
+			info.Hint = "…" + value[len(value)-4:]
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// SetSecret adds or replaces a secret in the keystore
+func (a *App) SetSecret(name, value string) error {
+	store, err := a.getKeystore()
+	if err != nil {
+		return err
+	}
+	if err := store.Set(name, value); err != nil {
+		return err
+	}
+	msgs.EmitStatus(fmt.Sprintf("Saved secret %s", name))
+	return nil
+}
+
+// DeleteSecret removes a secret from the keystore
+func (a *App) DeleteSecret(name string) error {
+	store, err := a.getKeystore()
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(name); err != nil {
+		return err
+	}
+	msgs.EmitStatus(fmt.Sprintf("Deleted secret %s", name))
+	return nil
+}
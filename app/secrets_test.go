@@ -0,0 +1,50 @@
+package app
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/secrets"
+)
+
+func TestSecretBindings(t *testing.T) {
+	defer preferences.SetConfigBaseForTest(t, t.TempDir())()
+	defer secrets.SetDefault(nil)
+
+	app := &App{Preferences: &preferences.Preferences{}}
+	if _, err := app.ListSecrets(); !errors.Is(err, ErrNoKeystore) {
+		t.Errorf("Expected ErrNoKeystore before the keystore is open, got %v", err)
+	}
+
+	var events []string
+	msgs.SetEmitter(func(messageType msgs.EventType, msgText string) {
+		events = append(events, msgText)
+	})
+	defer msgs.SetEmitter(nil)
+
+	t.Setenv("OPENAI_API_KEY", "sk-from-dotenv-0123456789")
+	app.openKeystore()
+
+	infos, err := app.ListSecrets()
+	if err != nil || len(infos) != 1 || infos[0].Name != "OPENAI_API_KEY" || infos[0].Hint != "…6789" {
+		t.Fatalf("Expected the imported key with a hint, got %+v and %v", infos, err)
+	}
+
+	if err := app.SetSecret("rpc-token", "tok-abcdefgh"); err != nil {
+		t.Fatalf("SetSecret failed: %v", err)
+	}
+	if err := app.DeleteSecret("rpc-token"); err != nil {
+		t.Fatalf("DeleteSecret failed: %v", err)
+	}
+
+	// Secrets never reach an event
+	msgs.EmitStatus("key is sk-from-dotenv-0123456789")
+	for _, event := range events {
+		if strings.Contains(event, "sk-from-dotenv") {
+			t.Errorf("Expected the key to be redacted, got %q", event)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+	"github.com/TrueBlocks/trueblocks-core/src/apps/chifra/pkg/file"
+)
+
+// saveSession records the open projects, in tab order, and the active one in
+// the app preferences so the next launch can reopen them
+func (a *App) saveSession() {
+	paths, activePath := a.Projects.OpenPaths()
+	record := func(app *preferences.AppPreferences) {
+		app.Session = preferences.Session{
+			OpenProjects:  paths,
+			ActiveProject: activePath,
+		}
+	}
+
+	if !a.IsReady() {
+		a.Preferences.UpdateApp(record)
+		return
+	}
+	if err := a.updateAppPreferences(record); err != nil {
+		msgs.EmitError("Saving session failed", err)
+	}
+}
+
+// restoreSession reopens the projects that were open when the app last closed
+// and reactivates the one that was active. Files that no longer exist are
+// skipped with a warning. Without a saved session the most recent project is
+// opened instead.
+func (a *App) restoreSession() {
+	appPrefs := a.Preferences.GetApp()
+	session := appPrefs.Session
+	paths := session.OpenProjects
+	if len(paths) == 0 && len(appPrefs.RecentProjects) > 0 {
+		paths = appPrefs.RecentProjects[:1]
+	}
+
+	for _, path := range paths {
+		if !file.FileExists(path) {
+			msgs.EmitWarning("Restore session", "project file not found: "+path)
+			continue
+		}
+		if _, err := a.Projects.Open(path); err != nil {
+			msgs.EmitError("Failed to reopen project", err)
+		}
+	}
+
+	if session.ActiveProject != "" && a.Projects.GetProjectByPath(session.ActiveProject) != nil {
+		if _, err := a.Projects.Open(session.ActiveProject); err != nil {
+			msgs.EmitError("Failed to reactivate project", err)
+		}
+	}
+
+	msgs.EmitMessage(msgs.EventProjectsUpdated, "")
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+)
+
+func TestSessionRestore(t *testing.T) {
+	app, dir, firstPath := getTestApp(t, false)
+	defer preferences.SetConfigBaseForTest(t, dir)()
+
+	paths := []string{firstPath}
+	for _, name := range []string{"second", "third"} {
+		path := filepath.Join(dir, name+".json")
+		if err := project.New(name).SaveAs(path); err != nil {
+			t.Fatalf("Failed to save project: %v", err)
+		}
+		if err := app.fileOpen(path); err != nil {
+			t.Fatalf("Failed to open project: %v", err)
+		}
+		paths = append(paths, path)
+	}
+	app.Projects.New("New Project") // never saved, so not part of the session
+
+	second := app.Projects.GetProjectByPath(paths[1])
+	if err := app.SwitchToProject(second.GetID()); err != nil {
+		t.Fatalf("Failed to switch project: %v", err)
+	}
+
+	app.saveSession()
+	session := app.Preferences.App.Session
+	if strings.Join(session.OpenProjects, ",") != strings.Join(paths, ",") {
+		t.Fatalf("Expected open projects %v in order, got %v", paths, session.OpenProjects)
+	}
+	if session.ActiveProject != paths[1] {
+		t.Fatalf("Expected active project %s, got %s", paths[1], session.ActiveProject)
+	}
+
+	// Restart with the third project deleted in the meantime
+	if err := os.Remove(paths[2]); err != nil {
+		t.Fatalf("Failed to remove project: %v", err)
+	}
+
+	var warnings []string
+	msgs.SetEmitter(func(messageType msgs.EventType, msgText string) {
+		if messageType == msgs.EventError {
+			warnings = append(warnings, msgText)
+		}
+	})
+	defer msgs.SetEmitter(nil)
+
+	restarted := &App{
+		Preferences: &preferences.Preferences{App: preferences.AppPreferences{Session: session}},
+		Projects:    project.NewManager(),
+	}
+	restarted.restoreSession()
+
+	var reopened []string
+	for _, id := range restarted.Projects.GetOpenProjectIDs() {
+		reopened = append(reopened, restarted.Projects.GetProjectByID(id).GetPath())
+	}
+	if strings.Join(reopened, ",") != strings.Join(paths[:2], ",") {
+		t.Errorf("Expected %v to be reopened in order, got %v", paths[:2], reopened)
+	}
+	if active := restarted.Projects.Active(); active == nil || active.GetPath() != paths[1] {
+		t.Errorf("Expected %s to be active again", paths[1])
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], paths[2]) {
+		t.Errorf("Expected one warning about the missing project, got %v", warnings)
+	}
+}
+
+func TestQuitWithoutSavingKeepsSession(t *testing.T) {
+	app, dir, path := getTestApp(t, true)
+	defer preferences.SetConfigBaseForTest(t, dir)()
+	app.ctx = context.Background()
+	msgs.SetEmitter(func(msgs.EventType, string) {})
+	defer msgs.SetEmitter(nil)
+
+	// Answering "No" closes the abandoned project, then BeforeClose shuts down
+	if !app.prepareQuit("No") {
+		t.Fatal("Expected to go on quitting")
+	}
+	app.shutdown()
+
+	saved, err := preferences.GetAppPreferences()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(saved.Session.OpenProjects, ",") != path || saved.Session.ActiveProject != path {
+		t.Errorf("Expected the session to keep %s, got %+v", path, saved.Session)
+	}
+}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+)
+
+// resolver merges the loaded org and user preferences with the active
+// project's preferences and the environment
+func (a *App) resolver() *preferences.Resolver {
+	var projectPrefs map[string]string
+	if active := a.Projects.Active(); active != nil {
+		projectPrefs = active.GetPreferences()
+	}
+	return preferences.NewResolver(a.Preferences.GetOrg(), a.Preferences.GetUser(), projectPrefs)
+}
+
+// GetEffectiveSettings returns every setting with its effective value, where
+// that value came from, and the values it overrides
+func (a *App) GetEffectiveSettings() []preferences.Setting {
+	return a.resolver().All()
+}
+
+// ResolveSetting returns the effective value of one setting. A setting no
+// source gives a value has an empty source and no layers.
+func (a *App) ResolveSetting(key string) preferences.Setting {
+	setting, _ := a.resolver().Resolve(key)
+	return setting
+}
+
+// watchPreferences reloads the org and user preferences when another program
+// changes their files
+func (a *App) watchPreferences() {
+	watcher, err := preferences.NewWatcher(prefsReloadDelay, a.reloadPreferences, func(err error) {
+		msgs.EmitError("Watching preference files failed", err)
+	})
+	if err != nil {
+		msgs.EmitError("Failed to watch preference files", err)
+		return
+	}
+	a.prefsWatch = watcher
+}
+
+func (a *App) stopWatchingPreferences() {
+	if a.prefsWatch == nil {
+		return
+	}
+	_ = a.prefsWatch.Close()
+	a.prefsWatch = nil
+}
+
+// reloadPreferences takes over changed preference files and tells the
+// frontend which sections changed so the settings views can refresh. A file
+// that does not load or validate is reported and the previous values kept.
+func (a *App) reloadPreferences(files []preferences.PrefsFile) {
+	changed, err := a.Preferences.Reload(files)
+	if err != nil {
+		msgs.EmitError("Reloading preferences failed", err)
+	}
+	if len(changed) == 0 {
+		return
+	}
+
+	for _, file := range changed {
+		if file == preferences.OrgFile {
+			a.applyBackupCount()
+		}
+	}
+	sections, _ := json.Marshal(changed)
+	msgs.EmitPreferencesChanged(string(sections))
+}
+
+// ExportSettings writes the org, user and app preferences, and unless
+// excluded the keystore's secrets and the window bounds, to a settings bundle
+func (a *App) ExportSettings(dest string, opts preferences.ExportOptions) error {
+	if dest == "" {
+		return ErrEmptyFilePath
+	}
+	// The file must hold what the app holds
+	if err := a.saveAppPreferences(); err != nil {
+		return err
+	}
+	if a.appWriter != nil {
+		if err := a.appWriter.Flush(); err != nil {
+			return err
+		}
+	}
+	return preferences.ExportSettings(dest, opts)
+}
+
+// PreviewSettingsImport validates a settings bundle and lists what importing
+// it would change, without changing anything
+func (a *App) PreviewSettingsImport(src string) ([]preferences.SettingChange, error) {
+	bundle, err := a.readSettingsBundle(src)
+	if err != nil {
+		return nil, err
+	}
+	return bundle.Diff()
+}
+
+// ImportSettings validates a settings bundle and applies it, either entirely
+// or not at all. It returns what changed.
+func (a *App) ImportSettings(src string) ([]preferences.SettingChange, error) {
+	bundle, err := a.readSettingsBundle(src)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := bundle.Diff()
+	if err != nil {
+		return nil, err
+	}
+	if err := bundle.Apply(); err != nil {
+		return nil, err
+	}
+
+	org, errOrg := preferences.GetOrgPreferences()
+	user, errUser := preferences.GetUserPreferences()
+	appPrefs, errApp := preferences.GetAppPreferences()
+	if err := errors.Join(errOrg, errUser, errApp); err != nil {
+		return changes, err
+	}
+	a.Preferences.SetOrg(org)
+	a.Preferences.SetUser(user)
+	a.Preferences.SetApp(appPrefs)
+	a.applyBackupCount()
+
+	sections, _ := json.Marshal([]preferences.PrefsFile{preferences.OrgFile, preferences.UserFile, preferences.AppFile})
+	msgs.EmitPreferencesChanged(string(sections))
+	return changes, nil
+}
+
+// readSettingsBundle reads a bundle once any app preference changes still
+// queued are on disk, so the diff is taken against what the app holds
+func (a *App) readSettingsBundle(src string) (*preferences.SettingsBundle, error) {
+	if src == "" {
+		return nil, ErrEmptyFilePath
+	}
+	if a.appWriter != nil {
+		if err := a.appWriter.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	return preferences.ReadSettingsBundle(src)
+}
@@ -0,0 +1,148 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+)
+
+func TestReloadPreferencesEmitsChangedSections(t *testing.T) {
+	dir := t.TempDir()
+	defer preferences.SetConfigBaseForTest(t, dir)()
+	t.Setenv(preferences.EnvName("policy"), filepath.Join(t.TempDir(), "policy.json"))
+
+	org, _ := preferences.GetOrgPreferences()
+	user, _ := preferences.GetUserPreferences()
+	app := &App{
+		Preferences: &preferences.Preferences{Org: org, User: user},
+		Projects:    project.NewManager(),
+	}
+
+	var events []string
+	msgs.SetEmitter(func(messageType msgs.EventType, msgText string) {
+		if messageType == msgs.EventPreferencesChanged {
+			events = append(events, msgText)
+		}
+	})
+	defer msgs.SetEmitter(nil)
+
+	// Saving from the app does not count as an outside change
+	user.Theme = "light"
+	if err := app.SetUserPreferences(&user); err != nil {
+		t.Fatalf("Failed to save user preferences: %v", err)
+	}
+	app.reloadPreferences([]preferences.PrefsFile{preferences.UserFile})
+	if len(events) != 0 {
+		t.Errorf("Expected no event for the app's own save, got %v", events)
+	}
+
+	edited := []byte(`{"version": "1.0", "theme": "dark", "backupCount": 3}`)
+	if err := os.WriteFile(filepath.Join(dir, "org_prefs.json"), edited, 0644); err != nil {
+		t.Fatal(err)
+	}
+	app.reloadPreferences([]preferences.PrefsFile{preferences.OrgFile, preferences.UserFile})
+	if len(events) != 1 || events[0] != `["org"]` {
+		t.Errorf("Expected one event naming the org section, got %v", events)
+	}
+	if app.Preferences.Org.BackupCount != 3 {
+		t.Errorf("Expected the edited org preferences to be taken over, got %+v", app.Preferences.Org)
+	}
+	project.SetBackupGenerations(project.DefaultBackups)
+}
+
+func TestImportSettings(t *testing.T) {
+	defer preferences.SetConfigBaseForTest(t, t.TempDir())()
+	t.Setenv(preferences.EnvName("policy"), filepath.Join(t.TempDir(), "policy.json"))
+
+	org, _ := preferences.GetOrgPreferences()
+	user, _ := preferences.GetUserPreferences()
+	appPrefs, _ := preferences.GetAppPreferences()
+	app := &App{Preferences: &preferences.Preferences{Org: org, User: user, App: appPrefs}}
+
+	src := filepath.Join(t.TempDir(), "settings.json")
+	if err := app.ExportSettings(src, preferences.ExportOptions{ExcludeSecrets: true}); err != nil {
+		t.Fatalf("ExportSettings failed: %v", err)
+	}
+	bundle := []byte(`{"version": "1", "org": {"version": "1.0", "theme": "dark", "backupCount": 5}, "user": {"version": "1.0", "theme": "light"}, "app": {"version": "1.0"}}`)
+	if err := os.WriteFile(src, bundle, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []string
+	msgs.SetEmitter(func(messageType msgs.EventType, msgText string) {
+		if messageType == msgs.EventPreferencesChanged {
+			events = append(events, msgText)
+		}
+	})
+	defer msgs.SetEmitter(nil)
+
+	preview, err := app.PreviewSettingsImport(src)
+	if err != nil || len(preview) == 0 {
+		t.Fatalf("Expected changes to preview, got %v and %v", preview, err)
+	}
+	if app.Preferences.User.Theme == "light" || len(events) != 0 {
+		t.Error("Previewing an import should not change anything")
+	}
+
+	changes, err := app.ImportSettings(src)
+	if err != nil || len(changes) != len(preview) {
+		t.Fatalf("Expected the previewed changes, got %v and %v", changes, err)
+	}
+	if app.Preferences.User.Theme != "light" || app.Preferences.Org.BackupCount != 5 {
+		t.Errorf("Expected the imported preferences in the app, got %+v and %+v", app.Preferences.User, app.Preferences.Org)
+	}
+	if len(events) != 1 || events[0] != `["org","user","app"]` {
+		t.Errorf("Expected one event naming every section, got %v", events)
+	}
+	project.SetBackupGenerations(project.DefaultBackups)
+}
+
+func TestReloadPreferencesWhileSetting(t *testing.T) {
+	dir := t.TempDir()
+	defer preferences.SetConfigBaseForTest(t, dir)()
+	t.Setenv(preferences.EnvName("policy"), filepath.Join(t.TempDir(), "policy.json"))
+	defer project.SetBackupGenerations(project.DefaultBackups)
+
+	org, _ := preferences.GetOrgPreferences()
+	user, _ := preferences.GetUserPreferences()
+	app := &App{
+		Preferences: &preferences.Preferences{Org: org, User: user},
+		Projects:    project.NewManager(),
+	}
+	msgs.SetEmitter(func(msgs.EventType, string) {})
+	defer msgs.SetEmitter(nil)
+
+	// The watcher's goroutine reloads while the bindings save and read,
+	// which go test -race reports if the preferences are not guarded
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			app.reloadPreferences([]preferences.PrefsFile{preferences.OrgFile, preferences.UserFile})
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		user := *app.GetUserPreferences()
+		user.Name = fmt.Sprintf("user %d", i)
+		if err := app.SetUserPreferences(&user); err != nil {
+			t.Errorf("Failed to save user preferences: %v", err)
+		}
+		org := *app.GetOrgPreferences()
+		org.BackupCount = i%5 + 1
+		if err := app.SetOrgPreferences(&org); err != nil {
+			t.Errorf("Failed to save org preferences: %v", err)
+		}
+		_ = app.GetUserInfoStatus()
+	}
+	<-done
+
+	app.reloadPreferences([]preferences.PrefsFile{preferences.OrgFile, preferences.UserFile})
+	if got := app.GetUserPreferences().Name; got != "user 49" {
+		t.Errorf("Expected the last saved name, got %q", got)
+	}
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"os"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+)
+
+func TestFileNewFromTemplate(t *testing.T) {
+	app, dir, _ := getTestApp(t, false)
+	defer preferences.SetConfigBaseForTest(t, dir)()
+
+	// Templates lay out their folders in the working folder
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change folder: %v", err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+
+	templates := app.ListProjectTemplates()
+	if len(templates) == 0 {
+		t.Fatal("Expected the built-in templates to be listed")
+	}
+
+	// Every built-in template must produce a valid project with the sections the app registers
+	for _, template := range templates {
+		if err := app.NewProjectFromTemplate(template.ID); err != nil {
+			t.Errorf("Template %s failed: %v", template.ID, err)
+			continue
+		}
+		active := app.Projects.Active()
+		if active == nil || active.IsDirty() {
+			t.Errorf("Template %s should open a clean, active project", template.ID)
+		}
+	}
+}
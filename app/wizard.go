@@ -14,12 +14,13 @@ type UserInfoStatus struct {
 }
 
 func (a *App) GetUserInfoStatus() UserInfoStatus {
-	missingNameEmail := a.Preferences.User.Name == "" || a.Preferences.User.Email == ""
+	user := a.Preferences.GetUser()
+	missingNameEmail := user.Name == "" || user.Email == ""
 
 	rpcUnavailable := true
 	if !missingNameEmail {
 		hasRpcs := false
-		for _, chain := range a.Preferences.User.Chains {
+		for _, chain := range user.Chains {
 			if len(chain.RpcProviders) > 0 {
 				hasRpcs = true
 				break
@@ -49,10 +50,11 @@ func (a *App) SetUserInfo(name, email string) error {
 		return fmt.Errorf("invalid name: %w", err)
 	}
 
-	a.Preferences.User.Name = name
-	a.Preferences.User.Email = email
+	user := a.Preferences.GetUser()
+	user.Name = name
+	user.Email = email
 
-	return preferences.SetUserPreferences(&a.Preferences.User)
+	return a.SetUserPreferences(&user)
 }
 
 func (a *App) SetChain(ch preferences.Chain) error {
@@ -65,10 +67,17 @@ func (a *App) SetChain(ch preferences.Chain) error {
 		}
 	}
 
+	// A chain picked by ID gets its name, symbol and explorer from the chain options
+	ch = a.prefillChain(ch)
+
+	// Work on a copy so a change the org policy rejects leaves nothing behind
+	user := a.Preferences.GetUser()
+	user.Chains = append([]preferences.Chain{}, user.Chains...)
+
 	chainID := ch.ChainId
 	foundInChain := false
 
-	for i, chain := range a.Preferences.User.Chains {
+	for i, chain := range user.Chains {
 		if chain.ChainId == chainID {
 			// If this chain already exists, update its RPC providers
 			for _, existingRpc := range chain.RpcProviders {
@@ -81,11 +90,11 @@ func (a *App) SetChain(ch preferences.Chain) error {
 			}
 
 			// Add new RPCs at the beginning of the list
-			a.Preferences.User.Chains[i].RpcProviders = append(ch.RpcProviders, chain.RpcProviders...)
+			user.Chains[i].RpcProviders = append(ch.RpcProviders, chain.RpcProviders...)
 
 			// Limit to 5 RPCs
-			if len(a.Preferences.User.Chains[i].RpcProviders) > 5 {
-				a.Preferences.User.Chains[i].RpcProviders = a.Preferences.User.Chains[i].RpcProviders[:5]
+			if len(user.Chains[i].RpcProviders) > 5 {
+				user.Chains[i].RpcProviders = user.Chains[i].RpcProviders[:5]
 			}
 
 			foundInChain = true
@@ -95,16 +104,16 @@ func (a *App) SetChain(ch preferences.Chain) error {
 
 	if !foundInChain {
 		// Add new chain
-		a.Preferences.User.Chains = append([]preferences.Chain{ch}, a.Preferences.User.Chains...)
+		user.Chains = append([]preferences.Chain{ch}, user.Chains...)
 	}
 
-	return preferences.SetUserPreferences(&a.Preferences.User)
+	return a.SetUserPreferences(&user)
 }
 
 func (a *App) CheckRPCStatus() (string, error) {
 	var lastErr error = fmt.Errorf("no RPCs configured")
 
-	for _, chain := range a.Preferences.User.Chains {
+	for _, chain := range a.Preferences.GetUser().Chains {
 		for _, rpc := range chain.RpcProviders {
 			if err := validation.ValidRPC(rpc); err == nil {
 				return rpc, nil
@@ -3,8 +3,10 @@ package main
 import (
 	"embed"
 	"fmt"
+	"os"
 
 	"github.com/TrueBlocks/trueblocks-codegen/app"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/cli"
 	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
 	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
 	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
@@ -18,7 +20,16 @@ import (
 var assets embed.FS
 
 func main() {
-	preferences.LoadIdentifiers(assets)
+	// The identity names the config folders, so the command line needs it too
+	if err := preferences.LoadIdentifiers(assets); err != nil {
+		fmt.Fprintf(os.Stderr, "Using the default identity: %v\n", err)
+	}
+
+	// A command on the command line runs headless instead of opening the window
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	a, menu := app.NewApp(assets)
 
 	opts := options.App{
@@ -0,0 +1,334 @@
+// package automation serves the app's operations as JSON-RPC on localhost so
+// other tools on the machine can drive it
+package automation
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
+)
+
+// ErrInvalidParams is returned by a method whose parameters are missing or wrong
+var ErrInvalidParams = errors.New("invalid params")
+
+// JSON-RPC 2.0 error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeServerError    = -32000
+)
+
+// Handler runs one method with its raw JSON-RPC params. The result is
+// serialized as JSON.
+type Handler func(params json.RawMessage) (interface{}, error)
+
+// Method adapts a function taking typed params to a Handler. Params that do
+// not decode into T are rejected with ErrInvalidParams.
+func Method[T any](fn func(params T) (interface{}, error)) Handler {
+	return func(raw json.RawMessage) (interface{}, error) {
+		var params T
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &params); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+			}
+		}
+		return fn(params)
+	}
+}
+
+// Server serves registered methods at /rpc and streams app events as
+// server-sent events at /events. Every request must carry the token.
+type Server struct {
+	token        string
+	methods      map[string]Handler
+	methodsMutex sync.RWMutex
+	server       *http.Server
+	listener     net.Listener
+	stopping     chan struct{} // Closed by Stop to end event streams
+	mutex        sync.Mutex
+}
+
+// NewServer creates a server that accepts requests carrying token
+func NewServer(token string) *Server {
+	return &Server{
+		token:   token,
+		methods: make(map[string]Handler),
+	}
+}
+
+// NewToken returns a random token for a new server
+func NewToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Register adds a method. It panics if the name is already registered.
+func (s *Server) Register(name string, handler Handler) {
+	s.methodsMutex.Lock()
+	defer s.methodsMutex.Unlock()
+	if _, exists := s.methods[name]; exists {
+		panic(fmt.Sprintf("automation: method %s registered twice", name))
+	}
+	s.methods[name] = handler
+}
+
+// Handler returns the server's routes, for tests or serving elsewhere. Its
+// event streams run until their clients disconnect.
+func (s *Server) Handler() http.Handler {
+	return s.routes(nil)
+}
+
+func (s *Server) routes(stopping <-chan struct{}) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rpc", s.handleRPC)
+	mux.HandleFunc("/events", handleEvents(stopping))
+	return s.authorize(mux)
+}
+
+// Start listens on 127.0.0.1 at port, or on a free port if port is zero
+func (s *Server) Start(port int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.server != nil {
+		return nil // Already running
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return fmt.Errorf("failed to start automation server: %w", err)
+	}
+	s.listener = listener
+	s.stopping = make(chan struct{})
+	s.server = &http.Server{
+		Handler:           s.routes(s.stopping),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("Automation server started at %s", s.url())
+	go func(server *http.Server) {
+		if err := server.Serve(listener); err != http.ErrServerClosed {
+			log.Printf("Automation server error: %v", err)
+		}
+	}(s.server)
+
+	return nil
+}
+
+// Stop shuts the server down, ending any event streams
+func (s *Server) Stop() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.server == nil {
+		return nil // Not running, nothing to do
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Event streams never finish on their own, so end them before waiting
+	close(s.stopping)
+	err := s.server.Shutdown(ctx)
+	s.server = nil
+	s.listener = nil
+	if err != nil {
+		return fmt.Errorf("error shutting down automation server: %w", err)
+	}
+	return nil
+}
+
+// URL returns the server's base URL, or "" if it is not running
+func (s *Server) URL() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.url()
+}
+
+func (s *Server) url() string {
+	if s.listener == nil {
+		return ""
+	}
+	return "http://" + s.listener.Addr().String()
+}
+
+// authorize rejects requests without the token, given as a bearer token or,
+// for event streams whose clients cannot set headers, as a query parameter
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if given == "" && r.URL.Path == "/events" {
+			given = r.URL.Query().Get("token")
+		}
+		if s.token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		next.ServeHTTP(w, r)
+	})
+}
+
+type rpcRequest struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type rpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+func (s *Server) handleRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req rpcRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeResponse(w, rpcResponse{Error: &rpcError{Code: CodeParseError, Message: err.Error()}})
+		return
+	}
+	if req.Version != "2.0" || req.Method == "" {
+		writeResponse(w, rpcResponse{ID: req.ID, Error: &rpcError{Code: CodeInvalidRequest, Message: "expected a JSON-RPC 2.0 request"}})
+		return
+	}
+
+	resp := rpcResponse{ID: req.ID}
+	if result, err := s.call(req.Method, req.Params); err != nil {
+		resp.Error = toRPCError(err)
+	} else {
+		resp.Result = result
+	}
+
+	// A request without an ID is a notification and gets no response
+	if len(req.ID) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeResponse(w, resp)
+}
+
+func (s *Server) call(name string, params json.RawMessage) (interface{}, error) {
+	s.methodsMutex.RLock()
+	handler, ok := s.methods[name]
+	s.methodsMutex.RUnlock()
+	if !ok {
+		return nil, errMethodNotFound{name}
+	}
+	return handler(params)
+}
+
+type errMethodNotFound struct {
+	name string
+}
+
+func (e errMethodNotFound) Error() string {
+	return "method not found: " + e.name
+}
+
+func toRPCError(err error) *rpcError {
+	var notFound errMethodNotFound
+	var invalid validation.ValidationError
+	switch {
+	case errors.As(err, &notFound):
+		return &rpcError{Code: CodeMethodNotFound, Message: err.Error()}
+	case errors.As(err, &invalid):
+		return &rpcError{Code: CodeInvalidParams, Message: err.Error(), Data: map[string]string{"field": invalid.Field, "problem": invalid.Problem}}
+	case errors.Is(err, ErrInvalidParams):
+		return &rpcError{Code: CodeInvalidParams, Message: err.Error()}
+	default:
+		return &rpcError{Code: CodeServerError, Message: err.Error()}
+	}
+}
+
+func writeResponse(w http.ResponseWriter, resp rpcResponse) {
+	resp.Version = "2.0"
+	if resp.ID == nil {
+		resp.ID = json.RawMessage("null")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// eventBuffer is how many events a slow client may fall behind before
+// further events are dropped for it
+const eventBuffer = 64
+
+type event struct {
+	Type msgs.EventType `json:"type"`
+	Text string         `json:"text"`
+}
+
+// handleEvents streams every app event until the client disconnects or
+// stopping is closed
+func handleEvents(stopping <-chan struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		streamEvents(w, r, stopping)
+	}
+}
+
+func streamEvents(w http.ResponseWriter, r *http.Request, stopping <-chan struct{}) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events := make(chan event, eventBuffer)
+	unsubscribe := msgs.Subscribe(func(messageType msgs.EventType, msgText string) {
+		select {
+		case events <- event{messageType, msgText}:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-stopping:
+			return
+		case e := <-events:
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package automation_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/automation"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
+)
+
+const testToken = "secret"
+
+type rpcResult struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code int `json:"code"`
+	} `json:"error"`
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	s := automation.NewServer(testToken)
+	s.Register("echo", automation.Method(func(params struct {
+		Text string `json:"text"`
+	}) (interface{}, error) {
+		if params.Text == "" {
+			return nil, validation.ValidationError{Field: "text", Problem: "cannot be empty"}
+		}
+		return params.Text, nil
+	}))
+	server := httptest.NewServer(s.Handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+func call(t *testing.T, server *httptest.Server, token, body string) (int, rpcResult) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/rpc", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result rpcResult
+	_ = json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func TestRPC(t *testing.T) {
+	server := newTestServer(t)
+
+	status, result := call(t, server, testToken, `{"jsonrpc": "2.0", "id": 1, "method": "echo", "params": {"text": "hello"}}`)
+	if status != http.StatusOK || result.Error != nil || string(result.Result) != `"hello"` {
+		t.Errorf("Expected the echoed text, got %d %s %+v", status, result.Result, result.Error)
+	}
+
+	cases := map[string]int{
+		`{"jsonrpc": "2.0", "id": 1, "method": "missing"}`:                     automation.CodeMethodNotFound,
+		`{"jsonrpc": "2.0", "id": 1, "method": "echo", "params": {"text": 1}}`: automation.CodeInvalidParams,
+		`{"jsonrpc": "2.0", "id": 1, "method": "echo", "params": {}}`:          automation.CodeInvalidParams,
+		`{"id": 1, "method": "echo"}`:                                          automation.CodeInvalidRequest,
+		`{not json`:                                                            automation.CodeParseError,
+	}
+	for body, code := range cases {
+		if _, result := call(t, server, testToken, body); result.Error == nil || result.Error.Code != code {
+			t.Errorf("%s: expected error %d, got %+v", body, code, result.Error)
+		}
+	}
+
+	if status, _ := call(t, server, testToken, `{"jsonrpc": "2.0", "method": "echo", "params": {"text": "quiet"}}`); status != http.StatusNoContent {
+		t.Errorf("Expected no content for a notification, got %d", status)
+	}
+}
+
+func TestRPCRequiresToken(t *testing.T) {
+	server := newTestServer(t)
+
+	if status, _ := call(t, server, "wrong", `{"jsonrpc": "2.0", "id": 1, "method": "echo"}`); status != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong token to be refused, got %d", status)
+	}
+	resp, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected events without a token to be refused, got %d", resp.StatusCode)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	server := newTestServer(t)
+
+	resp, err := http.Get(server.URL + "/events?token=" + testToken)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	// The stream is subscribed once its headers arrive
+	msgs.EmitStatus("streamed")
+
+	lines := make(chan string, 16)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("Stream ended before the event arrived")
+			}
+			if strings.HasPrefix(line, "data: ") && strings.Contains(line, "streamed") {
+				return
+			}
+		case <-timeout:
+			t.Fatal("Timed out waiting for the event")
+		}
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	s := automation.NewServer(testToken)
+	if err := s.Start(0); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	url := s.URL()
+	if !strings.HasPrefix(url, "http://127.0.0.1:") {
+		t.Errorf("Expected the server to bind to localhost, got %s", url)
+	}
+
+	resp, err := http.Get(url + "/events?token=" + testToken)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	done := make(chan error)
+	go func() { done <- s.Stop() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Stop failed: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Stop should not wait for open event streams")
+	}
+	if s.URL() != "" {
+		t.Error("A stopped server should have no URL")
+	}
+}
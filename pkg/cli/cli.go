@@ -0,0 +1,132 @@
+// package cli runs the codegen binary without a window, for scripts and CI
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	_ "github.com/TrueBlocks/trueblocks-codegen/pkg/dalle" // registers its project section
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	_ "github.com/TrueBlocks/trueblocks-codegen/pkg/openai" // registers its project section
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+)
+
+// Exit codes returned by Run
+const (
+	ExitOK       = 0 // The command succeeded
+	ExitFailure  = 1 // The command failed
+	ExitUsage    = 2 // The command line was wrong
+	ExitNotFound = 3 // A file, field or name the command needed does not exist
+	ExitDamaged  = 4 // A project file was damaged; any recovered copy was used
+)
+
+// ErrUsage is returned when a command is given the wrong arguments
+var ErrUsage = errors.New("wrong arguments")
+
+// ErrNotFound is returned when something a command needs does not exist
+var ErrNotFound = errors.New("not found")
+
+// command is one subcommand, such as "project new"
+type command struct {
+	usage string
+	run   func(env *env, args []string) error
+}
+
+// env carries the output streams through a command
+type env struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (e *env) printf(format string, args ...interface{}) {
+	fmt.Fprintf(e.stdout, format, args...)
+}
+
+var commands = map[string]map[string]command{
+	"project": {
+		"new":    {"project new [-name NAME] [-template ID] [-force] PATH", projectNew},
+		"info":   {"project info PATH", projectInfo},
+		"export": {"project export PATH BUNDLE", projectExport},
+	},
+	"images": {
+		"generate": {"images generate -series SERIES -file NAME [-enhance] [-project PATH] PROMPT", imagesGenerate},
+	},
+	"prefs": {
+		"get": {"prefs get app|user|org [FIELD]", prefsGet},
+		"set": {"prefs set app|user|org FIELD VALUE", prefsSet},
+	},
+	"names": {
+		"search": {"names search TERM...", namesSearch},
+	},
+}
+
+// IsCommand reports whether name is a command group Run handles, which is
+// how main tells a headless invocation from launching the window
+func IsCommand(name string) bool {
+	_, ok := commands[name]
+	return ok || name == "help"
+}
+
+// Run executes the command in args, printing results and app events to stdout
+// and errors to stderr, and returns the process exit code
+func Run(args []string, stdout, stderr io.Writer) int {
+	e := &env{stdout: stdout, stderr: stderr}
+	msgs.SetEmitter(func(messageType msgs.EventType, msgText string) {
+		e.printf("[%s] %s\n", messageType, msgText)
+	})
+
+	if len(args) < 2 || args[0] == "help" {
+		printUsage(stderr)
+		if len(args) > 0 && args[0] == "help" {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	cmd, ok := commands[args[0]][args[1]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command: %s\n", strings.Join(args[:2], " "))
+		printUsage(stderr)
+		return ExitUsage
+	}
+
+	if err := cmd.run(e, args[2:]); err != nil {
+		fmt.Fprintf(stderr, "Error: %s\n", err)
+		var damaged *project.CorruptionError
+		switch {
+		case errors.Is(err, ErrUsage):
+			fmt.Fprintf(stderr, "usage: %s\n", cmd.usage)
+			return ExitUsage
+		case errors.Is(err, ErrNotFound):
+			return ExitNotFound
+		case errors.As(err, &damaged):
+			return ExitDamaged
+		default:
+			return ExitFailure
+		}
+	}
+	return ExitOK
+}
+
+func printUsage(w io.Writer) {
+	usages := []string{}
+	for _, group := range commands {
+		for _, cmd := range group {
+			usages = append(usages, cmd.usage)
+		}
+	}
+	sort.Strings(usages)
+
+	fmt.Fprintln(w, "usage:")
+	for _, usage := range usages {
+		fmt.Fprintf(w, "  %s\n", usage)
+	}
+}
+
+// usageError reports a wrong command line
+func usageError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrUsage, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,133 @@
+package cli_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/cli"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+)
+
+func run(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := cli.Run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestUsage(t *testing.T) {
+	if code, _, _ := run(t); code != cli.ExitUsage {
+		t.Errorf("Expected ExitUsage with no command, got %d", code)
+	}
+	if code, _, _ := run(t, "project", "rename"); code != cli.ExitUsage {
+		t.Errorf("Expected ExitUsage for an unknown subcommand, got %d", code)
+	}
+	if code, _, stderr := run(t, "project", "info"); code != cli.ExitUsage || !strings.Contains(stderr, "project info PATH") {
+		t.Errorf("Expected ExitUsage and the command's usage, got %d: %s", code, stderr)
+	}
+	if code, _, _ := run(t, "help"); code != cli.ExitOK {
+		t.Errorf("Expected help to succeed, got %d", code)
+	}
+	if !cli.IsCommand("project") || cli.IsCommand("-psn_0_12345") {
+		t.Error("IsCommand should only accept command groups")
+	}
+}
+
+func TestProjectCommands(t *testing.T) {
+	defer preferences.SetConfigBaseForTest(t, t.TempDir())()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "demo.json")
+
+	if code, _, stderr := run(t, "project", "new", "-template", "notebook", path); code != cli.ExitOK {
+		t.Fatalf("project new failed with %d: %s", code, stderr)
+	}
+	if code, _, _ := run(t, "project", "new", path); code != cli.ExitFailure {
+		t.Errorf("Expected an existing file to be refused, got %d", code)
+	}
+
+	code, stdout, stderr := run(t, "project", "info", path)
+	if code != cli.ExitOK {
+		t.Fatalf("project info failed with %d: %s", code, stderr)
+	}
+	var info struct {
+		Name     string   `json:"name"`
+		Sections []string `json:"sections"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &info); err != nil {
+		t.Fatalf("Expected JSON from project info: %v\n%s", err, stdout)
+	}
+	if info.Name != "demo" || len(info.Sections) == 0 {
+		t.Errorf("Expected the demo project with notebook sections, got %+v", info)
+	}
+
+	bundle := filepath.Join(dir, "demo.zip")
+	if code, _, stderr := run(t, "project", "export", path, bundle); code != cli.ExitOK {
+		t.Fatalf("project export failed with %d: %s", code, stderr)
+	}
+	if _, err := os.Stat(bundle); err != nil {
+		t.Errorf("Expected the bundle to be written: %v", err)
+	}
+
+	if code, _, _ := run(t, "project", "info", filepath.Join(dir, "missing.json")); code != cli.ExitNotFound {
+		t.Errorf("Expected ExitNotFound for a missing project, got %d", code)
+	}
+	if code, _, _ := run(t, "project", "new", "-template", "nonsense", filepath.Join(dir, "other.json")); code != cli.ExitNotFound {
+		t.Errorf("Expected ExitNotFound for an unknown template, got %d", code)
+	}
+}
+
+func TestProjectInfoReportsDamage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "demo.json")
+	if code, _, _ := run(t, "project", "new", path); code != cli.ExitOK {
+		t.Fatal("project new failed")
+	}
+	if code, _, _ := run(t, "project", "new", "-force", "-name", "Second", path); code != cli.ExitOK {
+		t.Fatal("project new -force failed")
+	}
+
+	data, _ := os.ReadFile(path)
+	if err := os.WriteFile(path, bytes.Replace(data, []byte("Second"), []byte("Sec0nd"), 1), 0644); err != nil {
+		t.Fatalf("Failed to damage the file: %v", err)
+	}
+
+	code, stdout, _ := run(t, "project", "info", path)
+	if code != cli.ExitDamaged {
+		t.Errorf("Expected ExitDamaged, got %d", code)
+	}
+	if !strings.Contains(stdout, `"name": "demo"`) {
+		t.Errorf("Expected the recovered project to be described, got %s", stdout)
+	}
+}
+
+func TestPrefsCommands(t *testing.T) {
+	defer preferences.SetConfigBaseForTest(t, t.TempDir())()
+
+	if code, _, stderr := run(t, "prefs", "set", "user", "theme", "light"); code != cli.ExitOK {
+		t.Fatalf("prefs set failed with %d: %s", code, stderr)
+	}
+	if code, stdout, _ := run(t, "prefs", "get", "user", "theme"); code != cli.ExitOK || stdout != "light\n" {
+		t.Errorf("Expected the theme back bare, got %d: %q", code, stdout)
+	}
+
+	if code, _, stderr := run(t, "prefs", "set", "org", "backupCount", "3"); code != cli.ExitOK {
+		t.Fatalf("prefs set failed with %d: %s", code, stderr)
+	}
+	org, _ := preferences.GetOrgPreferences()
+	if org.BackupCount != 3 {
+		t.Errorf("Expected backupCount 3 to be saved, got %d", org.BackupCount)
+	}
+
+	if code, _, _ := run(t, "prefs", "set", "org", "backupCount", "many"); code != cli.ExitUsage {
+		t.Errorf("Expected ExitUsage for a value of the wrong type, got %d", code)
+	}
+	if code, _, _ := run(t, "prefs", "get", "app", "nonsense"); code != cli.ExitNotFound {
+		t.Errorf("Expected ExitNotFound for an unknown field, got %d", code)
+	}
+	if code, _, _ := run(t, "prefs", "get", "team"); code != cli.ExitUsage {
+		t.Errorf("Expected ExitUsage for unknown preferences, got %d", code)
+	}
+}
@@ -0,0 +1,98 @@
+// package cli runs the codegen binary without a window, for scripts and CI
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/openai"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/secrets"
+)
+
+// ErrNoAPIKey is returned when an image is requested without an OpenAI key
+var ErrNoAPIKey = openai.ErrNoAPIKey
+
+func imagesGenerate(e *env, args []string) error {
+	flags := flag.NewFlagSet("images generate", flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+	series := flags.String("series", "", "series the image belongs to")
+	filename := flags.String("file", "", "file name of the image, without extension")
+	terse := flags.String("caption", "", "caption written on the image, defaults to the prompt")
+	enhance := flags.Bool("enhance", false, "have the prompt improved before generating")
+	projectPath := flags.String("project", "", "project file to record the image in")
+	if err := flags.Parse(args); err != nil {
+		return usageError("%v", err)
+	}
+	if *series == "" || *filename == "" || flags.NArg() == 0 {
+		return usageError("expected -series, -file and a PROMPT")
+	}
+	names := openai.ImageData{SeriesName: *series, Filename: *filename}
+	if err := names.Validate(); err != nil {
+		return usageError("%v", err)
+	}
+
+	// The project is loaded first so a bad path fails before the image is paid for
+	var p *project.Project
+	if *projectPath != "" {
+		var err error
+		if p, err = loadProject(*projectPath); err != nil {
+			return err
+		}
+	}
+
+	if err := openKeystore(); err != nil {
+		return err
+	}
+
+	prompt := strings.Join(flags.Args(), " ")
+	imageData := openai.ImageData{
+		EnhancedPrompt: prompt,
+		TersePrompt:    prompt,
+		SeriesName:     *series,
+		Filename:       *filename,
+	}
+	if *terse != "" {
+		imageData.TersePrompt = *terse
+	}
+	if *enhance {
+		enhanced, err := openai.EnhancePrompt(prompt, "")
+		if err != nil {
+			return err
+		}
+		imageData.EnhancedPrompt = enhanced
+	}
+
+	if err := openai.RequestImage(&imageData); err != nil {
+		return err
+	}
+	if p != nil {
+		if err := openai.AddImage(p, &imageData); err != nil {
+			return err
+		}
+		if err := p.Save(); err != nil {
+			return err
+		}
+	}
+	e.printf("%s\n", filepath.Join("output", *series, "annotated", *filename+".png"))
+	return nil
+}
+
+// openKeystore opens the keystore the OpenAI key is read from. A passphrase
+// protected keystore is unlocked with the passphrase in the environment, and
+// a key in the environment is moved into the keystore as the app would.
+func openKeystore() error {
+	_, appFolder := preferences.GetConfigFolders()
+	store, err := secrets.OpenDefault(appFolder, preferences.EnvName("passphrase"))
+	if err != nil {
+		return err
+	}
+	if store.Locked() {
+		return fmt.Errorf("%w: set %s to unlock it", secrets.ErrLocked, preferences.EnvName("passphrase"))
+	}
+	_, err = secrets.ImportEnv(store, openai.APIKeyName)
+	return err
+}
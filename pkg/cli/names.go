@@ -0,0 +1,34 @@
+// package cli runs the codegen binary without a window, for scripts and CI
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	sdk "github.com/TrueBlocks/trueblocks-sdk/v5"
+)
+
+func namesSearch(e *env, args []string) error {
+	if len(args) == 0 {
+		return usageError("expected at least one TERM")
+	}
+
+	opts := sdk.NamesOptions{
+		Terms: args,
+	}
+	names, _, err := opts.Names()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("%w: no names match %s", ErrNotFound, strings.Join(args, " "))
+	}
+
+	data, err := json.MarshalIndent(names, "", "  ")
+	if err != nil {
+		return err
+	}
+	e.printf("%s\n", data)
+	return nil
+}
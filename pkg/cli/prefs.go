@@ -0,0 +1,134 @@
+// package cli runs the codegen binary without a window, for scripts and CI
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/secrets"
+)
+
+// prefsFile reads and writes one of the preference files as a pointer to its struct
+type prefsFile struct {
+	load func() (interface{}, error)
+	save func(interface{}) error
+}
+
+var prefsFiles = map[string]prefsFile{
+	"app": {
+		load: func() (interface{}, error) {
+			prefs, err := preferences.GetAppPreferences()
+			return &prefs, err
+		},
+		save: func(v interface{}) error { return preferences.SetAppPreferences(v.(*preferences.AppPreferences)) },
+	},
+	"user": {
+		load: func() (interface{}, error) {
+			// The same profile the app would start in
+			if err := preferences.UseLastProfile(); err != nil {
+				return nil, err
+			}
+			// RPC credentials are kept in the keystore. Without the passphrase
+			// it stays locked and only URLs without credentials can be saved.
+			if err := openKeystore(); err != nil && !errors.Is(err, secrets.ErrLocked) {
+				return nil, err
+			}
+			prefs, err := preferences.GetUserPreferences()
+			return &prefs, err
+		},
+		save: func(v interface{}) error { return preferences.SetUserPreferences(v.(*preferences.UserPreferences)) },
+	},
+	"org": {
+		load: func() (interface{}, error) {
+			prefs, err := preferences.GetOrgPreferences()
+			return &prefs, err
+		},
+		save: func(v interface{}) error { return preferences.SetOrgPreferences(v.(*preferences.OrgPreferences)) },
+	},
+}
+
+func loadPrefs(which string) (prefsFile, interface{}, error) {
+	file, ok := prefsFiles[which]
+	if !ok {
+		return prefsFile{}, nil, usageError("unknown preferences %q, expected app, user or org", which)
+	}
+	prefs, err := file.load()
+	if err != nil {
+		return prefsFile{}, nil, err
+	}
+	return file, prefs, nil
+}
+
+// prefsField returns the field of the struct prefs points to whose JSON name is name
+func prefsField(prefs interface{}, name string) (reflect.Value, error) {
+	v := reflect.ValueOf(prefs).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		tag := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+		if tag == name {
+			return v.Field(i), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("%w: no preference named %s", ErrNotFound, name)
+}
+
+func prefsGet(e *env, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return usageError("expected app, user or org and an optional FIELD")
+	}
+
+	_, prefs, err := loadPrefs(args[0])
+	if err != nil {
+		return err
+	}
+
+	value := prefs
+	if len(args) == 2 {
+		field, err := prefsField(prefs, args[1])
+		if err != nil {
+			return err
+		}
+		value = field.Interface()
+	}
+
+	// Plain strings print bare so scripts can use them directly
+	if s, ok := value.(string); ok {
+		e.printf("%s\n", s)
+		return nil
+	}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	e.printf("%s\n", data)
+	return nil
+}
+
+func prefsSet(e *env, args []string) error {
+	if len(args) != 3 {
+		return usageError("expected app, user or org, a FIELD and a VALUE")
+	}
+
+	file, prefs, err := loadPrefs(args[0])
+	if err != nil {
+		return err
+	}
+	field, err := prefsField(prefs, args[1])
+	if err != nil {
+		return err
+	}
+
+	// VALUE is JSON, except that a string field takes it as written
+	target := reflect.New(field.Type())
+	if field.Kind() == reflect.String {
+		target.Elem().SetString(args[2])
+	} else if err := json.Unmarshal([]byte(args[2]), target.Interface()); err != nil {
+		return usageError("%s expects a %s value: %v", args[1], field.Type(), err)
+	}
+	field.Set(target.Elem())
+
+	return file.save(prefs)
+}
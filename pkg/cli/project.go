@@ -0,0 +1,141 @@
+// package cli runs the codegen binary without a window, for scripts and CI
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/preferences"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+)
+
+// projectSummary is what "project info" prints
+type projectSummary struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Version     string            `json:"version"`
+	Path        string            `json:"path"`
+	LastOpened  string            `json:"lastOpened"`
+	Preferences map[string]string `json:"preferences"`
+	Sections    []string          `json:"sections"`
+	Backups     int               `json:"backups"`
+}
+
+// templatesFolder is the user templates folder, the same one the app uses
+func templatesFolder() string {
+	_, appFolder := preferences.GetConfigFolders()
+	return filepath.Join(appFolder, "templates")
+}
+
+func projectNew(e *env, args []string) error {
+	flags := flag.NewFlagSet("project new", flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+	name := flags.String("name", "", "project name, defaults to the file name")
+	templateID := flags.String("template", "", "template to create the project from")
+	force := flags.Bool("force", false, "overwrite an existing file")
+	if err := flags.Parse(args); err != nil {
+		return usageError("%v", err)
+	}
+	if flags.NArg() != 1 {
+		return usageError("expected one PATH")
+	}
+
+	path := flags.Arg(0)
+	if _, err := os.Stat(path); err == nil && !*force {
+		return fmt.Errorf("%s already exists, use -force to overwrite it", path)
+	}
+	if *name == "" {
+		base := filepath.Base(path)
+		*name = base[:len(base)-len(filepath.Ext(base))]
+	}
+
+	m := project.NewManager()
+	if *templateID == "" {
+		m.New(*name)
+	} else {
+		template, err := project.FindTemplate(templatesFolder(), *templateID)
+		if errors.Is(err, project.ErrUnknownTemplate) {
+			return fmt.Errorf("%w: %v", ErrNotFound, err)
+		} else if err != nil {
+			return err
+		}
+		if _, err := m.NewFromTemplate(*name, template, filepath.Dir(path)); err != nil {
+			return err
+		}
+	}
+
+	if err := m.SaveActiveAs(path); err != nil {
+		return err
+	}
+	e.printf("%s\n", path)
+	return nil
+}
+
+// loadProject loads the project at path. A damaged file that could be
+// recovered returns the recovered project along with its *CorruptionError.
+func loadProject(path string) (*project.Project, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	return project.Load(path)
+}
+
+func projectInfo(e *env, args []string) error {
+	if len(args) != 1 {
+		return usageError("expected one PATH")
+	}
+
+	// A recovered project is still described, then the damage is reported
+	p, err := loadProject(args[0])
+	if p == nil {
+		return err
+	}
+
+	sections := make([]string, 0, len(p.Data))
+	for key := range p.Data {
+		sections = append(sections, key)
+	}
+	sort.Strings(sections)
+
+	backups, _ := project.ListBackups(args[0])
+	summary := projectSummary{
+		ID:          p.GetID(),
+		Name:        p.GetName(),
+		Version:     p.Version,
+		Path:        p.GetPath(),
+		LastOpened:  p.LastOpened,
+		Preferences: p.Preferences,
+		Sections:    sections,
+		Backups:     len(backups),
+	}
+	data, _ := json.MarshalIndent(summary, "", "  ")
+	e.printf("%s\n", data)
+	return err
+}
+
+func projectExport(e *env, args []string) error {
+	if len(args) != 2 {
+		return usageError("expected PATH and BUNDLE")
+	}
+
+	p, err := loadProject(args[0])
+	if err != nil {
+		// Exporting a recovered copy would silently pass on the loss
+		return err
+	}
+
+	manifest, err := project.ExportBundle(p, args[1])
+	if err != nil {
+		return err
+	}
+	for _, missing := range manifest.Missing {
+		e.printf("missing: %s\n", missing)
+	}
+	e.printf("%s: %d files\n", args[1], len(manifest.Files))
+	return nil
+}
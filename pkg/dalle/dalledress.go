@@ -9,6 +9,7 @@ import (
 	"sync"
 	"text/template"
 
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/secrets"
 	"github.com/TrueBlocks/trueblocks-core/src/apps/chifra/pkg/file"
 	"github.com/TrueBlocks/trueblocks-core/src/apps/chifra/pkg/logger"
 )
@@ -28,7 +29,7 @@ type DalleDress struct {
 
 func (d *DalleDress) String() string {
 	jsonData, _ := json.MarshalIndent(d, "", "  ")
-	return string(jsonData)
+	return secrets.Redact(string(jsonData))
 }
 
 func (dd *DalleDress) ExecuteTemplate(t *template.Template, f func(s string) string) (string, error) {
@@ -0,0 +1,52 @@
+package dalle
+
+import (
+	"fmt"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
+)
+
+// SeriesSection is the key under which a project stores its DalleDress series
+const SeriesSection = "series"
+
+func init() {
+	project.RegisterSection(project.Section[[]Series]{
+		Key:      SeriesSection,
+		Validate: validateSeries,
+	})
+	project.RegisterAssetCollector(SeriesSection, collectSeries)
+}
+
+// validateSeries ensures every series in a project has a unique, non-empty suffix
+func validateSeries(series []Series) error {
+	seen := make(map[string]bool, len(series))
+	for _, s := range series {
+		if s.Suffix == "" {
+			return validation.ValidationError{Field: "series", Problem: "suffix cannot be empty"}
+		}
+		if seen[s.Suffix] {
+			return validation.ValidationError{Field: "series", Problem: fmt.Sprintf("duplicate suffix %s", s.Suffix)}
+		}
+		seen[s.Suffix] = true
+	}
+	return nil
+}
+
+// collectSeries bundles each of the project's series as the JSON file
+// SaveSeries would write for it
+func collectSeries(p *project.Project) ([]project.BundleAsset, error) {
+	series, err := project.GetSection[[]Series](p, SeriesSection)
+	if err != nil {
+		return nil, err
+	}
+
+	assets := make([]project.BundleAsset, 0, len(series))
+	for i := range series {
+		assets = append(assets, project.BundleAsset{
+			Name: "output/series/" + series[i].Suffix + ".json",
+			Data: []byte(series[i].String()),
+		})
+	}
+	return assets, nil
+}
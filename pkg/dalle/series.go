@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/secrets"
 	"github.com/TrueBlocks/trueblocks-core/src/apps/chifra/pkg/file"
 )
 
@@ -27,7 +28,7 @@ type Series struct {
 
 func (s *Series) String() string {
 	bytes, _ := json.MarshalIndent(s, "", "  ")
-	return string(bytes)
+	return secrets.Redact(string(bytes))
 }
 
 func (s *Series) SaveSeries(fn string, last int) {
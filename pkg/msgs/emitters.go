@@ -26,6 +26,32 @@ func EmitManager(reason string) {
 	EmitMessage(EventManager, reason)
 }
 
+// EmitRecovery announces projects that can be restored from autosave. The
+// message is a JSON array describing each one.
+func EmitRecovery(entries string) {
+	EmitMessage(EventRecovery, entries)
+}
+
+// EmitFileChanged announces that the file of the open project with the given
+// ID was modified by another program
+func EmitFileChanged(id string) {
+	EmitMessage(EventFileChanged, id)
+}
+
+// EmitFileDamaged announces that a project file was damaged when opened. The
+// message is a JSON object saying what was recovered and what was lost.
+func EmitFileDamaged(report string) {
+	EmitMessage(EventFileDamaged, report)
+}
+
+// EmitPreferencesChanged announces that preferences were reloaded, because
+// another program changed their files or the user switched profiles. The
+// message is a JSON array naming the sections that changed, such as "org" or
+// "user".
+func EmitPreferencesChanged(sections string) {
+	EmitMessage(EventPreferencesChanged, sections)
+}
+
 func EmitAppInit() {
 	EmitMessage(EventAppInit, "")
 }
@@ -10,6 +10,9 @@ const (
 
 	EventManager         EventType = "manager:change"
 	EventProjectsUpdated EventType = "projects:updated"
+	EventRecovery        EventType = "projects:recovery"
+	EventFileChanged     EventType = "projects:file-changed"
+	EventFileDamaged     EventType = "projects:file-damaged"
 
 	EventAppInit    EventType = "app:initialized"
 	EventAppReady   EventType = "app:ready"
@@ -18,6 +21,8 @@ const (
 	EventTabCycle EventType = "hotkey:tab-cycle"
 
 	EventImagesChanged EventType = "images:changed"
+
+	EventPreferencesChanged EventType = "preferences:changed"
 )
 
 var AllMessages = []struct {
@@ -28,10 +33,14 @@ var AllMessages = []struct {
 	{EventError, "ERROR"},
 	{EventManager, "MANAGER"},
 	{EventProjectsUpdated, "PROJECTS_UPDATED"},
+	{EventRecovery, "RECOVERY"},
+	{EventFileChanged, "FILE_CHANGED"},
+	{EventFileDamaged, "FILE_DAMAGED"},
 	{EventAppInit, "APP_INIT"},
 	{EventAppReady, "APP_READY"},
 	{EventViewChange, "VIEW_CHANGE"},
 	{EventVersion, "VERSION"},
 	{EventTabCycle, "TAB_CYCLE"},
 	{EventImagesChanged, "IMAGES_CHANGED"},
+	{EventPreferencesChanged, "PREFERENCES_CHANGED"},
 }
@@ -5,6 +5,7 @@ import (
 	"log"
 	"sync"
 
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/secrets"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
@@ -55,9 +56,40 @@ func DisableLogging() {
 	currentEmitter = defaultEmitter
 }
 
+var (
+	listeners      = make(map[int]EventEmitter)
+	nextListener   int
+	listenersMutex sync.RWMutex
+)
+
+// Subscribe adds a listener that receives every message alongside the
+// emitter. Listeners are called on the emitting goroutine and must not block.
+// It returns a function that removes the listener.
+func Subscribe(listener EventEmitter) func() {
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+	id := nextListener
+	nextListener++
+	listeners[id] = listener
+	return func() {
+		listenersMutex.Lock()
+		defer listenersMutex.Unlock()
+		delete(listeners, id)
+	}
+}
+
 // EmitMessage emits a message using the stored context
 func EmitMessage(messageType EventType, msgText string) {
+	// Nothing that leaves through an event may carry a secret
+	msgText = secrets.Redact(msgText)
+
 	if currentEmitter != nil {
 		currentEmitter(messageType, msgText)
 	}
+
+	listenersMutex.RLock()
+	defer listenersMutex.RUnlock()
+	for _, listener := range listeners {
+		listener(messageType, msgText)
+	}
 }
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
 	"github.com/TrueBlocks/trueblocks-core/src/apps/chifra/pkg/colors"
 	"github.com/TrueBlocks/trueblocks-core/src/apps/chifra/pkg/file"
 	"github.com/TrueBlocks/trueblocks-core/src/apps/chifra/pkg/logger"
@@ -24,7 +25,24 @@ type ImageData struct {
 	Filename       string `json:"filename"`
 }
 
+// Validate returns a validation.ValidationError unless the series and file
+// names stay inside the output folder they are joined to
+func (d *ImageData) Validate() error {
+	if err := validation.ValidFileName("series", d.SeriesName); err != nil {
+		return err
+	}
+	return validation.ValidFileName("file", d.Filename)
+}
+
 func RequestImage(imageData *ImageData) error {
+	if err := imageData.Validate(); err != nil {
+		return err
+	}
+	apiKey, err := lookupAPIKey()
+	if err != nil {
+		return err
+	}
+
 	generated := filepath.Join("./output", imageData.SeriesName, "generated")
 	_ = file.EstablishFolder(generated)
 	annotated := strings.Replace(generated, "/generated", "/annotated", -1)
@@ -60,11 +78,6 @@ func RequestImage(imageData *ImageData) error {
 		panic(err)
 	}
 
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		logger.Fatal("No OPENAI_API_KEY key found")
-	}
-
 	logger.Info(colors.Cyan, imageData.Filename, colors.Yellow, "- generating the image...", colors.Off)
 
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payloadBytes))
@@ -1,6 +1,32 @@
 package openai
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/secrets"
+)
+
+// APIKeyName names the OpenAI key in the keystore
+const APIKeyName = "OPENAI_API_KEY"
+
+// ErrNoAPIKey is returned when the keystore holds no OpenAI key
+var ErrNoAPIKey = errors.New("no OPENAI_API_KEY key found")
+
+// lookupAPIKey returns the OpenAI key from the default keystore
+func lookupAPIKey() (string, error) {
+	key, ok := secrets.Lookup(APIKeyName)
+	if !ok {
+		return "", ErrNoAPIKey
+	}
+	return key, nil
+}
+
+// HasAPIKey reports whether an OpenAI key is available to make requests with
+func HasAPIKey() bool {
+	_, err := lookupAPIKey()
+	return err == nil
+}
 
 type message struct {
 	Role    string `json:"role"`
@@ -22,7 +48,7 @@ type dalleRequest struct {
 
 func (req *dalleRequest) String() string {
 	bytes, _ := json.MarshalIndent(req, "", "  ")
-	return string(bytes)
+	return secrets.Redact(string(bytes))
 }
 
 type dalleResponse1 struct {
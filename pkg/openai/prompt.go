@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
-	"os"
 )
 
 func EnhancePrompt(prompt, authorType string) (string, error) {
@@ -21,12 +20,16 @@ func EnhancePrompt(prompt, authorType string) (string, error) {
 		return "", err
 	}
 
+	apiKey, err := lookupAPIKey()
+	if err != nil {
+		return "", err
+	}
+
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payloadBytes))
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	apiKey := os.Getenv("OPENAI_API_KEY")
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 
 	client := &http.Client{}
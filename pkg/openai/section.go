@@ -0,0 +1,115 @@
+package openai
+
+import (
+	"path/filepath"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
+)
+
+// ImagesSection is the key under which a project stores references to its generated images
+const ImagesSection = "images"
+
+// ImageRef points at an image generated by RequestImage
+type ImageRef struct {
+	SeriesName string `json:"seriesName"`
+	Filename   string `json:"filename"`
+	Generated  string `json:"generated"`
+	Annotated  string `json:"annotated,omitempty"`
+}
+
+func init() {
+	project.RegisterSection(project.Section[[]ImageRef]{
+		Key:      ImagesSection,
+		Validate: validateImageRefs,
+		Paths:    moveImageRefs,
+	})
+	project.RegisterAssetCollector(ImagesSection, collectImages)
+}
+
+func validateImageRefs(refs []ImageRef) error {
+	for _, ref := range refs {
+		if ref.Filename == "" {
+			return validation.ValidationError{Field: "image", Problem: "filename cannot be empty"}
+		}
+		if ref.Generated == "" {
+			return validation.ValidationError{Field: "image", Problem: "generated path cannot be empty"}
+		}
+	}
+	return nil
+}
+
+// moveImageRefs passes the generated and annotated paths of every image through move
+func moveImageRefs(refs []ImageRef, move func(path string) string) []ImageRef {
+	for i := range refs {
+		refs[i].Generated = move(refs[i].Generated)
+		if refs[i].Annotated != "" {
+			refs[i].Annotated = move(refs[i].Annotated)
+		}
+	}
+	return refs
+}
+
+// collectImages bundles the generated and annotated files of every image the
+// project refers to. Relative paths are kept and absolute ones are stored by
+// series, and the bundled references are rewritten to match.
+func collectImages(p *project.Project) ([]project.BundleAsset, error) {
+	refs, err := project.GetSection[[]ImageRef](p, ImagesSection)
+	if err != nil {
+		return nil, err
+	}
+
+	assets := []project.BundleAsset{}
+	for _, ref := range refs {
+		for _, kind := range []string{"generated", "annotated"} {
+			path := ref.Generated
+			if kind == "annotated" {
+				path = ref.Annotated
+			}
+			if path == "" {
+				continue
+			}
+			name := filepath.ToSlash(path)
+			if filepath.IsAbs(path) {
+				name = "images/" + ref.SeriesName + "/" + kind + "/" + filepath.Base(path)
+			}
+			assets = append(assets, project.BundleAsset{Name: name, Source: path})
+		}
+	}
+	return assets, nil
+}
+
+// AddImage records an image made by RequestImage in the project, replacing an
+// earlier image with the same series and filename. The paths are stored
+// absolute so they do not depend on the folder the app was started in.
+func AddImage(p *project.Project, imageData *ImageData) error {
+	if err := imageData.Validate(); err != nil {
+		return err
+	}
+	generated, err := filepath.Abs(filepath.Join("output", imageData.SeriesName, "generated", imageData.Filename+".png"))
+	if err != nil {
+		return err
+	}
+	annotated, err := filepath.Abs(filepath.Join("output", imageData.SeriesName, "annotated", imageData.Filename+".png"))
+	if err != nil {
+		return err
+	}
+
+	refs, err := project.GetSection[[]ImageRef](p, ImagesSection)
+	if err != nil {
+		return err
+	}
+	ref := ImageRef{
+		SeriesName: imageData.SeriesName,
+		Filename:   imageData.Filename,
+		Generated:  generated,
+		Annotated:  annotated,
+	}
+	for i := range refs {
+		if refs[i].SeriesName == ref.SeriesName && refs[i].Filename == ref.Filename {
+			refs[i] = ref
+			return p.SetSection(ImagesSection, refs)
+		}
+	}
+	return p.SetSection(ImagesSection, append(refs, ref))
+}
@@ -0,0 +1,97 @@
+package openai
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+)
+
+// writePNG writes a small image to a file of its own and returns its contents
+func writePNG(t *testing.T, path string) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 2, color.RGBA{R: 200, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImagesBundleRoundTrip(t *testing.T) {
+	work := t.TempDir()
+	generated := filepath.Join(work, "output", "demo", "generated", "first.png")
+	annotated := filepath.Join(work, "output", "demo", "annotated", "first.png")
+	contents := writePNG(t, generated)
+	writePNG(t, annotated)
+
+	p := project.New("Images")
+	refs := []ImageRef{{SeriesName: "demo", Filename: "first", Generated: generated, Annotated: annotated}}
+	if err := p.SetSection(ImagesSection, refs); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := filepath.Join(t.TempDir(), "images.zip")
+	if _, err := project.ExportBundle(p, bundle); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	// The source folder is gone, as it would be on another machine
+	if err := os.RemoveAll(work); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := t.TempDir()
+	imported, _, err := project.ImportBundle(bundle, filepath.Join(dest, "images.json"))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	got, err := project.GetSection[[]ImageRef](imported, ImagesSection)
+	if err != nil || len(got) != 1 {
+		t.Fatalf("Expected one image back, got %+v (%v)", got, err)
+	}
+
+	expected := filepath.Join(dest, "images", "demo", "generated", "first.png")
+	if got[0].Generated != expected {
+		t.Errorf("Expected the reference to resolve next to the project, got %s", got[0].Generated)
+	}
+	data, err := os.ReadFile(got[0].Generated)
+	if err != nil || !bytes.Equal(data, contents) {
+		t.Fatalf("Expected the bundled image at %s: %v", got[0].Generated, err)
+	}
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Errorf("Expected a readable image: %v", err)
+	}
+	if got[0].Annotated == "" || got[0].Annotated == annotated {
+		t.Errorf("Expected the annotated reference to be rewritten, got %s", got[0].Annotated)
+	}
+}
+
+func TestAddImageReplacesSameFile(t *testing.T) {
+	p := project.New("Images")
+	imageData := ImageData{SeriesName: "demo", Filename: "first"}
+	if err := AddImage(p, &imageData); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddImage(p, &imageData); err != nil {
+		t.Fatal(err)
+	}
+
+	refs, _ := project.GetSection[[]ImageRef](p, ImagesSection)
+	if len(refs) != 1 || !filepath.IsAbs(refs[0].Generated) {
+		t.Errorf("Expected one image with an absolute path, got %+v", refs)
+	}
+}
@@ -2,9 +2,14 @@ package preferences
 
 import (
 	"encoding/json"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/secrets"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
 	"github.com/kbinani/screenshot"
 )
 
@@ -16,26 +21,74 @@ type Bounds struct {
 }
 
 type AppPreferences struct {
-	Version          string            `json:"version,omitempty"`
-	Name             string            `json:"name,omitempty"`
-	Bounds           Bounds            `json:"bounds,omitempty"`
-	RecentProjects   []string          `json:"recentProjects,omitempty"`
-	LastView         string            `json:"lastView,omitempty"`
-	LastTab          map[string]string `json:"lastTab,omitempty"`
-	LastViewNoWizard string            `json:"lastViewNoWizard,omitempty"`
-	MenuCollapsed    bool              `json:"menuCollapsed,omitempty"`
-	HelpCollapsed    bool              `json:"helpCollapsed,omitempty"`
+	Version          string              `json:"version,omitempty"`
+	Name             string              `json:"name,omitempty"`
+	Bounds           Bounds              `json:"bounds,omitempty"`
+	RecentProjects   []string            `json:"recentProjects,omitempty"`
+	LastView         string              `json:"lastView,omitempty"`
+	LastTab          map[string]string   `json:"lastTab,omitempty"`
+	LastViewNoWizard string              `json:"lastViewNoWizard,omitempty"`
+	MenuCollapsed    bool                `json:"menuCollapsed,omitempty"`
+	HelpCollapsed    bool                `json:"helpCollapsed,omitempty"`
+	MaxResident      int                 `json:"maxResidentProjects,omitempty"` // Open projects kept fully in memory, zero for the default
+	Session          Session             `json:"session,omitempty"`
+	Automation       bool                `json:"automation,omitempty"`     // Serve the local automation API, off unless opted in
+	AutomationPort   int                 `json:"automationPort,omitempty"` // Port for the automation API, zero for any free port
+	Profile          string              `json:"profile,omitempty"`        // Profile last used, empty for the default
+	ProfileRecents   map[string][]string `json:"profileRecents,omitempty"` // Recent projects of the profiles not in use
+}
+
+// Session records the projects that were open when the app last ran so they
+// can be reopened on the next launch
+type Session struct {
+	OpenProjects  []string `json:"openProjects,omitempty"` // Project files in tab order
+	ActiveProject string   `json:"activeProject,omitempty"`
 }
 
 func (p *AppPreferences) String() string {
 	bytes, _ := json.Marshal(p)
-	return string(bytes)
+	return secrets.Redact(string(bytes))
+}
+
+// clone returns a copy of p that shares none of its slices or maps
+func (p AppPreferences) clone() AppPreferences {
+	c := p
+	c.RecentProjects = slices.Clone(p.RecentProjects)
+	c.LastTab = maps.Clone(p.LastTab)
+	c.Session.OpenProjects = slices.Clone(p.Session.OpenProjects)
+	if p.ProfileRecents != nil {
+		c.ProfileRecents = make(map[string][]string, len(p.ProfileRecents))
+		for name, recent := range p.ProfileRecents {
+			c.ProfileRecents[name] = slices.Clone(recent)
+		}
+	}
+	return c
+}
+
+// Validate returns a validation.ValidationError for the first field that
+// holds a value the app cannot use
+func (p *AppPreferences) Validate() error {
+	for _, view := range []string{p.LastView, p.LastViewNoWizard} {
+		if view != "" && !strings.HasPrefix(view, "/") {
+			return validation.ValidationError{Field: "lastView", Problem: "must start with /"}
+		}
+	}
+	if p.MaxResident < 0 {
+		return validation.ValidationError{Field: "maxResidentProjects", Problem: "cannot be negative"}
+	}
+	if p.AutomationPort < 0 || p.AutomationPort > 65535 {
+		return validation.ValidationError{Field: "automationPort", Problem: "must be between 0 and 65535"}
+	}
+	if p.Bounds.Width < 0 || p.Bounds.Height < 0 {
+		return validation.ValidationError{Field: "bounds", Problem: "cannot have a negative size"}
+	}
+	return nil
 }
 
 // NewAppPreferences creates a new AppPreferences instance with default values
 func NewAppPreferences() *AppPreferences {
 	return &AppPreferences{
-		Version:          "1.0",
+		Version:          CurrentVersions[AppFile],
 		RecentProjects:   []string{},
 		LastView:         "/",
 		LastViewNoWizard: "/",
@@ -51,7 +104,7 @@ func GetAppPreferences() (AppPreferences, error) {
 
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		defaults := AppPreferences{
-			Version:          "1.0",
+			Version:          CurrentVersions[AppFile],
 			RecentProjects:   []string{},
 			LastView:         "/",
 			LastViewNoWizard: "/",
@@ -66,7 +119,7 @@ func GetAppPreferences() (AppPreferences, error) {
 		return defaults, nil
 	}
 
-	data, err := os.ReadFile(path)
+	data, err := readPrefsFile(AppFile, path)
 	if err != nil {
 		return AppPreferences{}, err
 	}
@@ -84,20 +137,80 @@ func GetAppPreferences() (AppPreferences, error) {
 	return appPrefs, nil
 }
 
+// SetAppPreferences writes the app preferences right away. The app queues
+// its writes on an AppWriter instead.
 func SetAppPreferences(appPrefs *AppPreferences) error {
+	data, err := json.MarshalIndent(appPrefs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeAppPreferences(data)
+}
+
+// writeAppPreferences replaces the app preferences file, keeping any fields
+// of the file on disk that this build of the app does not know
+func writeAppPreferences(data []byte) error {
 	path := getAppPrefsPath()
+	data, err := keepUnknownFields(path, data, AppPreferences{})
+	if err != nil {
+		return err
+	}
+	return writeAtomic(path, data)
+}
 
-	data, err := json.MarshalIndent(appPrefs, "", "  ")
+// writeAtomic replaces a file through a temporary file, so a crash mid-write
+// leaves the previous file intact
+func writeAtomic(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
 	if err != nil {
 		return err
 	}
 
-	err = os.MkdirAll(filepath.Dir(path), 0755)
+	// Each write has its own temporary file so concurrent writers cannot mix
+	pattern := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + "-*.tmp"
+	temp, err := os.CreateTemp(filepath.Dir(path), pattern)
 	if err != nil {
 		return err
 	}
+	defer os.Remove(temp.Name())
 
-	return os.WriteFile(path, data, 0644)
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(temp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+// AddRecentProject moves path to the top of the recent projects, keeping at
+// most ten. It reports whether the list changed.
+func (p *AppPreferences) AddRecentProject(path string) bool {
+	if len(p.RecentProjects) > 0 && p.RecentProjects[0] == path {
+		return false
+	}
+
+	recent := []string{path}
+	for _, existing := range p.RecentProjects {
+		if existing != path {
+			recent = append(recent, existing)
+		}
+	}
+
+	maxRecent := 10 // Maximum number of recent projects to keep
+	if len(recent) > maxRecent {
+		recent = recent[:maxRecent]
+	}
+	p.RecentProjects = recent
+	return true
 }
 
 func getDefaultBounds() Bounds {
@@ -114,5 +227,37 @@ func getDefaultBounds() Bounds {
 }
 
 func getAppPrefsPath() string {
-	return filepath.Join(getConfigBase(), ToCamel(configBaseApp), "app_prefs.json")
+	return filepath.Join(getConfigBase(), ToCamel(GetAppId().baseApp()), "app_prefs.json")
+}
+
+// SwitchRecentProjects puts away the recent projects of the profile in use
+// and brings out those of profile, which becomes the one in use
+func (p *AppPreferences) SwitchRecentProjects(profile string) {
+	current := p.Profile
+	if current == "" {
+		current = DefaultProfile
+	}
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	if profile == current {
+		return
+	}
+
+	if p.ProfileRecents == nil {
+		p.ProfileRecents = make(map[string][]string)
+	}
+	if len(p.RecentProjects) > 0 {
+		p.ProfileRecents[current] = p.RecentProjects
+	}
+	p.RecentProjects = p.ProfileRecents[profile]
+	if p.RecentProjects == nil {
+		p.RecentProjects = []string{}
+	}
+	delete(p.ProfileRecents, profile)
+
+	p.Profile = profile
+	if profile == DefaultProfile {
+		p.Profile = ""
+	}
 }
@@ -0,0 +1,123 @@
+package preferences
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// AppWriter persists app preferences from a single goroutine. Changes queued
+// in quick succession are coalesced into one write, and every write goes
+// through writeAppPreferences so the file is never left half written.
+type AppWriter struct {
+	delay   time.Duration
+	onError func(error)
+	pending []byte // Latest queued preferences not yet written, nil if none
+	mutex   sync.Mutex
+	wake    chan struct{}
+	flush   chan chan error
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewAppWriter starts a writer that waits delay after a change for further
+// changes before writing. Errors from background writes are passed to onError.
+func NewAppWriter(delay time.Duration, onError func(error)) *AppWriter {
+	w := &AppWriter{
+		delay:   delay,
+		onError: onError,
+		wake:    make(chan struct{}, 1),
+		flush:   make(chan chan error),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.loop()
+	return w
+}
+
+// Queue schedules prefs to be written. The preferences are copied, so the
+// caller may go on changing them.
+func (w *AppWriter) Queue(prefs *AppPreferences) error {
+	data, err := json.MarshalIndent(prefs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	w.mutex.Lock()
+	w.pending = data
+	w.mutex.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default: // A write is already scheduled
+	}
+	return nil
+}
+
+// Flush writes any queued preferences now and waits for the write
+func (w *AppWriter) Flush() error {
+	reply := make(chan error)
+	select {
+	case w.flush <- reply:
+		return <-reply
+	case <-w.done:
+		return nil
+	}
+}
+
+// Close writes any queued preferences and stops the writer
+func (w *AppWriter) Close() error {
+	err := w.Flush()
+	select {
+	case <-w.done:
+	default:
+		close(w.stop)
+		<-w.done
+	}
+	return err
+}
+
+func (w *AppWriter) loop() {
+	defer close(w.done)
+
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-w.wake:
+			if timer == nil {
+				timer = time.After(w.delay)
+			}
+		case <-timer:
+			timer = nil
+			if err := w.write(); err != nil && w.onError != nil {
+				w.onError(err)
+			}
+		case reply := <-w.flush:
+			timer = nil
+			reply <- w.write()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+func (w *AppWriter) write() error {
+	w.mutex.Lock()
+	data := w.pending
+	w.pending = nil
+	w.mutex.Unlock()
+
+	if data == nil {
+		return nil
+	}
+	if err := writeAppPreferences(data); err != nil {
+		// Keep the failed write for the next attempt unless something newer is queued
+		w.mutex.Lock()
+		if w.pending == nil {
+			w.pending = data
+		}
+		w.mutex.Unlock()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package preferences
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppWriterCoalescesAndFlushes(t *testing.T) {
+	defer SetConfigBaseForTest(t, t.TempDir())()
+
+	w := NewAppWriter(time.Hour, func(err error) { t.Errorf("Unexpected write error: %v", err) })
+	defer w.Close()
+
+	prefs := AppPreferences{Version: "1.0"}
+	for _, view := range []string{"/one", "/two", "/three"} {
+		prefs.LastView = view
+		if err := w.Queue(&prefs); err != nil {
+			t.Fatalf("Queue failed: %v", err)
+		}
+	}
+	// Changes after queueing do not leak into the queued copy
+	prefs.LastView = "/unqueued"
+
+	if _, err := os.Stat(getAppPrefsPath()); !os.IsNotExist(err) {
+		t.Fatal("Nothing should be written before the delay or a flush")
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	saved, err := GetAppPreferences()
+	if err != nil {
+		t.Fatalf("Failed to read app preferences: %v", err)
+	}
+	if saved.LastView != "/three" {
+		t.Errorf("Expected the last queued view, got %s", saved.LastView)
+	}
+}
+
+func TestAppWriterWritesAfterDelay(t *testing.T) {
+	defer SetConfigBaseForTest(t, t.TempDir())()
+
+	w := NewAppWriter(10*time.Millisecond, nil)
+	defer w.Close()
+
+	if err := w.Queue(&AppPreferences{Version: "1.0", LastView: "/later"}); err != nil {
+		t.Fatalf("Queue failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if data, err := os.ReadFile(getAppPrefsPath()); err == nil && len(data) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the delayed write")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAppWriterCloseFlushes(t *testing.T) {
+	defer SetConfigBaseForTest(t, t.TempDir())()
+
+	w := NewAppWriter(time.Hour, nil)
+	if err := w.Queue(&AppPreferences{Version: "1.0", LastView: "/closing"}); err != nil {
+		t.Fatalf("Queue failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Closing twice should be harmless, got %v", err)
+	}
+
+	saved, _ := GetAppPreferences()
+	if saved.LastView != "/closing" {
+		t.Errorf("Expected the queued view to be written on close, got %s", saved.LastView)
+	}
+
+	// Only the preferences file is left behind, no temporary files
+	entries, _ := os.ReadDir(filepath.Dir(getAppPrefsPath()))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the preferences file, got %d entries", len(entries))
+	}
+}
+
+func TestAddRecentProject(t *testing.T) {
+	prefs := AppPreferences{}
+	for i := 0; i < 12; i++ {
+		prefs.AddRecentProject(filepath.Join("projects", string(rune('a'+i))))
+	}
+	if len(prefs.RecentProjects) != 10 || prefs.RecentProjects[0] != filepath.Join("projects", "l") {
+		t.Errorf("Expected the ten most recent, newest first, got %v", prefs.RecentProjects)
+	}
+
+	if !prefs.AddRecentProject(filepath.Join("projects", "e")) || prefs.RecentProjects[0] != filepath.Join("projects", "e") || len(prefs.RecentProjects) != 10 {
+		t.Errorf("Expected an existing project to move to the top, got %v", prefs.RecentProjects)
+	}
+	if prefs.AddRecentProject(filepath.Join("projects", "e")) {
+		t.Error("Adding the top project again should change nothing")
+	}
+}
@@ -0,0 +1,377 @@
+package preferences
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/secrets"
+)
+
+// SettingsBundleVersion is the layout version written to every settings bundle
+const SettingsBundleVersion = "1"
+
+// ErrSettingsBundleInvalid is returned for a settings bundle that cannot be read
+var ErrSettingsBundleInvalid = errors.New("invalid settings bundle")
+
+// ExportOptions leaves parts of the settings out of an exported bundle
+type ExportOptions struct {
+	ExcludeSecrets bool `json:"excludeSecrets"` // Leave out the keystore and passwords embedded in RPC URLs
+	ExcludeBounds  bool `json:"excludeBounds"`  // Leave out the window's size and position
+}
+
+// SettingsBundle carries the org, user and app preferences, and optionally
+// the keystore's secrets, from one machine to another. The app preferences
+// travel without the recent projects, open projects and profile, which only
+// make sense on the machine they were recorded on.
+type SettingsBundle struct {
+	Version string            `json:"version"`
+	Created string            `json:"created"`
+	Org     OrgPreferences    `json:"org"`
+	User    UserPreferences   `json:"user"`
+	App     AppPreferences    `json:"app"`
+	Secrets map[string]string `json:"secrets,omitempty"`
+}
+
+// SecretsSection names the keystore in a SettingChange
+const SecretsSection PrefsFile = "secrets"
+
+// SettingChange is one field a settings import would change. Values are
+// shown as JSON with secrets redacted.
+type SettingChange struct {
+	Section PrefsFile `json:"section"`
+	Field   string    `json:"field"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+}
+
+// ExportSettings writes the current org, user and app preferences to a
+// settings bundle at dest
+func ExportSettings(dest string, opts ExportOptions) error {
+	bundle := SettingsBundle{
+		Version: SettingsBundleVersion,
+		Created: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	var err error
+	if bundle.Org, err = GetOrgPreferences(); err != nil {
+		return err
+	}
+	if bundle.User, err = GetUserPreferences(); err != nil {
+		return err
+	}
+	if bundle.App, err = GetAppPreferences(); err != nil {
+		return err
+	}
+	clearLocalState(&bundle.App)
+	if opts.ExcludeBounds {
+		bundle.App.Bounds = Bounds{}
+	}
+
+	if opts.ExcludeSecrets {
+		bundle.User.Chains = withoutCredentials(bundle.User.Chains)
+	} else if store := secrets.Default(); store != nil {
+		names, err := store.Names()
+		if err != nil {
+			return fmt.Errorf("cannot export secrets: %w", err)
+		}
+		bundle.Secrets = make(map[string]string, len(names))
+		for _, name := range names {
+			if bundle.Secrets[name], err = store.Get(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	// The bundle may hold secrets, so only the user may read it
+	return os.WriteFile(dest, data, 0600)
+}
+
+// ReadSettingsBundle reads a settings bundle and validates every field. It
+// returns a validation.ValidationError for the first field the app cannot
+// use or the org policy does not allow.
+func ReadSettingsBundle(src string) (*SettingsBundle, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var bundle SettingsBundle
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSettingsBundleInvalid, err)
+	}
+	if bundle.Version != SettingsBundleVersion {
+		return nil, fmt.Errorf("%w: unsupported version %q", ErrSettingsBundleInvalid, bundle.Version)
+	}
+
+	if err := bundle.Org.Validate(); err != nil {
+		return nil, err
+	}
+	if err := bundle.User.Validate(); err != nil {
+		return nil, err
+	}
+	if err := bundle.App.Validate(); err != nil {
+		return nil, err
+	}
+
+	policy, err := LoadPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.check(&bundle.Org); err != nil {
+		return nil, err
+	}
+	if err := policy.checkUser(&bundle.User); err != nil {
+		return nil, err
+	}
+	return &bundle, nil
+}
+
+// Diff lists the fields importing the bundle would change, by section and field
+func (b *SettingsBundle) Diff() ([]SettingChange, error) {
+	org, user, app, err := b.merged()
+	if err != nil {
+		return nil, err
+	}
+	current, err := currentSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []SettingChange
+	changes = append(changes, diffFields(OrgFile, current.org, org)...)
+	changes = append(changes, diffFields(UserFile, current.user, user)...)
+	changes = append(changes, diffFields(AppFile, current.app, app)...)
+
+	if len(b.Secrets) > 0 {
+		store := secrets.Default()
+		names := make([]string, 0, len(b.Secrets))
+		for name := range b.Secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			change := SettingChange{Section: SecretsSection, Field: name, To: secrets.Redacted}
+			if store != nil {
+				if value, err := store.Get(name); err == nil {
+					if value == b.Secrets[name] {
+						continue
+					}
+					change.From = secrets.Redacted
+				}
+			}
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// Apply writes the bundle's preferences and secrets. Either everything is
+// written or, if any write fails, the files and secrets are put back as they
+// were. Recent projects, open projects, the profile and, if the bundle has
+// none, the window bounds are kept from the current app preferences.
+func (b *SettingsBundle) Apply() error {
+	org, user, app, err := b.merged()
+	if err != nil {
+		return err
+	}
+
+	store := secrets.Default()
+	if len(b.Secrets) > 0 && (store == nil || store.Locked()) {
+		return fmt.Errorf("cannot import secrets: %w", secrets.ErrLocked)
+	}
+
+	paths := []string{getOrgPrefsPath(), getUserPrefsPath(), getAppPrefsPath()}
+	originals := make(map[string][]byte, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		originals[path] = data
+	}
+	restore := func() {
+		for path, data := range originals {
+			if data == nil {
+				_ = os.Remove(path)
+			} else {
+				_ = writeAtomic(path, data)
+			}
+		}
+	}
+
+	if err := SetOrgPreferences(&org); err != nil {
+		restore()
+		return err
+	}
+	if err := SetUserPreferences(&user); err != nil {
+		restore()
+		return err
+	}
+	if err := SetAppPreferences(&app); err != nil {
+		restore()
+		return err
+	}
+
+	// Secrets that existed before are put back, new ones removed
+	replaced := make(map[string]*string)
+	undoSecrets := func() {
+		for name, value := range replaced {
+			if value == nil {
+				_ = store.Delete(name)
+			} else {
+				_ = store.Set(name, *value)
+			}
+		}
+	}
+	for name, value := range b.Secrets {
+		replaced[name] = nil
+		if old, err := store.Get(name); err == nil {
+			replaced[name] = &old
+		}
+		if err := store.Set(name, value); err != nil {
+			undoSecrets()
+			restore()
+			return err
+		}
+	}
+	return nil
+}
+
+// merged returns the preferences as they would be after importing the bundle
+func (b *SettingsBundle) merged() (OrgPreferences, UserPreferences, AppPreferences, error) {
+	current, err := GetAppPreferences()
+	if err != nil {
+		return OrgPreferences{}, UserPreferences{}, AppPreferences{}, err
+	}
+
+	app := b.App
+	app.RecentProjects = current.RecentProjects
+	app.Session = current.Session
+	app.Profile = current.Profile
+	app.ProfileRecents = current.ProfileRecents
+	if app.Bounds == (Bounds{}) {
+		app.Bounds = current.Bounds
+	}
+	if app.LastTab == nil {
+		app.LastTab = make(map[string]string)
+	}
+
+	user := b.User
+	if user.Chains == nil {
+		user.Chains = []Chain{}
+	}
+	return b.Org, user, app, nil
+}
+
+type settings struct {
+	org  OrgPreferences
+	user UserPreferences
+	app  AppPreferences
+}
+
+func currentSettings() (settings, error) {
+	var s settings
+	var err error
+	if s.org, err = GetOrgPreferences(); err != nil {
+		return s, err
+	}
+	if s.user, err = GetUserPreferences(); err != nil {
+		return s, err
+	}
+	s.app, err = GetAppPreferences()
+	return s, err
+}
+
+// clearLocalState empties the app preferences that only make sense on the
+// machine they were recorded on
+func clearLocalState(app *AppPreferences) {
+	app.RecentProjects = nil
+	app.Session = Session{}
+	app.Profile = ""
+	app.ProfileRecents = nil
+}
+
+// withoutCredentials returns a copy of chains with any user name and password
+// removed from the RPC URLs
+func withoutCredentials(chains []Chain) []Chain {
+	stripped := make([]Chain, len(chains))
+	for i, chain := range chains {
+		stripped[i] = chain
+		stripped[i].RpcProviders = make([]string, len(chain.RpcProviders))
+		for j, rpc := range chain.RpcProviders {
+			if u, err := url.Parse(rpc); err == nil && u.User != nil {
+				u.User = nil
+				rpc = u.String()
+			}
+			stripped[i].RpcProviders[j] = rpc
+		}
+	}
+	return stripped
+}
+
+// diffFields compares two preference structs field by field, by JSON name
+func diffFields(section PrefsFile, from, to interface{}) []SettingChange {
+	before, after := rawFields(from), rawFields(to)
+
+	keys := make(map[string]bool)
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []SettingChange
+	for _, key := range sorted {
+		if before[key] != after[key] {
+			changes = append(changes, SettingChange{
+				Section: section,
+				Field:   key,
+				From:    secrets.Redact(before[key]),
+				To:      secrets.Redact(after[key]),
+			})
+		}
+	}
+	return changes
+}
+
+// rawFields returns the compact JSON of each field of a preferences struct
+func rawFields(prefs interface{}) map[string]string {
+	fields := make(map[string]string)
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return fields
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fields
+	}
+	for key, value := range raw {
+		var compact bytes.Buffer
+		if json.Compact(&compact, value) == nil {
+			fields[key] = compact.String()
+		}
+	}
+	return fields
+}
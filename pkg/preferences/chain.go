@@ -1,5 +1,13 @@
 package preferences
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/secrets"
+)
+
 type Chain struct {
 	Chain          string   `json:"chain"`
 	ChainId        uint64   `json:"chainId"`
@@ -7,3 +15,120 @@ type Chain struct {
 	RpcProviders   []string `json:"rpcProviders"`
 	Symbol         string   `json:"symbol"`
 }
+
+// Sources a ChainConflict may name besides the user preferences
+const (
+	ChainSourceUser      = "user"
+	ChainSourceChainList = "chainList"
+	ChainSourceConfig    = "trueBlocks.toml"
+)
+
+// ChainConflict is a setting of one of the user's chains that disagrees with
+// another source, or with another of the user's chains
+type ChainConflict struct {
+	Chain   string `json:"chain"`
+	ChainId uint64 `json:"chainId"`
+	Field   string `json:"field"`
+	User    string `json:"user"`
+	Source  string `json:"source"`
+	Value   string `json:"value"`
+}
+
+// ChainConflicts compares the user's chains with the chains known to source,
+// matching them by chain ID. Only settings both sides have are compared, so a
+// source without names or RPC providers never conflicts on them. Chains the
+// source does not know are not conflicts.
+func ChainConflicts(user []Chain, source string, known []Chain) []ChainConflict {
+	byId := make(map[uint64]Chain, len(known))
+	for _, chain := range known {
+		byId[chain.ChainId] = chain
+	}
+
+	var conflicts []ChainConflict
+	for _, chain := range user {
+		other, ok := byId[chain.ChainId]
+		if !ok {
+			continue
+		}
+		add := func(field, mine, theirs string) {
+			conflicts = append(conflicts, ChainConflict{
+				Chain:   chain.Chain,
+				ChainId: chain.ChainId,
+				Field:   field,
+				User:    mine,
+				Source:  source,
+				Value:   theirs,
+			})
+		}
+		if chain.Chain != "" && other.Chain != "" && chain.Chain != other.Chain {
+			add("chain", chain.Chain, other.Chain)
+		}
+		if chain.Symbol != "" && other.Symbol != "" && !strings.EqualFold(chain.Symbol, other.Symbol) {
+			add("symbol", chain.Symbol, other.Symbol)
+		}
+		if chain.RemoteExplorer != "" && other.RemoteExplorer != "" &&
+			strings.TrimSuffix(chain.RemoteExplorer, "/") != strings.TrimSuffix(other.RemoteExplorer, "/") {
+			add("remoteExplorer", chain.RemoteExplorer, other.RemoteExplorer)
+		}
+		// The source's preferred provider should be one the user knows about,
+		// with the user's credentials put back from the keystore to compare
+		if len(chain.RpcProviders) > 0 && len(other.RpcProviders) > 0 &&
+			!slices.ContainsFunc(chain.RpcProviders, func(rpc string) bool {
+				return secrets.ResolveURL(rpc) == other.RpcProviders[0]
+			}) {
+			add("rpcProviders", chain.RpcProviders[0], other.RpcProviders[0])
+		}
+	}
+	return conflicts
+}
+
+// DuplicateChains returns the user's chains that share a name or a chain ID
+// with an earlier one
+func DuplicateChains(user []Chain) []ChainConflict {
+	names := make(map[string]Chain)
+	ids := make(map[uint64]Chain)
+
+	var conflicts []ChainConflict
+	for _, chain := range user {
+		if first, ok := ids[chain.ChainId]; ok {
+			conflicts = append(conflicts, ChainConflict{
+				Chain:   chain.Chain,
+				ChainId: chain.ChainId,
+				Field:   "chainId",
+				User:    chain.Chain,
+				Source:  ChainSourceUser,
+				Value:   first.Chain,
+			})
+		} else if first, ok := names[chain.Chain]; ok {
+			conflicts = append(conflicts, ChainConflict{
+				Chain:   chain.Chain,
+				ChainId: chain.ChainId,
+				Field:   "chain",
+				User:    fmt.Sprint(chain.ChainId),
+				Source:  ChainSourceUser,
+				Value:   fmt.Sprint(first.ChainId),
+			})
+		}
+		if _, ok := ids[chain.ChainId]; !ok {
+			ids[chain.ChainId] = chain
+		}
+		if _, ok := names[chain.Chain]; !ok {
+			names[chain.Chain] = chain
+		}
+	}
+	return conflicts
+}
+
+// Prefill fills the chain's empty name, symbol and explorer from known
+func (c Chain) Prefill(known Chain) Chain {
+	if c.Chain == "" {
+		c.Chain = known.Chain
+	}
+	if c.Symbol == "" {
+		c.Symbol = known.Symbol
+	}
+	if c.RemoteExplorer == "" {
+		c.RemoteExplorer = known.RemoteExplorer
+	}
+	return c
+}
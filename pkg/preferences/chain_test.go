@@ -0,0 +1,37 @@
+package preferences
+
+import (
+	"testing"
+)
+
+func TestChainConflicts(t *testing.T) {
+	user := []Chain{
+		{Chain: "mainnet", ChainId: 1, Symbol: "ETH", RemoteExplorer: "https://etherscan.io/", RpcProviders: []string{"https://rpc.example.com"}},
+		{Chain: "gnosis", ChainId: 100, Symbol: "DAI"},
+		{Chain: "private", ChainId: 7777},
+	}
+	known := []Chain{
+		{Chain: "mainnet", ChainId: 1, Symbol: "eth", RemoteExplorer: "https://etherscan.io", RpcProviders: []string{"http://localhost:8545"}},
+		{Chain: "xdai", ChainId: 100, Symbol: "XDAI"},
+	}
+
+	conflicts := ChainConflicts(user, ChainSourceConfig, known)
+	expected := []ChainConflict{
+		{Chain: "mainnet", ChainId: 1, Field: "rpcProviders", User: "https://rpc.example.com", Source: ChainSourceConfig, Value: "http://localhost:8545"},
+		{Chain: "gnosis", ChainId: 100, Field: "chain", User: "gnosis", Source: ChainSourceConfig, Value: "xdai"},
+		{Chain: "gnosis", ChainId: 100, Field: "symbol", User: "DAI", Source: ChainSourceConfig, Value: "XDAI"},
+	}
+	if len(conflicts) != len(expected) {
+		t.Fatalf("Expected %d conflicts, got %+v", len(expected), conflicts)
+	}
+	for i := range expected {
+		if conflicts[i] != expected[i] {
+			t.Errorf("Conflict %d: expected %+v, got %+v", i, expected[i], conflicts[i])
+		}
+	}
+
+	duplicates := DuplicateChains(append(user, Chain{Chain: "mainnet", ChainId: 5}, Chain{Chain: "eth", ChainId: 1}))
+	if len(duplicates) != 2 || duplicates[0].Field != "chain" || duplicates[1].Field != "chainId" || duplicates[1].Value != "mainnet" {
+		t.Errorf("Unexpected duplicates %+v", duplicates)
+	}
+}
@@ -1,13 +1,16 @@
 package preferences
 
 import (
-	"embed"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -21,7 +24,7 @@ func getConfigBase() string {
 	}
 
 	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
-		return filepath.Join(xdg, theOrg.DeveloperName)
+		return filepath.Join(xdg, GetAppId().OrgName)
 	}
 
 	home, err := os.UserHomeDir()
@@ -31,16 +34,16 @@ func getConfigBase() string {
 
 	switch runtime.GOOS {
 	case "darwin":
-		return filepath.Join(home, "Library", "Application Support", theOrg.DeveloperName)
+		return filepath.Join(home, "Library", "Application Support", GetAppId().OrgName)
 	case "windows":
-		return filepath.Join(os.Getenv("AppData"), theOrg.DeveloperName)
+		return filepath.Join(os.Getenv("AppData"), GetAppId().OrgName)
 	default:
-		return filepath.Join(home, ".config", theOrg.DeveloperName)
+		return filepath.Join(home, ".config", GetAppId().OrgName)
 	}
 }
 
 func GetConfigFolders() (string, string) {
-	return getConfigBase(), filepath.Join(getConfigBase(), ToCamel(configBaseApp))
+	return getConfigBase(), filepath.Join(getConfigBase(), ToCamel(GetAppId().baseApp()))
 }
 
 func ToProper(s string) string {
@@ -72,6 +75,9 @@ func ToCamel(s string) string {
 	return result
 }
 
+// Id is the identity of the app: what it is called, who makes it and where
+// to find them. It names the config folders, the environment variables and
+// the folder images are stored in.
 type Id struct {
 	AppName string `json:"appName"`
 	OrgName string `json:"orgName"`
@@ -80,20 +86,42 @@ type Id struct {
 	Twitter string `json:"twitter"`
 }
 
+// defaultId is the identity used until LoadIdentifiers reads wails.json
+var defaultId = Id{
+	AppName: "TrueBlocks Codegen",
+	OrgName: "TrueBlocks",
+	Github:  "https://github.com/TrueBlocks/trueblocks-codegen",
+	Domain:  "trueblocks.io",
+	Twitter: "trueblocks",
+}
+
+var (
+	identity   = defaultId
+	identityMu sync.RWMutex
+)
+
+// GetAppId returns the app's identity
 func GetAppId() Id {
-	return Id{
-		AppName: strings.Join([]string{theOrg.DeveloperName, configBaseApp}, " "),
-		OrgName: theOrg.DeveloperName,
-		Github:  configBaseGithub,
-		Domain:  configBaseDomain,
-		Twitter: strings.ToLower(theOrg.DeveloperName),
-	}
+	identityMu.RLock()
+	defer identityMu.RUnlock()
+	return identity
+}
+
+// setAppId replaces the app's identity
+func setAppId(id Id) {
+	identityMu.Lock()
+	defer identityMu.Unlock()
+	identity = id
 }
 
-const configOrgName = "TrueBlocks"
-const configBaseApp = "Codegen"
-const configBaseGithub = "github.com/TrueBlocks/trueblocks-core"
-const configBaseDomain = "trueblocks.io"
+// baseApp returns the app's name without the org's, for example Codegen for
+// TrueBlocks Codegen. It names the app's folder inside the org's.
+func (id Id) baseApp() string {
+	if base := strings.TrimSpace(strings.TrimPrefix(id.AppName, id.OrgName)); base != "" {
+		return base
+	}
+	return id.AppName
+}
 
 // WailsConfig represents the structure of the wails.json configuration file
 type WailsConfig struct {
@@ -108,26 +136,82 @@ type WailsConfig struct {
 		Name  string `json:"name"`
 		Email string `json:"email"`
 	} `json:"author"`
-	Github string `json:"github"`
+	Github   string `json:"github"`
+	Identity Id     `json:"identity"` // Overrides what is derived from the fields above
 }
 
-func LoadIdentifiers(embedFS embed.FS) {
-	configData, err := embedFS.ReadFile("wails.json")
+// Id returns the identity the config describes. The app is named by name, the
+// org by the author, the domain by the author's email and the twitter handle
+// by the org. Any field of the identity section takes precedence.
+func (c *WailsConfig) Id() Id {
+	id := Id{
+		AppName: c.Name,
+		OrgName: c.Author.Name,
+		Github:  c.Github,
+	}
+	if _, domain, ok := strings.Cut(c.Author.Email, "@"); ok {
+		id.Domain = domain
+	}
+
+	if c.Identity.AppName != "" {
+		id.AppName = c.Identity.AppName
+	}
+	if c.Identity.OrgName != "" {
+		id.OrgName = c.Identity.OrgName
+	}
+	if c.Identity.Github != "" {
+		id.Github = c.Identity.Github
+	}
+	if c.Identity.Domain != "" {
+		id.Domain = c.Identity.Domain
+	}
+	id.Twitter = c.Identity.Twitter
+	if id.Twitter == "" {
+		id.Twitter = strings.ToLower(strings.ReplaceAll(id.OrgName, " ", ""))
+	}
+
+	if id.Github != "" && !strings.Contains(id.Github, "://") {
+		id.Github = "https://" + id.Github
+	}
+	return id
+}
+
+// Validate checks that the identity can name folders and environment variables
+func (id Id) Validate() error {
+	for _, field := range []struct{ name, value string }{{"appName", id.AppName}, {"orgName", id.OrgName}} {
+		switch {
+		case strings.TrimSpace(field.value) == "":
+			return validation.ValidationError{Field: field.name, Problem: "cannot be empty"}
+		case strings.ContainsAny(field.value, `/\:`) || strings.HasPrefix(field.value, "."):
+			return validation.ValidationError{Field: field.name, Problem: "cannot be used as a folder name"}
+		}
+	}
+	if id.Github != "" {
+		if err := validation.ValidURL("github", id.Github); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadIdentifiers takes the app's identity from the wails.json in fsys. If the
+// file is missing or does not describe a usable identity, the app keeps its
+// default identity and the error is returned.
+func LoadIdentifiers(fsys fs.FS) error {
+	configData, err := fs.ReadFile(fsys, "wails.json")
 	if err != nil {
-		fmt.Printf("Error reading wails.json: %v\n", err)
-		return
+		return err
 	}
 
 	var config WailsConfig
 	if err := json.Unmarshal(configData, &config); err != nil {
-		fmt.Printf("Error parsing wails.json: %v\n", err)
-		return
+		return fmt.Errorf("parsing wails.json: %w", err)
 	}
 
-	// Display the parsed configuration
-	fmt.Printf("Wails Configuration:\n")
-	fmt.Printf("  Name: %s\n", config.Name)
-	fmt.Printf("  Output filename: %s\n", config.OutputFilename)
-	fmt.Printf("  Author: %s (%s)\n", config.Author.Name, config.Author.Email)
-	fmt.Printf("  Github: %s\n", config.Github)
+	id := config.Id()
+	if err := id.Validate(); err != nil {
+		return fmt.Errorf("wails.json: %w", err)
+	}
+	setAppId(id)
+	return nil
 }
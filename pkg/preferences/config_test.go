@@ -0,0 +1,64 @@
+package preferences
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
+)
+
+func TestLoadIdentifiers(t *testing.T) {
+	defer setAppId(GetAppId())
+
+	// The repo's own wails.json describes the default identity
+	if err := LoadIdentifiers(os.DirFS("../..")); err != nil || GetAppId() != defaultId {
+		t.Errorf("Expected the default identity from wails.json, got %+v and %v", GetAppId(), err)
+	}
+
+	wails := `{
+		"name": "Acme Ledger",
+		"author": {"name": "Acme", "email": "hello@acme.example"},
+		"identity": {"github": "github.com/acme/ledger"}
+	}`
+	if err := LoadIdentifiers(fstest.MapFS{"wails.json": {Data: []byte(wails)}}); err != nil {
+		t.Fatalf("LoadIdentifiers failed: %v", err)
+	}
+	expected := Id{
+		AppName: "Acme Ledger",
+		OrgName: "Acme",
+		Github:  "https://github.com/acme/ledger",
+		Domain:  "acme.example",
+		Twitter: "acme",
+	}
+	if id := GetAppId(); id != expected {
+		t.Errorf("Unexpected identity\n got: %+v\nwant: %+v", id, expected)
+	}
+
+	// Folders, environment variables and defaults follow the identity
+	_, appFolder := GetConfigFolders()
+	if filepath.Base(appFolder) != "ledger" || filepath.Base(filepath.Dir(appFolder)) != "Acme" {
+		t.Errorf("Expected the Acme/ledger folder, got %s", appFolder)
+	}
+	if got := EnvName("backupCount"); got != "ACME_LEDGER_BACKUP_COUNT" {
+		t.Errorf("Expected ACME_LEDGER_BACKUP_COUNT, got %s", got)
+	}
+	if org := NewOrgPreferences(); org.DeveloperName != "Acme" || org.SupportURL != "https://acme.example/support" {
+		t.Errorf("Expected the org defaults to follow the identity, got %+v", org)
+	}
+
+	// An identity that cannot name a folder keeps the one in use
+	invalid := `{"name": "Ledger", "author": {"name": "../acme"}}`
+	var problem validation.ValidationError
+	if err := LoadIdentifiers(fstest.MapFS{"wails.json": {Data: []byte(invalid)}}); !errors.As(err, &problem) || problem.Field != "orgName" {
+		t.Errorf("Expected a ValidationError for orgName, got %v", err)
+	}
+	if err := LoadIdentifiers(fstest.MapFS{}); err == nil {
+		t.Error("Expected an error without a wails.json")
+	}
+	if GetAppId() != expected {
+		t.Errorf("Expected the identity to be kept, got %+v", GetAppId())
+	}
+}
@@ -0,0 +1,184 @@
+package preferences
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/secrets"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
+)
+
+// PrefsFile names one of the preference files
+type PrefsFile string
+
+const (
+	OrgFile  PrefsFile = "org"
+	UserFile PrefsFile = "user"
+	AppFile  PrefsFile = "app"
+)
+
+// CurrentVersions holds the schema version of each preference file written by
+// this build of the app. Bump a version together with the migration that
+// upgrades files from the previous one.
+var CurrentVersions = map[PrefsFile]string{
+	OrgFile:  "1.0",
+	UserFile: "1.1",
+	AppFile:  "1.0",
+}
+
+// ErrNewerVersion is returned when a preference file was written by a newer version of the app
+var ErrNewerVersion = errors.New("preference file is from a newer version")
+
+// MigrationFunc upgrades the raw JSON fields of a preference file in place.
+// It may rename or split fields or fill in new defaults. Fields it does not
+// touch are kept, including ones this build of the app does not know.
+type MigrationFunc func(fields map[string]interface{}) error
+
+type migration struct {
+	to    string
+	apply MigrationFunc
+}
+
+// migrations maps each file's schema versions to the step that upgrades it to the next version
+var migrations = map[PrefsFile]map[string]migration{}
+
+// RegisterMigration adds a step to the migration chain that upgrades the
+// given preference file from version `from` to version `to`
+func RegisterMigration(file PrefsFile, from, to string, fn MigrationFunc) {
+	if migrations[file] == nil {
+		migrations[file] = map[string]migration{}
+	}
+	if _, exists := migrations[file][from]; exists {
+		panic(fmt.Sprintf("%s preferences migration from version %s is already registered", file, from))
+	}
+	migrations[file][from] = migration{to: to, apply: fn}
+}
+
+// migrate upgrades the raw contents of a preference file to its current
+// version. It returns the upgraded bytes and the version the file was written with.
+func migrate(file PrefsFile, data []byte) ([]byte, string, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, "", err
+	}
+
+	// Files written before versioning was read are treated as 1.0
+	original, _ := fields["version"].(string)
+	if original == "" {
+		original = "1.0"
+	}
+
+	current := CurrentVersions[file]
+	cmp, err := validation.CompareVersions(original, current)
+	if err != nil {
+		return nil, original, err
+	}
+	if cmp > 0 {
+		return nil, original, fmt.Errorf("%w: %s preferences are version %s, this app supports up to %s", ErrNewerVersion, file, original, current)
+	}
+	if cmp == 0 {
+		return data, original, nil
+	}
+
+	version := original
+	for version != current {
+		step, exists := migrations[file][version]
+		if !exists {
+			return nil, original, fmt.Errorf("no %s preferences migration registered from version %s", file, version)
+		}
+		if err := step.apply(fields); err != nil {
+			return nil, original, fmt.Errorf("%s preferences migration from version %s to %s failed: %w", file, version, step.to, err)
+		}
+		version = step.to
+		fields["version"] = version
+	}
+
+	upgraded, err := json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return nil, original, err
+	}
+	return upgraded, original, nil
+}
+
+// readPrefsFile reads a preference file and upgrades it to the current
+// version. An upgraded file is written back, after copying the original
+// aside so the upgrade can always be undone by hand. Secrets are redacted in
+// the copy.
+func readPrefsFile(file PrefsFile, path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	upgraded, original, err := migrate(file, data)
+	if err != nil || original == CurrentVersions[file] {
+		return upgraded, err
+	}
+
+	// Credentials the migration moved into the keystore stay out of the copy
+	backup := []byte(secrets.Redact(string(data)))
+	if err := os.WriteFile(fmt.Sprintf("%s.v%s.bak", path, original), backup, 0644); err != nil {
+		return nil, fmt.Errorf("failed to back up %s preferences before migration: %w", file, err)
+	}
+	if err := writeAtomic(path, upgraded); err != nil {
+		return nil, err
+	}
+	return upgraded, nil
+}
+
+// keepUnknownFields adds to data, the marshaled prefs about to be written to
+// path, any fields of the file on disk that prefs does not declare. Fields
+// added by a newer version of the app or by hand survive a save this way.
+func keepUnknownFields(path string, data []byte, prefs interface{}) ([]byte, error) {
+	existing, err := os.ReadFile(path)
+	if err != nil {
+		return data, nil
+	}
+	var onDisk map[string]json.RawMessage
+	if json.Unmarshal(existing, &onDisk) != nil {
+		return data, nil
+	}
+
+	known := jsonFields(reflect.TypeOf(prefs))
+	var unknown []string
+	for key := range onDisk {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) == 0 {
+		return data, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, key := range unknown {
+		fields[key] = onDisk[key]
+	}
+	return json.MarshalIndent(fields, "", "  ")
+}
+
+// jsonFields returns the JSON names of a struct type's fields
+func jsonFields(t reflect.Type) map[string]bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	fields := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		fields[name] = true
+	}
+	return fields
+}
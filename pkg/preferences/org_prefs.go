@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/secrets"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
 )
 
 type OrgPreferences struct {
@@ -15,42 +18,70 @@ type OrgPreferences struct {
 	LogLevel      string `json:"logLevel,omitempty"`
 	Experimental  bool   `json:"experimental,omitempty"`
 	SupportURL    string `json:"supportUrl,omitempty"`
+	BackupCount   int    `json:"backupCount,omitempty"` // Backups kept on each project save, zero for the default, negative for none
 }
 
 func (o *OrgPreferences) String() string {
 	bytes, _ := json.Marshal(o)
-	return string(bytes)
+	return secrets.Redact(string(bytes))
+}
+
+// Validate returns a validation.ValidationError for the first field that
+// holds a value the app cannot use
+func (o *OrgPreferences) Validate() error {
+	if o.LogLevel != "" {
+		if err := validation.ValidChoice("logLevel", o.LogLevel, "debug", "info", "warn", "error"); err != nil {
+			return err
+		}
+	}
+	if o.SupportURL != "" {
+		if err := validation.ValidURL("supportUrl", o.SupportURL); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // NewOrgPreferences creates a new OrgPreferences instance with default values
+// for the app's identity
 func NewOrgPreferences() *OrgPreferences {
-	return &OrgPreferences{
-		Version:       "1.0",
+	id := GetAppId()
+	prefs := &OrgPreferences{
+		Version:       CurrentVersions[OrgFile],
 		Telemetry:     false,
 		Theme:         "dark",
 		Language:      "en",
-		DeveloperName: configOrgName,
+		DeveloperName: id.OrgName,
 		LogLevel:      "info",
 		Experimental:  false,
-		SupportURL:    "https://" + configBaseDomain + "/support",
 	}
+	if id.Domain != "" {
+		prefs.SupportURL = "https://" + id.Domain + "/support"
+	}
+	return prefs
 }
 
-// The app has only a single instance of OrgPreferences
-var theOrg = NewOrgPreferences()
-
+// GetOrgPreferences reads the org preferences with any values the org policy
+// locks in place
 func GetOrgPreferences() (OrgPreferences, error) {
 	path := getOrgPrefsPath()
 
+	policy, err := LoadPolicy()
+	if err != nil {
+		return OrgPreferences{}, err
+	}
+
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		if err := SetOrgPreferences(theOrg); err != nil {
+		defaults := *NewOrgPreferences()
+		policy.apply(&defaults)
+		if err := SetOrgPreferences(&defaults); err != nil {
 			return OrgPreferences{}, err
 		}
 
-		return *theOrg, nil
+		return defaults, nil
 	}
 
-	data, err := os.ReadFile(path)
+	data, err := readPrefsFile(OrgFile, path)
 	if err != nil {
 		return OrgPreferences{}, err
 	}
@@ -59,14 +90,21 @@ func GetOrgPreferences() (OrgPreferences, error) {
 	if err := json.Unmarshal(data, &orgPrefs); err != nil {
 		return OrgPreferences{}, err
 	}
+	policy.apply(&orgPrefs)
 
 	return orgPrefs, nil
 }
 
+// SetOrgPreferences saves the org preferences. It returns a
+// validation.ValidationError if they change a field the org policy locks.
 func SetOrgPreferences(orgPrefs *OrgPreferences) error {
 	path := getOrgPrefsPath()
 
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+	policy, err := LoadPolicy()
+	if err != nil {
+		return err
+	}
+	if err := policy.check(orgPrefs); err != nil {
 		return err
 	}
 
@@ -74,8 +112,12 @@ func SetOrgPreferences(orgPrefs *OrgPreferences) error {
 	if err != nil {
 		return err
 	}
+	if data, err = keepUnknownFields(path, data, orgPrefs); err != nil {
+		return err
+	}
 
-	return os.WriteFile(path, data, 0644)
+	// The watcher rereads the file, so it must never see half of it
+	return writeAtomic(path, data)
 }
 
 func getOrgPrefsPath() string {
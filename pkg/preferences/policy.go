@@ -0,0 +1,179 @@
+package preferences
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
+)
+
+// Policy is set by an organization's administrators to pin preferences for
+// everyone. It is read from EnvName("policy") if set, otherwise from
+// policy.json in the system config folder. Without a file nothing is locked.
+type Policy struct {
+	Locked          map[string]json.RawMessage `json:"locked"`          // Pinned values by the JSON name of an org or user preference
+	AllowedRPCHosts []string                   `json:"allowedRpcHosts"` // Host patterns such as "*.example.com", empty to allow any
+	Path            string                     `json:"path"`            // The file the policy was read from, empty if there is none
+}
+
+// getPolicyPath returns where the policy file is looked for
+func getPolicyPath() string {
+	if path := os.Getenv(EnvName("policy")); path != "" {
+		return path
+	}
+
+	id := GetAppId()
+	folder := filepath.Join(id.OrgName, ToCamel(id.baseApp()))
+	switch runtime.GOOS {
+	case "darwin":
+		return filepath.Join("/Library", "Application Support", folder, "policy.json")
+	case "windows":
+		return filepath.Join(os.Getenv("ProgramData"), folder, "policy.json")
+	default:
+		return filepath.Join("/etc", folder, "policy.json")
+	}
+}
+
+// LoadPolicy reads the org policy. A policy that exists but cannot be read or
+// pins a value a preference cannot hold is an error, so a broken policy never
+// silently unlocks anything.
+func LoadPolicy() (*Policy, error) {
+	path := getPolicyPath()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Policy{Locked: map[string]json.RawMessage{}, AllowedRPCHosts: []string{}}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read org policy: %w", err)
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse org policy %s: %w", path, err)
+	}
+	policy.Path = path
+	if policy.Locked == nil {
+		policy.Locked = map[string]json.RawMessage{}
+	}
+	if policy.AllowedRPCHosts == nil {
+		policy.AllowedRPCHosts = []string{}
+	}
+
+	for name, pinned := range policy.Locked {
+		found := false
+		for _, prefs := range []interface{}{&OrgPreferences{}, &UserPreferences{}} {
+			if field, ok := prefsField(prefs, name); ok {
+				found = true
+				if err := json.Unmarshal(pinned, reflect.New(field.Type()).Interface()); err != nil {
+					return nil, fmt.Errorf("org policy %s: locked %s: %w", path, name, err)
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("org policy %s: no preference named %s", path, name)
+		}
+	}
+
+	return &policy, nil
+}
+
+// LockedFields returns the JSON names of the locked preferences, sorted
+func (p *Policy) LockedFields() []string {
+	fields := make([]string, 0, len(p.Locked))
+	for name := range p.Locked {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// IsLocked reports whether the policy pins the named preference
+func (p *Policy) IsLocked(name string) bool {
+	_, ok := p.Locked[name]
+	return ok
+}
+
+// apply sets every locked field of the preferences struct prefs points to
+func (p *Policy) apply(prefs interface{}) {
+	for name, pinned := range p.Locked {
+		if field, ok := prefsField(prefs, name); ok {
+			value := reflect.New(field.Type())
+			if json.Unmarshal(pinned, value.Interface()) == nil {
+				field.Set(value.Elem())
+			}
+		}
+	}
+}
+
+// check returns a ValidationError for the first locked field of the
+// preferences struct prefs points to that differs from its pinned value
+func (p *Policy) check(prefs interface{}) error {
+	for _, name := range p.LockedFields() {
+		field, ok := prefsField(prefs, name)
+		if !ok {
+			continue
+		}
+		value := reflect.New(field.Type())
+		if json.Unmarshal(p.Locked[name], value.Interface()) != nil {
+			continue
+		}
+		if !reflect.DeepEqual(field.Interface(), value.Elem().Interface()) {
+			return validation.ValidationError{Field: name, Problem: "is locked by org policy"}
+		}
+	}
+	return nil
+}
+
+// CheckRPC returns a ValidationError if the policy does not allow the host of rpc
+func (p *Policy) CheckRPC(rpc string) error {
+	if len(p.AllowedRPCHosts) == 0 {
+		return nil
+	}
+
+	u, err := url.Parse(strings.TrimSpace(rpc))
+	if err != nil {
+		return validation.ValidationError{Field: "rpc", Problem: "not a valid URL"}
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, pattern := range p.AllowedRPCHosts {
+		if matched, _ := path.Match(strings.ToLower(pattern), host); matched {
+			return nil
+		}
+	}
+	return validation.ValidationError{Field: "rpc", Problem: fmt.Sprintf("host %s is not allowed by org policy", host)}
+}
+
+// checkUser checks both the locked fields and the chains' RPC hosts
+func (p *Policy) checkUser(user *UserPreferences) error {
+	if err := p.check(user); err != nil {
+		return err
+	}
+	for _, chain := range user.Chains {
+		for _, rpc := range chain.RpcProviders {
+			if err := p.CheckRPC(rpc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// prefsField returns the field of the struct prefs points to whose JSON name is name
+func prefsField(prefs interface{}, name string) (reflect.Value, bool) {
+	v := reflect.ValueOf(prefs).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		tag := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+		if tag == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
@@ -0,0 +1,110 @@
+package preferences
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
+)
+
+func writePolicy(t *testing.T, contents string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("Failed to write policy: %v", err)
+	}
+	t.Setenv(EnvName("policy"), path)
+}
+
+func TestPolicyLocksOrgFields(t *testing.T) {
+	defer SetConfigBaseForTest(t, t.TempDir())()
+	writePolicy(t, `{"locked": {"telemetry": true, "logLevel": "warn"}}`)
+
+	org, err := GetOrgPreferences()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !org.Telemetry || org.LogLevel != "warn" {
+		t.Errorf("Expected the pinned values, got telemetry %v and log level %s", org.Telemetry, org.LogLevel)
+	}
+
+	org.Theme = "light"
+	if err := SetOrgPreferences(&org); err != nil {
+		t.Errorf("Changing an unlocked field should succeed, got %v", err)
+	}
+
+	org.LogLevel = "debug"
+	var invalid validation.ValidationError
+	if err := SetOrgPreferences(&org); !errors.As(err, &invalid) || invalid.Field != "logLevel" {
+		t.Errorf("Expected a ValidationError for logLevel, got %v", err)
+	}
+
+	reloaded, _ := GetOrgPreferences()
+	if reloaded.LogLevel != "warn" || reloaded.Theme != "light" {
+		t.Errorf("Expected the rejected change not to be saved, got %+v", reloaded)
+	}
+}
+
+func TestPolicyRestrictsRPCHosts(t *testing.T) {
+	defer SetConfigBaseForTest(t, t.TempDir())()
+	writePolicy(t, `{"locked": {"theme": "dark"}, "allowedRpcHosts": ["localhost", "*.example.com"]}`)
+
+	user, err := GetUserPreferences()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	user.Chains = []Chain{{Chain: "mainnet", ChainId: 1, RpcProviders: []string{"https://rpc.example.com", "http://localhost:8545"}}}
+	if err := SetUserPreferences(&user); err != nil {
+		t.Errorf("Allowed hosts should be accepted, got %v", err)
+	}
+
+	user.Chains[0].RpcProviders = []string{"https://rpc.elsewhere.org"}
+	var invalid validation.ValidationError
+	if err := SetUserPreferences(&user); !errors.As(err, &invalid) || invalid.Field != "rpc" {
+		t.Errorf("Expected a ValidationError for the RPC host, got %v", err)
+	}
+
+	user.Chains = nil
+	user.Theme = "light"
+	if err := SetUserPreferences(&user); !errors.As(err, &invalid) || invalid.Field != "theme" {
+		t.Errorf("Expected a ValidationError for theme, got %v", err)
+	}
+}
+
+func TestPolicyErrors(t *testing.T) {
+	defer SetConfigBaseForTest(t, t.TempDir())()
+
+	t.Setenv(EnvName("policy"), filepath.Join(t.TempDir(), "missing.json"))
+	policy, err := LoadPolicy()
+	if err != nil || len(policy.LockedFields()) != 0 {
+		t.Errorf("A missing policy should lock nothing, got %v", err)
+	}
+
+	for _, contents := range []string{`{not json`, `{"locked": {"nonsense": 1}}`, `{"locked": {"telemetry": "yes"}}`} {
+		writePolicy(t, contents)
+		if _, err := LoadPolicy(); err == nil {
+			t.Errorf("Expected %s to be rejected", contents)
+		}
+		if _, err := GetOrgPreferences(); err == nil {
+			t.Errorf("A broken policy should stop the preferences loading: %s", contents)
+		}
+	}
+}
+
+func TestResolverPolicyLayer(t *testing.T) {
+	writePolicy(t, `{"locked": {"experimental": false, "theme": "dark"}}`)
+	t.Setenv(EnvName("theme"), "light")
+
+	r := NewResolver(OrgPreferences{}, UserPreferences{Theme: "solarized"}, nil)
+	theme, _ := r.Resolve("theme")
+	if theme.Value != "dark" || theme.Source != SourcePolicy {
+		t.Errorf("Expected the policy to win, got %s from %s", theme.Value, theme.Source)
+	}
+	experimental, ok := r.Resolve("experimental")
+	if !ok || experimental.Value != "false" || experimental.Source != SourcePolicy {
+		t.Errorf("Expected a pinned false to resolve, got %+v", experimental)
+	}
+}
@@ -5,13 +5,19 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
+// Preferences holds the loaded org, user and app preferences. Reload replaces
+// the org and user preferences from the watcher's goroutine, and the bindings
+// and the automation server change the app preferences from theirs, so
+// everything reads and writes them through the getters and setters below.
 type Preferences struct {
-	Org  OrgPreferences  `json:"org"`
-	User UserPreferences `json:"user"`
-	App  AppPreferences  `json:"app"`
-	Path string          `json:"-"` // Not serialized, in-memory only
+	Org   OrgPreferences  `json:"org"`
+	User  UserPreferences `json:"user"`
+	App   AppPreferences  `json:"app"`
+	Path  string          `json:"-"` // Not serialized, in-memory only
+	mutex sync.RWMutex    // Guards Org, User and App
 }
 
 // NewPreferences creates a new Preferences instance with default values
@@ -66,25 +72,70 @@ func (p *Preferences) Save() error {
 	return nil
 }
 
-// GetOrgPreferences returns the organization preferences
-func (p *Preferences) GetOrgPreferences() *OrgPreferences {
-	return &p.Org
+// GetOrg returns a copy of the organization preferences
+func (p *Preferences) GetOrg() OrgPreferences {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.Org
 }
 
-// GetUserPreferences returns the user preferences
-func (p *Preferences) GetUserPreferences() *UserPreferences {
-	return &p.User
+// SetOrg replaces the organization preferences
+func (p *Preferences) SetOrg(org OrgPreferences) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.Org = org
 }
 
-// GetAppPreferences returns the application preferences
+// GetUser returns a copy of the user preferences
+func (p *Preferences) GetUser() UserPreferences {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.User
+}
+
+// SetUser replaces the user preferences
+func (p *Preferences) SetUser(user UserPreferences) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.User = user
+}
+
+// GetApp returns a copy of the app preferences
+func (p *Preferences) GetApp() AppPreferences {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.App.clone()
+}
+
+// SetApp replaces the app preferences
+func (p *Preferences) SetApp(app AppPreferences) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.App = app.clone()
+}
+
+// UpdateApp changes the app preferences with fn while holding the lock and
+// returns a copy of the result. fn works on a copy, so copies handed out
+// earlier never change under their readers.
+func (p *Preferences) UpdateApp(fn func(app *AppPreferences)) AppPreferences {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	app := p.App.clone()
+	fn(&app)
+	p.App = app
+	return app.clone()
+}
+
+// GetAppPreferences returns a copy of the application preferences
 func (p *Preferences) GetAppPreferences() *AppPreferences {
-	return &p.App
+	app := p.GetApp()
+	return &app
 }
 
 // SetMenuCollapsed updates the menu collapsed state and saves the preferences
 func (p *Preferences) SetMenuCollapsed(val bool) error {
-	if p.App.MenuCollapsed != val {
-		p.App.MenuCollapsed = val
+	if p.GetApp().MenuCollapsed != val {
+		p.UpdateApp(func(app *AppPreferences) { app.MenuCollapsed = val })
 		return p.Save()
 	}
 	return nil
@@ -92,8 +143,8 @@ func (p *Preferences) SetMenuCollapsed(val bool) error {
 
 // SetHelpCollapsed updates the help collapsed state and saves the preferences
 func (p *Preferences) SetHelpCollapsed(val bool) error {
-	if p.App.HelpCollapsed != val {
-		p.App.HelpCollapsed = val
+	if p.GetApp().HelpCollapsed != val {
+		p.UpdateApp(func(app *AppPreferences) { app.HelpCollapsed = val })
 		return p.Save() // Immediately save changes
 	}
 	return nil
@@ -101,29 +152,10 @@ func (p *Preferences) SetHelpCollapsed(val bool) error {
 
 // AddRecentProject adds a project to the recently used list
 func (p *Preferences) AddRecentProject(path string) error {
-	// Check if the path is already in the list
-	for i, recent := range p.App.RecentProjects {
-		if recent == path {
-			// If it's already in the list but not at the top, move it to the top
-			if i > 0 {
-				// Remove from current position
-				p.App.RecentProjects = append(p.App.RecentProjects[:i], p.App.RecentProjects[i+1:]...)
-				// Add to the beginning
-				p.App.RecentProjects = append([]string{path}, p.App.RecentProjects...)
-				return p.Save()
-			}
-			return nil // Already at the top, no need to save
-		}
-	}
-
-	// Not in the list, add it to the beginning
-	p.App.RecentProjects = append([]string{path}, p.App.RecentProjects...)
-
-	// Trim the list if it's too long
-	maxRecent := 10 // Maximum number of recent projects to keep
-	if len(p.App.RecentProjects) > maxRecent {
-		p.App.RecentProjects = p.App.RecentProjects[:maxRecent]
+	added := false
+	p.UpdateApp(func(app *AppPreferences) { added = app.AddRecentProject(path) })
+	if added {
+		return p.Save()
 	}
-
-	return p.Save()
+	return nil
 }
@@ -0,0 +1,138 @@
+package preferences
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
+)
+
+// DefaultProfile is the profile whose user preferences live directly in the
+// config base, where they were kept before profiles existed
+const DefaultProfile = "default"
+
+var (
+	// ErrProfileExists is returned when creating a profile that already exists
+	ErrProfileExists = errors.New("profile already exists")
+	// ErrProfileNotFound is returned when using a profile that does not exist
+	ErrProfileNotFound = errors.New("profile not found")
+)
+
+// profileNames are also folder names, so they are kept to safe characters
+var profileNames = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _-]{0,63}$`)
+
+var (
+	activeProfile = DefaultProfile
+	profileMutex  sync.RWMutex
+)
+
+// GetProfile returns the profile whose user preferences are read and written
+func GetProfile() string {
+	profileMutex.RLock()
+	defer profileMutex.RUnlock()
+	return activeProfile
+}
+
+// SetProfile makes GetUserPreferences and SetUserPreferences use the named
+// profile's user preferences. An empty name selects the default profile.
+func SetProfile(name string) error {
+	if name == "" {
+		name = DefaultProfile
+	}
+	if !profileExists(name) {
+		return fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+
+	profileMutex.Lock()
+	defer profileMutex.Unlock()
+	activeProfile = name
+	return nil
+}
+
+// UseLastProfile selects the profile the app last used, falling back to the
+// default profile if it has since been deleted
+func UseLastProfile() error {
+	appPrefs, err := GetAppPreferences()
+	if err != nil {
+		return err
+	}
+	if err := SetProfile(appPrefs.Profile); errors.Is(err, ErrProfileNotFound) {
+		return SetProfile(DefaultProfile)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListProfiles returns the default profile followed by the named profiles,
+// sorted
+func ListProfiles() ([]string, error) {
+	entries, err := os.ReadDir(getProfilesFolder())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != DefaultProfile && profileNames.MatchString(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultProfile}, names...), nil
+}
+
+// CreateProfile adds a profile. Its user preferences start from the defaults
+// when it is first used.
+func CreateProfile(name string) error {
+	if !profileNames.MatchString(name) {
+		return validation.ValidationError{Field: "profile", Problem: "must be letters, digits, spaces, dashes or underscores"}
+	}
+	if profileExists(name) {
+		return fmt.Errorf("%w: %s", ErrProfileExists, name)
+	}
+	return os.MkdirAll(getProfileFolder(name), 0755)
+}
+
+// DeleteProfile removes a profile and its preferences. Neither the default
+// profile nor the one in use can be deleted.
+func DeleteProfile(name string) error {
+	if name == DefaultProfile || name == "" {
+		return validation.ValidationError{Field: "profile", Problem: "the default profile cannot be deleted"}
+	}
+	if name == GetProfile() {
+		return validation.ValidationError{Field: "profile", Problem: "the profile in use cannot be deleted"}
+	}
+	if !profileExists(name) {
+		return fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+	return os.RemoveAll(getProfileFolder(name))
+}
+
+func profileExists(name string) bool {
+	if name == DefaultProfile {
+		return true
+	}
+	if !profileNames.MatchString(name) {
+		return false
+	}
+	info, err := os.Stat(getProfileFolder(name))
+	return err == nil && info.IsDir()
+}
+
+func getProfilesFolder() string {
+	return filepath.Join(getConfigBase(), "profiles")
+}
+
+// getProfileFolder returns the folder holding a profile's preferences
+func getProfileFolder(name string) string {
+	if name == DefaultProfile {
+		return getConfigBase()
+	}
+	return filepath.Join(getProfilesFolder(), name)
+}
@@ -0,0 +1,98 @@
+package preferences
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
+)
+
+func TestProfilesKeepSeparateUserPreferences(t *testing.T) {
+	defer SetConfigBaseForTest(t, t.TempDir())()
+
+	user, _ := GetUserPreferences()
+	user.Name = "Research"
+	if err := SetUserPreferences(&user); err != nil {
+		t.Fatalf("Failed to save default profile: %v", err)
+	}
+
+	if err := SetProfile("testnet-dev"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("Expected ErrProfileNotFound for a missing profile, got %v", err)
+	}
+	if err := CreateProfile("testnet-dev"); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	if err := CreateProfile("testnet-dev"); !errors.Is(err, ErrProfileExists) {
+		t.Errorf("Expected ErrProfileExists, got %v", err)
+	}
+	var invalid validation.ValidationError
+	if err := CreateProfile("../escape"); !errors.As(err, &invalid) {
+		t.Errorf("Expected a ValidationError for an unsafe name, got %v", err)
+	}
+
+	if err := SetProfile("testnet-dev"); err != nil {
+		t.Fatalf("Failed to switch profile: %v", err)
+	}
+	dev, _ := GetUserPreferences()
+	if dev.Name != "" {
+		t.Errorf("A new profile should start from the defaults, got name %q", dev.Name)
+	}
+	if filepath.Base(filepath.Dir(getUserPrefsPath())) != "testnet-dev" {
+		t.Errorf("Expected the profile's own file, got %s", getUserPrefsPath())
+	}
+	dev.Name = "Dev"
+	_ = SetUserPreferences(&dev)
+
+	if err := DeleteProfile("testnet-dev"); !errors.As(err, &invalid) {
+		t.Errorf("The profile in use should not be deletable, got %v", err)
+	}
+
+	_ = SetProfile("")
+	if user, _ := GetUserPreferences(); user.Name != "Research" {
+		t.Errorf("Expected the default profile's preferences, got %q", user.Name)
+	}
+	if names, _ := ListProfiles(); !reflect.DeepEqual(names, []string{DefaultProfile, "testnet-dev"}) {
+		t.Errorf("Unexpected profiles %v", names)
+	}
+	if err := DeleteProfile("testnet-dev"); err != nil {
+		t.Errorf("Failed to delete profile: %v", err)
+	}
+	if names, _ := ListProfiles(); len(names) != 1 {
+		t.Errorf("Expected only the default profile left, got %v", names)
+	}
+}
+
+func TestSwitchRecentProjects(t *testing.T) {
+	prefs := AppPreferences{RecentProjects: []string{"mainnet.json"}}
+
+	prefs.SwitchRecentProjects("testnet")
+	if prefs.Profile != "testnet" || len(prefs.RecentProjects) != 0 {
+		t.Errorf("Expected an empty list for a new profile, got %+v", prefs)
+	}
+	prefs.AddRecentProject("testnet.json")
+
+	prefs.SwitchRecentProjects(DefaultProfile)
+	if prefs.Profile != "" || !reflect.DeepEqual(prefs.RecentProjects, []string{"mainnet.json"}) {
+		t.Errorf("Expected the default profile's projects back, got %+v", prefs)
+	}
+	if !reflect.DeepEqual(prefs.ProfileRecents["testnet"], []string{"testnet.json"}) {
+		t.Errorf("Expected the testnet projects to be put away, got %v", prefs.ProfileRecents)
+	}
+}
+
+func TestUseLastProfile(t *testing.T) {
+	defer SetConfigBaseForTest(t, t.TempDir())()
+
+	_ = CreateProfile("research")
+	_ = SetAppPreferences(&AppPreferences{Version: "1.0", Profile: "research"})
+	if err := UseLastProfile(); err != nil || GetProfile() != "research" {
+		t.Errorf("Expected the last used profile, got %s and %v", GetProfile(), err)
+	}
+
+	_ = SetAppPreferences(&AppPreferences{Version: "1.0", Profile: "deleted"})
+	if err := UseLastProfile(); err != nil || GetProfile() != DefaultProfile {
+		t.Errorf("Expected the default profile for a deleted one, got %s and %v", GetProfile(), err)
+	}
+}
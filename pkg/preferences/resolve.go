@@ -0,0 +1,185 @@
+package preferences
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Source names a layer of configuration. Later layers override earlier ones
+// in the order of Layers.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceOrg     Source = "org"
+	SourceUser    Source = "user"
+	SourceProject Source = "project"
+	SourceEnv     Source = "env"
+	SourcePolicy  Source = "policy"
+)
+
+// Layers lists the configuration sources from lowest to highest precedence
+var Layers = []Source{SourceDefault, SourceOrg, SourceUser, SourceProject, SourceEnv, SourcePolicy}
+
+// LayerValue is the value one source gives a setting
+type LayerValue struct {
+	Source Source `json:"source"`
+	Value  string `json:"value"`
+}
+
+// Setting is the effective value of a setting, the source it came from, and
+// every source that gives it a value, from lowest to highest precedence
+type Setting struct {
+	Key    string       `json:"key"`
+	Value  string       `json:"value"`
+	Source Source       `json:"source"`
+	Layers []LayerValue `json:"layers"`
+}
+
+// Overridden reports whether a higher source replaced a value set by a lower one
+func (s Setting) Overridden() bool {
+	return len(s.Layers) > 1
+}
+
+// Resolver merges the configuration layers. Build one with NewResolver
+// whenever any layer changes; it does not watch its sources.
+type Resolver struct {
+	layers map[Source]map[string]string
+	keys   []string
+}
+
+// NewResolver resolves settings from the built-in defaults, the org and user
+// preferences, a project's preferences, environment variables and finally the
+// org policy. Project preferences may be nil when no project is open. An
+// environment variable overrides a setting when named EnvName(key).
+func NewResolver(org OrgPreferences, user UserPreferences, project map[string]string) *Resolver {
+	r := &Resolver{layers: make(map[Source]map[string]string)}
+
+	defaults := scalarFields(NewUserPreferences())
+	for key, value := range scalarFields(NewOrgPreferences()) {
+		defaults[key] = value
+	}
+	r.layers[SourceDefault] = defaults
+	r.layers[SourceOrg] = scalarFields(&org)
+	r.layers[SourceUser] = scalarFields(&user)
+
+	r.layers[SourceProject] = make(map[string]string, len(project))
+	for key, value := range project {
+		r.layers[SourceProject][key] = value
+	}
+
+	known := make(map[string]bool)
+	for _, values := range r.layers {
+		for key := range values {
+			known[key] = true
+		}
+	}
+
+	r.layers[SourceEnv] = make(map[string]string)
+	for key := range known {
+		if value, ok := os.LookupEnv(EnvName(key)); ok {
+			r.layers[SourceEnv][key] = value
+		}
+	}
+
+	// A policy that cannot be read is reported when the preferences are loaded
+	r.layers[SourcePolicy] = make(map[string]string)
+	if policy, err := LoadPolicy(); err == nil {
+		for key, pinned := range policy.Locked {
+			var value interface{}
+			if json.Unmarshal(pinned, &value) == nil {
+				r.layers[SourcePolicy][key] = fmt.Sprint(value)
+				known[key] = true
+			}
+		}
+	}
+
+	for key := range known {
+		r.keys = append(r.keys, key)
+	}
+	sort.Strings(r.keys)
+	return r
+}
+
+// Resolve returns the effective value of key, or false if no source sets it
+func (r *Resolver) Resolve(key string) (Setting, bool) {
+	setting := Setting{Key: key, Layers: []LayerValue{}}
+	for _, source := range Layers {
+		if value, ok := r.layers[source][key]; ok {
+			setting.Layers = append(setting.Layers, LayerValue{Source: source, Value: value})
+			setting.Value = value
+			setting.Source = source
+		}
+	}
+	return setting, len(setting.Layers) > 0
+}
+
+// All returns every setting any source gives a value, sorted by key
+func (r *Resolver) All() []Setting {
+	settings := make([]Setting, 0, len(r.keys))
+	for _, key := range r.keys {
+		if setting, ok := r.Resolve(key); ok {
+			settings = append(settings, setting)
+		}
+	}
+	return settings
+}
+
+// EnvName returns the environment variable that overrides key, for example
+// TRUEBLOCKS_CODEGEN_BACKUP_COUNT for backupCount. The prefix follows the
+// app's identity.
+func EnvName(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	id := GetAppId()
+	return envWord(id.OrgName) + "_" + envWord(id.baseApp()) + "_" + b.String()
+}
+
+// envWord makes a name usable in an environment variable, for example
+// MY_APP for My App
+func envWord(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || (!unicode.IsLetter(r) && !unicode.IsDigit(r)) {
+			return '_'
+		}
+		return unicode.ToUpper(r)
+	}, name)
+}
+
+// scalarFields returns the fields of a preferences struct that hold a single
+// value, by JSON name, formatted as strings. Unset fields and the file's
+// version are left out.
+func scalarFields(prefs interface{}) map[string]string {
+	fields := make(map[string]string)
+
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return fields
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fields
+	}
+
+	for key, value := range raw {
+		if key == "version" {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			fields[key] = v
+		case bool, float64:
+			fields[key] = fmt.Sprint(v)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,72 @@
+package preferences
+
+import (
+	"testing"
+)
+
+func TestResolverPrecedence(t *testing.T) {
+	org := OrgPreferences{Theme: "light", Language: "fr", BackupCount: 3}
+	user := UserPreferences{Theme: "solarized", Name: "Jay"}
+	project := map[string]string{"theme": "contrast", "topic": "gardens"}
+	t.Setenv(EnvName("language"), "de")
+
+	r := NewResolver(org, user, project)
+
+	cases := []struct {
+		key    string
+		value  string
+		source Source
+		layers int
+	}{
+		{"theme", "contrast", SourceProject, 4},
+		{"language", "de", SourceEnv, 3},
+		{"backupCount", "3", SourceOrg, 1},
+		{"name", "Jay", SourceUser, 1},
+		{"topic", "gardens", SourceProject, 1},
+		{"logLevel", "info", SourceDefault, 1},
+	}
+	for _, c := range cases {
+		setting, ok := r.Resolve(c.key)
+		if !ok {
+			t.Errorf("%s: expected a value", c.key)
+			continue
+		}
+		if setting.Value != c.value || setting.Source != c.source || len(setting.Layers) != c.layers {
+			t.Errorf("%s: expected %s from %s over %d layers, got %s from %s over %d", c.key, c.value, c.source, c.layers, setting.Value, setting.Source, len(setting.Layers))
+		}
+	}
+
+	theme, _ := r.Resolve("theme")
+	if !theme.Overridden() || theme.Layers[0].Source != SourceDefault || theme.Layers[0].Value != "dark" {
+		t.Errorf("Expected the default theme at the bottom of the layers, got %+v", theme.Layers)
+	}
+
+	if _, ok := r.Resolve("nonsense"); ok {
+		t.Error("A key no source sets should not resolve")
+	}
+	if _, ok := r.Resolve("version"); ok {
+		t.Error("The file version is not a setting")
+	}
+}
+
+func TestResolverAll(t *testing.T) {
+	r := NewResolver(OrgPreferences{}, UserPreferences{}, nil)
+
+	settings := r.All()
+	for i := 1; i < len(settings); i++ {
+		if settings[i-1].Key >= settings[i].Key {
+			t.Fatalf("Expected settings sorted by key, got %s before %s", settings[i-1].Key, settings[i].Key)
+		}
+	}
+	for _, setting := range settings {
+		if setting.Source != SourceDefault {
+			t.Errorf("With empty files, %s should come from the defaults, got %s", setting.Key, setting.Source)
+		}
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if got := EnvName("backupCount"); got != "TRUEBLOCKS_CODEGEN_BACKUP_COUNT" {
+		t.Errorf("Expected TRUEBLOCKS_CODEGEN_BACKUP_COUNT, got %s", got)
+	}
+}
@@ -4,9 +4,11 @@ import "testing"
 
 func SetConfigBaseForTest(t *testing.T, path string) func() {
 	t.Helper()
-	original := configBase
+	original, profile := configBase, GetProfile()
 	configBase = path
+	activeProfile = DefaultProfile
 	return func() {
 		configBase = original
+		activeProfile = profile
 	}
 }
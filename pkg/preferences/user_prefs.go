@@ -2,10 +2,21 @@ package preferences
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/secrets"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
 )
 
+func init() {
+	// From 1.1 the credentials in RPC URLs are kept in the keystore
+	RegisterMigration(UserFile, "1.0", "1.1", stashRawCredentials)
+}
+
 type UserPreferences struct {
 	Version  string  `json:"version,omitempty"`
 	Theme    string  `json:"theme,omitempty"`
@@ -17,7 +28,7 @@ type UserPreferences struct {
 
 func NewUserPreferences() *UserPreferences {
 	return &UserPreferences{
-		Version:  "1.0",
+		Version:  CurrentVersions[UserFile],
 		Theme:    "dark",
 		Language: "en",
 		Chains:   []Chain{},
@@ -26,14 +37,55 @@ func NewUserPreferences() *UserPreferences {
 
 func (u *UserPreferences) String() string {
 	bytes, _ := json.Marshal(u)
-	return string(bytes)
+	return secrets.Redact(string(bytes))
 }
 
+// Validate returns a validation.ValidationError if the email, a chain or an
+// RPC provider is malformed, or if the org policy does not allow an RPC host.
+// An empty email is allowed since the wizard asks for it later.
+func (u *UserPreferences) Validate() error {
+	if u.Email != "" {
+		if err := validation.ValidEmail(u.Email); err != nil {
+			return err
+		}
+	}
+
+	policy, err := LoadPolicy()
+	if err != nil {
+		return err
+	}
+	for _, chain := range u.Chains {
+		if strings.TrimSpace(chain.Chain) == "" {
+			return validation.ValidationError{Field: "chain", Problem: "name cannot be empty"}
+		}
+		if chain.ChainId == 0 {
+			return validation.ValidationError{Field: "chainId", Problem: fmt.Sprintf("chain %s needs a chain ID", chain.Chain)}
+		}
+		for _, rpc := range chain.RpcProviders {
+			if err := validation.ValidRPC(rpc); err != nil {
+				return err
+			}
+			if err := policy.CheckRPC(rpc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GetUserPreferences reads the user preferences with any values the org
+// policy locks in place
 func GetUserPreferences() (UserPreferences, error) {
 	path := getUserPrefsPath()
 
+	policy, err := LoadPolicy()
+	if err != nil {
+		return UserPreferences{}, err
+	}
+
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		defaults := NewUserPreferences()
+		policy.apply(defaults)
 
 		if err := SetUserPreferences(defaults); err != nil {
 			return UserPreferences{}, err
@@ -42,7 +94,7 @@ func GetUserPreferences() (UserPreferences, error) {
 		return *defaults, nil
 	}
 
-	data, err := os.ReadFile(path)
+	data, err := readPrefsFile(UserFile, path)
 	if err != nil {
 		return UserPreferences{}, err
 	}
@@ -56,24 +108,104 @@ func GetUserPreferences() (UserPreferences, error) {
 		userPrefs.Chains = []Chain{}
 	}
 
+	// Credentials the migration could not move because the keystore was
+	// locked, or that were added by hand, move as soon as it is open
+	if chains, changed, err := stashCredentials(userPrefs.Chains); err == nil && changed {
+		userPrefs.Chains = chains
+		if err := writeUserPreferences(path, &userPrefs); err != nil {
+			return UserPreferences{}, err
+		}
+	}
+	policy.apply(&userPrefs)
+
 	return userPrefs, nil
 }
 
+// SetUserPreferences saves the user preferences. It returns a
+// validation.ValidationError if they change a field the org policy locks or
+// use an RPC host it does not allow. Credentials in the RPC URLs are moved
+// into the default keystore, and userPrefs is left holding references to
+// them, so saving fails if they cannot be.
 func SetUserPreferences(userPrefs *UserPreferences) error {
 	path := getUserPrefsPath()
 
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+	policy, err := LoadPolicy()
+	if err != nil {
+		return err
+	}
+	if err := policy.checkUser(userPrefs); err != nil {
 		return err
 	}
 
+	chains, changed, err := stashCredentials(userPrefs.Chains)
+	if err != nil {
+		return fmt.Errorf("failed to move RPC credentials into the keystore: %w", err)
+	}
+	if changed {
+		userPrefs.Chains = chains
+	}
+	return writeUserPreferences(path, userPrefs)
+}
+
+func writeUserPreferences(path string, userPrefs *UserPreferences) error {
 	data, err := json.MarshalIndent(userPrefs, "", "  ")
 	if err != nil {
 		return err
 	}
+	if data, err = keepUnknownFields(path, data, userPrefs); err != nil {
+		return err
+	}
+
+	// The watcher rereads the file, so it must never see half of it
+	return writeAtomic(path, data)
+}
+
+// stashCredentials returns a copy of chains with the credentials in their RPC
+// URLs moved into the default keystore, and whether there were any
+func stashCredentials(chains []Chain) ([]Chain, bool, error) {
+	stashed := make([]Chain, len(chains))
+	changed := false
+	for i, chain := range chains {
+		stashed[i] = chain
+		stashed[i].RpcProviders = make([]string, len(chain.RpcProviders))
+		for j, rpc := range chain.RpcProviders {
+			reference, err := secrets.StashURL(secrets.Default(), rpc)
+			if err != nil {
+				return nil, false, err
+			}
+			changed = changed || reference != rpc
+			stashed[i].RpcProviders[j] = reference
+		}
+	}
+	return stashed, changed, nil
+}
 
-	return os.WriteFile(path, data, 0644)
+// stashRawCredentials migrates user preferences to 1.1 by moving the
+// credentials in the chains' RPC URLs into the default keystore. Without an
+// open, unlocked keystore they stay for GetUserPreferences to move later.
+func stashRawCredentials(fields map[string]interface{}) error {
+	chains, _ := fields["chains"].([]interface{})
+	for _, c := range chains {
+		chain, _ := c.(map[string]interface{})
+		rpcs, _ := chain["rpcProviders"].([]interface{})
+		for i, r := range rpcs {
+			rpc, ok := r.(string)
+			if !ok {
+				continue
+			}
+			reference, err := secrets.StashURL(secrets.Default(), rpc)
+			if errors.Is(err, secrets.ErrNoKeystore) || errors.Is(err, secrets.ErrLocked) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			rpcs[i] = reference
+		}
+	}
+	return nil
 }
 
 func getUserPrefsPath() string {
-	return filepath.Join(getConfigBase(), "user_prefs.json")
+	return filepath.Join(getProfileFolder(GetProfile()), "user_prefs.json")
 }
@@ -31,7 +31,7 @@ func TestGetUserPreferences(t *testing.T) {
 
 		path := getUserPrefsPath()
 		expected := UserPreferences{
-			Version:  "1.0",
+			Version:  CurrentVersions[UserFile],
 			Theme:    "light",
 			Language: "fr",
 			Chains: []Chain{
@@ -0,0 +1,178 @@
+package preferences
+
+import (
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher watches the org and user preference files, and the org policy, for
+// changes made by other programs. Its events are coalesced so an editor that
+// writes a file in several steps causes a single reload.
+type Watcher struct {
+	fs       *fsnotify.Watcher
+	delay    time.Duration
+	onChange func([]PrefsFile)
+	onError  func(error)
+	done     chan struct{}
+}
+
+// NewWatcher starts watching the preference files. After a file changes and
+// nothing else changes for delay, onChange is called with the files to
+// reload. A change to the policy reloads both files since it can pin values
+// in either. Errors from the watcher are passed to onError.
+//
+// The user preferences watched are those of the profile in use when the
+// watcher starts. The app preferences are not watched. The app alone writes
+// them and its in-memory copy may be ahead of the file.
+func NewWatcher(delay time.Duration, onChange func([]PrefsFile), onError func(error)) (*Watcher, error) {
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	// Folders are watched rather than files because editors often save by renaming
+	if err := fs.Add(getConfigBase()); err != nil {
+		fs.Close()
+		return nil, err
+	}
+	// The user preferences of a named profile live in their own folder
+	if userDir := filepath.Dir(getUserPrefsPath()); userDir != filepath.Clean(getConfigBase()) {
+		if err := fs.Add(userDir); err != nil {
+			fs.Close()
+			return nil, err
+		}
+	}
+	policyDir := filepath.Dir(getPolicyPath())
+	if policyDir != filepath.Clean(getConfigBase()) {
+		// Most machines have no policy, so a missing folder is not an error
+		_ = fs.Add(policyDir)
+	}
+
+	w := &Watcher{
+		fs:       fs,
+		delay:    delay,
+		onChange: onChange,
+		onError:  onError,
+		done:     make(chan struct{}),
+	}
+	go w.loop()
+	return w, nil
+}
+
+// Close stops watching
+func (w *Watcher) Close() error {
+	err := w.fs.Close()
+	<-w.done
+	return err
+}
+
+// watchedFiles returns the preference files to reload when the file at path changes
+func watchedFiles(path string) []PrefsFile {
+	switch filepath.Clean(path) {
+	case filepath.Clean(getOrgPrefsPath()):
+		return []PrefsFile{OrgFile}
+	case filepath.Clean(getUserPrefsPath()):
+		return []PrefsFile{UserFile}
+	case filepath.Clean(getPolicyPath()):
+		return []PrefsFile{OrgFile, UserFile}
+	}
+	return nil
+}
+
+func (w *Watcher) loop() {
+	defer close(w.done)
+
+	pending := make(map[PrefsFile]bool)
+	var timer <-chan time.Time
+	for {
+		select {
+		case event, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+			for _, file := range watchedFiles(event.Name) {
+				pending[file] = true
+			}
+			if len(pending) > 0 && timer == nil {
+				timer = time.After(w.delay)
+			}
+		case <-timer:
+			timer = nil
+			var files []PrefsFile
+			for _, file := range []PrefsFile{OrgFile, UserFile} {
+				if pending[file] {
+					files = append(files, file)
+				}
+			}
+			pending = make(map[PrefsFile]bool)
+			w.onChange(files)
+		case err, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+			if w.onError != nil {
+				w.onError(err)
+			}
+		}
+	}
+}
+
+// Reload reads the given preference files again and replaces the copies in p
+// whose contents changed, returning the files that did. A file that cannot be
+// read or does not validate leaves its copy alone and returns an error. The
+// files are read without holding p's lock.
+func (p *Preferences) Reload(files []PrefsFile) ([]PrefsFile, error) {
+	var changed []PrefsFile
+	for _, file := range files {
+		switch file {
+		case OrgFile:
+			org, err := GetOrgPreferences()
+			if err != nil {
+				return changed, err
+			}
+			if p.swapOrg(org) {
+				changed = append(changed, file)
+			}
+		case UserFile:
+			user, err := GetUserPreferences()
+			if err == nil {
+				err = user.Validate()
+			}
+			if err != nil {
+				return changed, err
+			}
+			if p.swapUser(user) {
+				changed = append(changed, file)
+			}
+		}
+	}
+	return changed, nil
+}
+
+// swapOrg replaces the org preferences if they differ from org, reporting whether they did
+func (p *Preferences) swapOrg(org OrgPreferences) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if reflect.DeepEqual(org, p.Org) {
+		return false
+	}
+	p.Org = org
+	return true
+}
+
+// swapUser replaces the user preferences if they differ from user, reporting whether they did
+func (p *Preferences) swapUser(user UserPreferences) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if reflect.DeepEqual(user, p.User) {
+		return false
+	}
+	p.User = user
+	return true
+}
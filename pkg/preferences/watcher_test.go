@@ -0,0 +1,87 @@
+package preferences
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatcherReportsOutsideEdits(t *testing.T) {
+	defer SetConfigBaseForTest(t, t.TempDir())()
+	t.Setenv(EnvName("policy"), filepath.Join(t.TempDir(), "policy.json"))
+
+	if _, err := GetUserPreferences(); err != nil {
+		t.Fatalf("Failed to create user preferences: %v", err)
+	}
+
+	reloads := make(chan []PrefsFile, 4)
+	w, err := NewWatcher(20*time.Millisecond, func(files []PrefsFile) { reloads <- files }, nil)
+	if err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer w.Close()
+
+	// Several writes in a row are reported once
+	for _, theme := range []string{"light", "solarized"} {
+		data := []byte(`{"version": "1.0", "theme": "` + theme + `"}`)
+		if err := os.WriteFile(getUserPrefsPath(), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Other files in the folder are ignored
+	_ = os.WriteFile(filepath.Join(getConfigBase(), "notes.txt"), []byte("hi"), 0644)
+
+	select {
+	case files := <-reloads:
+		if !reflect.DeepEqual(files, []PrefsFile{UserFile}) {
+			t.Errorf("Expected only the user file to reload, got %v", files)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the reload")
+	}
+	select {
+	case files := <-reloads:
+		t.Errorf("Expected a single reload, got another for %v", files)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestReloadReplacesChangedSections(t *testing.T) {
+	defer SetConfigBaseForTest(t, t.TempDir())()
+	t.Setenv(EnvName("policy"), filepath.Join(t.TempDir(), "policy.json"))
+
+	org, _ := GetOrgPreferences()
+	user, _ := GetUserPreferences()
+	prefs := Preferences{Org: org, User: user}
+
+	changed, err := prefs.Reload([]PrefsFile{OrgFile, UserFile})
+	if err != nil || len(changed) != 0 {
+		t.Errorf("Expected nothing to change, got %v and %v", changed, err)
+	}
+
+	edited := []byte(`{"version": "1.0", "theme": "light", "email": "someone@example.com"}`)
+	if err := os.WriteFile(getUserPrefsPath(), edited, 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, err = prefs.Reload([]PrefsFile{OrgFile, UserFile})
+	if err != nil || !reflect.DeepEqual(changed, []PrefsFile{UserFile}) {
+		t.Fatalf("Expected the user section to change, got %v and %v", changed, err)
+	}
+	if prefs.User.Theme != "light" || prefs.User.Email != "someone@example.com" {
+		t.Errorf("Expected the edited values, got %+v", prefs.User)
+	}
+
+	// An edit that does not validate keeps the previous values
+	invalid := []byte(`{"version": "1.0", "theme": "dark", "email": "not an email"}`)
+	if err := os.WriteFile(getUserPrefsPath(), invalid, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := prefs.Reload([]PrefsFile{UserFile}); err == nil {
+		t.Error("Expected the invalid email to be rejected")
+	}
+	if prefs.User.Theme != "light" {
+		t.Errorf("Expected the previous values to be kept, got %+v", prefs.User)
+	}
+}
@@ -0,0 +1,183 @@
+// package project contains the data structures and methods for managing project files
+package project
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+)
+
+// autosaver tracks the recovery files the manager has written during this session
+type autosaver struct {
+	dir     string
+	written map[string]bool
+	stop    chan struct{}
+	mutex   sync.Mutex
+}
+
+// StartAutosave periodically writes every open project with unsaved changes,
+// including ones that have never been saved, to dir so they can be recovered
+// after a crash. Recovery files for projects that become clean are removed.
+func (m *Manager) StartAutosave(dir string, interval time.Duration) {
+	m.autosave.mutex.Lock()
+	defer m.autosave.mutex.Unlock()
+
+	if m.autosave.stop != nil {
+		close(m.autosave.stop)
+	}
+	m.autosave.dir = dir
+	stop := make(chan struct{})
+	m.autosave.stop = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := m.Autosave(); err != nil {
+					msgs.EmitError("Autosave failed", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// StopAutosave stops the background autosave. Recovery files already written are kept.
+func (m *Manager) StopAutosave() {
+	m.autosave.mutex.Lock()
+	defer m.autosave.mutex.Unlock()
+
+	if m.autosave.stop != nil {
+		close(m.autosave.stop)
+		m.autosave.stop = nil
+	}
+}
+
+// Autosave runs a single autosave pass immediately
+func (m *Manager) Autosave() error {
+	projects, _ := m.Snapshot()
+
+	m.autosave.mutex.Lock()
+	defer m.autosave.mutex.Unlock()
+
+	dir := m.autosave.dir
+	if dir == "" {
+		return nil
+	}
+
+	var firstErr error
+	for id, project := range projects {
+		if project.IsDirty() {
+			if err := WriteRecovery(dir, project); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			m.autosave.written[id] = true
+		} else if m.autosave.written[id] {
+			if err := RemoveRecovery(dir, id); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			delete(m.autosave.written, id)
+		}
+	}
+	return firstErr
+}
+
+// discardAutosave removes the recovery file written for id, if this session wrote one
+func (m *Manager) discardAutosave(id string) {
+	m.autosave.mutex.Lock()
+	defer m.autosave.mutex.Unlock()
+
+	if m.autosave.dir != "" && m.autosave.written[id] {
+		_ = RemoveRecovery(m.autosave.dir, id)
+		delete(m.autosave.written, id)
+	}
+}
+
+// PendingRecovery lists recovery files left behind by an earlier session
+func (m *Manager) PendingRecovery() ([]RecoveryEntry, error) {
+	m.autosave.mutex.Lock()
+	dir := m.autosave.dir
+	written := make(map[string]bool, len(m.autosave.written))
+	for id := range m.autosave.written {
+		written[id] = true
+	}
+	m.autosave.mutex.Unlock()
+
+	if dir == "" {
+		return []RecoveryEntry{}, nil
+	}
+
+	entries, err := ListRecovery(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]RecoveryEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !written[entry.ID] {
+			pending = append(pending, entry)
+		}
+	}
+	return pending, nil
+}
+
+// Recover opens the autosaved copy of the project with the given ID and makes
+// it the active project. An open copy of the same project is replaced only if
+// it has no unsaved changes of its own.
+func (m *Manager) Recover(id string) (*Project, error) {
+	m.autosave.mutex.Lock()
+	dir := m.autosave.dir
+	m.autosave.mutex.Unlock()
+
+	if dir == "" {
+		return nil, fmt.Errorf("autosave is not enabled")
+	}
+
+	project, err := LoadRecovery(dir, id)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	if existing, exists := m.openProjects[project.ID]; exists && existing.IsDirty() {
+		m.mutex.Unlock()
+		return nil, fmt.Errorf("project %s is open with unsaved changes", existing.GetName())
+	}
+	if _, exists := m.openProjects[project.ID]; !exists {
+		m.order = append(m.order, project.ID)
+	}
+	m.openProjects[project.ID] = project
+	trim := m.activate(project.ID)
+	m.mutex.Unlock()
+
+	_ = m.settle(project, trim) // a recovered project is always resident
+
+	// The recovered changes are still unsaved, so the recovery file now belongs to this session
+	m.autosave.mutex.Lock()
+	m.autosave.written[project.ID] = true
+	m.autosave.mutex.Unlock()
+
+	m.syncWatches()
+	msgs.EmitManager(ProjectRecovered)
+	return project, nil
+}
+
+// DiscardRecovery deletes the recovery file for the project with the given ID
+func (m *Manager) DiscardRecovery(id string) error {
+	m.autosave.mutex.Lock()
+	defer m.autosave.mutex.Unlock()
+
+	if m.autosave.dir == "" {
+		return nil
+	}
+	delete(m.autosave.written, id)
+	return RemoveRecovery(m.autosave.dir, id)
+}
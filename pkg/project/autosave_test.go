@@ -0,0 +1,102 @@
+package project_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+)
+
+// TestAutosaveRecovery simulates a crash by abandoning a manager with unsaved
+// work and recovering it from a second manager sharing the recovery folder
+func TestAutosaveRecovery(t *testing.T) {
+	recoveryDir := filepath.Join(t.TempDir(), "recovery")
+
+	crashed := project.NewManager()
+	crashed.StartAutosave(recoveryDir, time.Hour)
+	defer crashed.StopAutosave()
+
+	unsaved := crashed.New("New Project 1")
+	if err := unsaved.SetSection(project.NotesSection, project.Notes{Text: "do not lose me"}); err != nil {
+		t.Fatalf("Failed to set notes: %v", err)
+	}
+	if err := crashed.Autosave(); err != nil {
+		t.Fatalf("Autosave failed: %v", err)
+	}
+
+	if pending, _ := crashed.PendingRecovery(); len(pending) != 0 {
+		t.Errorf("A session should not offer to recover its own autosaves, got %d", len(pending))
+	}
+
+	restarted := project.NewManager()
+	restarted.StartAutosave(recoveryDir, time.Hour)
+	defer restarted.StopAutosave()
+
+	pending, err := restarted.PendingRecovery()
+	if err != nil {
+		t.Fatalf("Failed to list recovery files: %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != unsaved.GetID() || pending[0].Name != "New Project 1" {
+		t.Fatalf("Expected one recovery entry for the unsaved project, got %+v", pending)
+	}
+
+	recovered, err := restarted.Recover(pending[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to recover project: %v", err)
+	}
+	if restarted.Active() != recovered {
+		t.Error("Recovered project should be active")
+	}
+	if !recovered.IsDirty() {
+		t.Error("Recovered project should have unsaved changes")
+	}
+	notes, err := project.GetSection[project.Notes](recovered, project.NotesSection)
+	if err != nil || notes.Text != "do not lose me" {
+		t.Errorf("Expected recovered notes, got %q (%v)", notes.Text, err)
+	}
+
+	if err := recovered.SaveAs(filepath.Join(t.TempDir(), "recovered.json")); err != nil {
+		t.Fatalf("Failed to save recovered project: %v", err)
+	}
+	if err := restarted.Autosave(); err != nil {
+		t.Fatalf("Autosave failed: %v", err)
+	}
+
+	entries, err := project.ListRecovery(recoveryDir)
+	if err != nil {
+		t.Fatalf("Failed to list recovery files: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected recovery file to be removed once the project is saved, got %d", len(entries))
+	}
+}
+
+// TestRecoveryRejectsUnsafeIDs verifies that an ID from the frontend cannot
+// reach a file outside the recovery folder
+func TestRecoveryRejectsUnsafeIDs(t *testing.T) {
+	root := t.TempDir()
+	recoveryDir := filepath.Join(root, "recovery")
+	victim := filepath.Join(root, "victim.json")
+	if err := os.WriteFile(victim, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := project.NewManager()
+	m.StartAutosave(recoveryDir, time.Hour)
+	defer m.StopAutosave()
+
+	for _, id := range []string{"../victim", "..", "a/b", ""} {
+		if err := m.DiscardRecovery(id); !errors.Is(err, project.ErrInvalidRecoveryID) {
+			t.Errorf("Expected ErrInvalidRecoveryID discarding %q, got %v", id, err)
+		}
+		if _, err := m.Recover(id); !errors.Is(err, project.ErrInvalidRecoveryID) {
+			t.Errorf("Expected ErrInvalidRecoveryID recovering %q, got %v", id, err)
+		}
+	}
+	if _, err := os.Stat(victim); err != nil {
+		t.Errorf("The file outside the recovery folder should survive: %v", err)
+	}
+}
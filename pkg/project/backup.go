@@ -0,0 +1,187 @@
+// package project contains the data structures and methods for managing project files
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBackups is the number of previous versions kept next to a project file
+const DefaultBackups = 5
+
+var (
+	backupGenerations = DefaultBackups
+	backupMutex       sync.RWMutex
+)
+
+// SetBackupGenerations sets how many previous versions of a project file are
+// kept each time it is saved. Zero or less turns backups off.
+func SetBackupGenerations(n int) {
+	backupMutex.Lock()
+	defer backupMutex.Unlock()
+	backupGenerations = n
+}
+
+func getBackupGenerations() int {
+	backupMutex.RLock()
+	defer backupMutex.RUnlock()
+	return backupGenerations
+}
+
+// Backup describes one previous version of a project file. Generation 1 is
+// the most recent.
+type Backup struct {
+	Path       string `json:"path"`
+	Generation int    `json:"generation"`
+	SavedAt    string `json:"savedAt"`
+	Size       int64  `json:"size"`
+}
+
+func backupPath(path string, generation int) string {
+	return fmt.Sprintf("%s.bak.%d", path, generation)
+}
+
+// rotateBackups copies the file at path to generation 1, shifting older
+// generations up and dropping any beyond the configured number. Nothing
+// happens if the file does not exist yet.
+func rotateBackups(path string) error {
+	keep := getBackupGenerations()
+	if keep <= 0 {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read project file for backup: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read project file for backup: %w", err)
+	}
+
+	// Remove generations beyond the limit, which may be left from a higher setting
+	existing, err := ListBackups(path)
+	if err != nil {
+		return err
+	}
+	for _, b := range existing {
+		if b.Generation >= keep {
+			if err := os.Remove(b.Path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove old backup: %w", err)
+			}
+		}
+	}
+
+	for generation := keep - 1; generation >= 1; generation-- {
+		from := backupPath(path, generation)
+		if err := os.Rename(from, backupPath(path, generation+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate backups: %w", err)
+		}
+	}
+
+	// The backup keeps the time the version was saved, not the time it was backed up
+	first := backupPath(path, 1)
+	if err := os.WriteFile(first, data, 0644); err != nil {
+		os.Remove(first)
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	_ = os.Chtimes(first, info.ModTime(), info.ModTime())
+	return nil
+}
+
+// ListBackups returns the previous versions kept for the project file at
+// path, most recent first
+func ListBackups(path string) ([]Backup, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []Backup{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	prefix := base + ".bak."
+	backups := []Backup{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		generation, err := strconv.Atoi(strings.TrimPrefix(name, prefix))
+		if err != nil || generation < 1 {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{
+			Path:       filepath.Join(dir, name),
+			Generation: generation,
+			SavedAt:    info.ModTime().Format(time.RFC3339),
+			Size:       info.Size(),
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Generation < backups[j].Generation
+	})
+	return backups, nil
+}
+
+// RestoreBackup replaces the project file at path with the given backup
+// generation. The version being replaced becomes the newest backup, so a
+// restore can itself be rolled back.
+func RestoreBackup(path string, generation int) error {
+	data, err := os.ReadFile(backupPath(path, generation))
+	if err != nil {
+		return fmt.Errorf("no backup %d of %s: %w", generation, path, err)
+	}
+
+	if err := rotateBackups(path); err != nil {
+		return err
+	}
+
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write project file: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to finalize project file: %w", err)
+	}
+	return nil
+}
+
+// RestoreBackup rolls the project's file back to the given backup generation
+// and reloads the project from it, discarding any unsaved changes
+func (p *Project) RestoreBackup(generation int) error {
+	defer p.lock()()
+	if p.Path == "" {
+		return fmt.Errorf("cannot restore a project that has never been saved")
+	}
+
+	if err := RestoreBackup(p.Path, generation); err != nil {
+		return err
+	}
+
+	fresh, err := Load(p.Path)
+	if err != nil {
+		return err
+	}
+	p.replaceWith(fresh)
+	return nil
+}
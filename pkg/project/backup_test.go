@@ -0,0 +1,78 @@
+package project_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+)
+
+func TestSaveRotatesBackups(t *testing.T) {
+	project.SetBackupGenerations(2)
+	defer project.SetBackupGenerations(project.DefaultBackups)
+
+	path := filepath.Join(t.TempDir(), "project.json")
+	p := project.New("Backed Up")
+	for i := 1; i <= 4; i++ {
+		p.SetPreference("version", fmt.Sprint(i))
+		if err := p.SaveAs(path); err != nil {
+			t.Fatalf("Save %d failed: %v", i, err)
+		}
+	}
+
+	backups, err := project.ListBackups(path)
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	if len(backups) != 2 || backups[0].Generation != 1 || backups[1].Generation != 2 {
+		t.Fatalf("Expected generations 1 and 2, got %+v", backups)
+	}
+	for i, expected := range []string{"3", "2"} {
+		backup, err := project.Load(backups[i].Path)
+		if err != nil {
+			t.Fatalf("Failed to load backup: %v", err)
+		}
+		if got := backup.GetPreference("version"); got != expected {
+			t.Errorf("Generation %d: expected version %s, got %s", i+1, expected, got)
+		}
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "project.json")
+	p := project.New("Backed Up")
+	p.SetPreference("state", "good")
+	if err := p.SaveAs(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	p.SetPreference("state", "bad")
+	if err := p.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	p.SetPreference("state", "unsaved")
+
+	if err := p.RestoreBackup(1); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if got := p.GetPreference("state"); got != "good" {
+		t.Errorf("Expected the good version back, got %s", got)
+	}
+	if p.IsDirty() || p.ModifiedOnDisk() {
+		t.Error("A restored project should match its file")
+	}
+
+	// The version that was rolled back is kept so the restore can be undone
+	backups, _ := project.ListBackups(path)
+	if len(backups) == 0 {
+		t.Fatal("Expected backups after restoring")
+	}
+	rolledBack, err := project.Load(backups[0].Path)
+	if err != nil || rolledBack.GetPreference("state") != "bad" {
+		t.Errorf("Expected the newest backup to hold the rolled back version")
+	}
+
+	if err := p.RestoreBackup(99); err == nil {
+		t.Error("Expected an error restoring a backup that does not exist")
+	}
+}
@@ -0,0 +1,551 @@
+// package project contains the data structures and methods for managing project files
+package project
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// BundleVersion is the layout version written to every bundle manifest
+	BundleVersion = "1"
+	// MaxBundleEntrySize is the largest file a bundle may hold, which keeps a
+	// hostile archive from exhausting memory when it is read
+	MaxBundleEntrySize = 32 << 20
+
+	bundleManifest = "manifest.json"
+	bundleProject  = "project.json"
+)
+
+var (
+	// ErrBundleFormat is returned for archives that are not .zip, .tar.gz or .tgz files
+	ErrBundleFormat = errors.New("unsupported bundle format")
+	// ErrBundleInvalid is returned when a bundle is missing its manifest or project,
+	// or contains files the manifest does not list or paths outside the bundle
+	ErrBundleInvalid = errors.New("invalid project bundle")
+	// ErrBundleChecksum is returned when a file in a bundle does not match its manifest entry
+	ErrBundleChecksum = errors.New("bundle checksum mismatch")
+	// ErrBundleAssetExists is returned when importing a bundle would replace a
+	// different file already in the project's folder
+	ErrBundleAssetExists = errors.New("bundle asset already exists")
+)
+
+// BundleAsset is a file that belongs in a project's bundle alongside the
+// project file. Name is the slash-separated path the file is stored under,
+// both inside the bundle and relative to the folder of the project it is
+// imported with. The contents come from Data if set, otherwise from the file
+// at Source. Section paths equal to Source are rewritten to Name in the
+// bundled project, see Section.Paths.
+type BundleAsset struct {
+	Name   string
+	Source string
+	Data   []byte
+}
+
+// AssetCollector lists the files a project refers to that should travel with it
+type AssetCollector func(p *Project) ([]BundleAsset, error)
+
+// BundleEntry describes one file stored in a bundle
+type BundleEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// BundleManifest describes the contents of a bundle
+type BundleManifest struct {
+	Version     string        `json:"version"`
+	ProjectID   string        `json:"projectId"`
+	ProjectName string        `json:"projectName"`
+	Created     string        `json:"created"`
+	Files       []BundleEntry `json:"files"`
+	Missing     []string      `json:"missing,omitempty"` // Referenced assets that could not be found on export
+}
+
+var (
+	collectorRegistry = map[string]AssetCollector{}
+	collectorMutex    sync.RWMutex
+)
+
+// RegisterAssetCollector adds a source of bundle assets under a unique key. It
+// is normally called from the init function of the package that owns the files.
+func RegisterAssetCollector(key string, collect AssetCollector) {
+	collectorMutex.Lock()
+	defer collectorMutex.Unlock()
+	if _, exists := collectorRegistry[key]; exists {
+		panic(fmt.Sprintf("asset collector %s is already registered", key))
+	}
+	collectorRegistry[key] = collect
+}
+
+// collectAssets gathers the assets of every registered collector in key order
+func collectAssets(p *Project) ([]BundleAsset, error) {
+	collectorMutex.RLock()
+	keys := make([]string, 0, len(collectorRegistry))
+	for key := range collectorRegistry {
+		keys = append(keys, key)
+	}
+	collectors := make([]AssetCollector, 0, len(keys))
+	sort.Strings(keys)
+	for _, key := range keys {
+		collectors = append(collectors, collectorRegistry[key])
+	}
+	collectorMutex.RUnlock()
+
+	assets := []BundleAsset{}
+	for i, collect := range collectors {
+		found, err := collect(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to collect %s assets: %w", keys[i], err)
+		}
+		assets = append(assets, found...)
+	}
+	return assets, nil
+}
+
+// ExportBundle writes the project together with the files it refers to and a
+// manifest of checksums to a single archive at dest. The archive format is
+// chosen by the extension of dest. Referenced files that no longer exist are
+// left out and listed as missing in the returned manifest, and the project
+// keeps referring to them where they were.
+func ExportBundle(p *Project, dest string) (*BundleManifest, error) {
+	kind := bundleKind(dest)
+	if kind == "" {
+		return nil, fmt.Errorf("%w: %s", ErrBundleFormat, dest)
+	}
+
+	assets, err := collectAssets(p)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+	bundled := map[string]string{} // Source path to name in the bundle
+	missing := []string{}
+	for _, asset := range assets {
+		name, err := cleanBundleName(asset.Name)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := files[name]; exists || name == bundleProject || name == bundleManifest {
+			continue
+		}
+
+		data := asset.Data
+		if data == nil {
+			if data, err = os.ReadFile(asset.Source); os.IsNotExist(err) {
+				missing = append(missing, name)
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", asset.Source, err)
+			}
+		}
+		files[name] = data
+		if asset.Source != "" {
+			bundled[asset.Source] = name
+		}
+	}
+
+	projectData, manifest, err := p.bundleHeader(func(path string) string {
+		if name, ok := bundled[path]; ok {
+			return name
+		}
+		return path
+	})
+	if err != nil {
+		return nil, err
+	}
+	files[bundleProject] = projectData
+	if len(missing) > 0 {
+		manifest.Missing = missing
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		manifest.Files = append(manifest.Files, BundleEntry{
+			Name:   name,
+			Size:   int64(len(files[name])),
+			SHA256: hashContents(files[name]),
+		})
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize bundle manifest: %w", err)
+	}
+
+	if err := writeBundle(dest, kind, manifestData, files, names); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// bundleHeader serializes the project with its section paths passed through
+// move and starts its manifest, under the project's lock
+func (p *Project) bundleHeader(move func(path string) string) ([]byte, *BundleManifest, error) {
+	defer p.lock()()
+	if err := p.resident(); err != nil {
+		return nil, nil, err
+	}
+
+	moved, err := movePaths(p.Data, move)
+	if err != nil {
+		return nil, nil, err
+	}
+	bundled := &Project{
+		ID:          p.ID,
+		Version:     p.Version,
+		Name:        p.Name,
+		LastOpened:  p.LastOpened,
+		Preferences: p.Preferences,
+		Dirty:       p.Dirty,
+		Data:        moved,
+		Checksum:    p.Checksum,
+	}
+
+	data, err := json.MarshalIndent(bundled, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to serialize project: %w", err)
+	}
+
+	manifest := &BundleManifest{
+		Version:     BundleVersion,
+		ProjectID:   p.ID,
+		ProjectName: p.Name,
+		Created:     time.Now().Format(time.RFC3339),
+		Files:       []BundleEntry{},
+	}
+	return data, manifest, nil
+}
+
+// ReadBundle reads and verifies the bundle at src without extracting it
+func ReadBundle(src string) (*BundleManifest, error) {
+	manifest, _, err := readBundle(src)
+	return manifest, err
+}
+
+// ImportBundle verifies the bundle at src, writes its project to projectPath
+// and its assets to the folder of projectPath, and returns the saved project.
+// Section paths naming bundled assets are resolved to where the assets were
+// written. Nothing is written unless every checksum in the manifest matches
+// and no asset would replace a different file already there.
+func ImportBundle(src, projectPath string) (*Project, *BundleManifest, error) {
+	manifest, files, err := readBundle(src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	projectPath, err = filepath.Abs(projectPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	dir := filepath.Dir(projectPath)
+
+	project, _, err := decode(files[bundleProject])
+	if err != nil {
+		if errors.Is(err, ErrNewerVersion) {
+			return nil, nil, fmt.Errorf("cannot import %s: %w", src, err)
+		}
+		return nil, nil, fmt.Errorf("failed to parse bundled project: %w", err)
+	}
+
+	// Every target is checked before anything is written
+	resolved := make(map[string]string, len(manifest.Files))
+	pending := []string{}
+	for _, entry := range manifest.Files {
+		if entry.Name == bundleProject {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(entry.Name))
+		resolved[entry.Name] = target
+		existing, err := os.ReadFile(target)
+		switch {
+		case target == projectPath:
+			return nil, nil, fmt.Errorf("%w: %s", ErrBundleAssetExists, target)
+		case err == nil && bytes.Equal(existing, files[entry.Name]):
+			continue // Already in place, say from an earlier import
+		case !os.IsNotExist(err):
+			return nil, nil, fmt.Errorf("%w: %s", ErrBundleAssetExists, target)
+		}
+		pending = append(pending, entry.Name)
+	}
+
+	if project.Data, err = movePaths(project.Data, func(path string) string {
+		if target, ok := resolved[path]; ok {
+			return target
+		}
+		return path
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	for _, name := range pending {
+		target := resolved[name]
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(target, files[name], 0644); err != nil {
+			return nil, nil, fmt.Errorf("failed to write %s: %w", target, err)
+		}
+	}
+
+	if err := project.SaveAs(projectPath); err != nil {
+		return nil, nil, err
+	}
+	return project, manifest, nil
+}
+
+// readBundle loads every file in the bundle at src and checks them against its manifest
+func readBundle(src string) (*BundleManifest, map[string][]byte, error) {
+	var files map[string][]byte
+	var err error
+	switch bundleKind(src) {
+	case "zip":
+		files, err = readZip(src)
+	case "tar.gz":
+		files, err = readTarGz(src)
+	default:
+		return nil, nil, fmt.Errorf("%w: %s", ErrBundleFormat, src)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manifestData, exists := files[bundleManifest]
+	if !exists {
+		return nil, nil, fmt.Errorf("%w: no %s", ErrBundleInvalid, bundleManifest)
+	}
+	var manifest BundleManifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("%w: failed to parse manifest: %v", ErrBundleInvalid, err)
+	}
+	if manifest.Version != BundleVersion {
+		return nil, nil, fmt.Errorf("%w: unknown bundle version %q", ErrBundleInvalid, manifest.Version)
+	}
+
+	listed := map[string]bool{bundleManifest: true}
+	for _, entry := range manifest.Files {
+		if cleaned, err := cleanBundleName(entry.Name); err != nil {
+			return nil, nil, err
+		} else if cleaned != entry.Name {
+			return nil, nil, fmt.Errorf("%w: unclean path %q", ErrBundleInvalid, entry.Name)
+		}
+		data, exists := files[entry.Name]
+		if !exists {
+			return nil, nil, fmt.Errorf("%w: %s is listed but missing", ErrBundleInvalid, entry.Name)
+		}
+		if int64(len(data)) != entry.Size || hashContents(data) != entry.SHA256 {
+			return nil, nil, fmt.Errorf("%w: %s", ErrBundleChecksum, entry.Name)
+		}
+		listed[entry.Name] = true
+	}
+	for name := range files {
+		if !listed[name] {
+			return nil, nil, fmt.Errorf("%w: %s is not in the manifest", ErrBundleInvalid, name)
+		}
+	}
+	if !listed[bundleProject] {
+		return nil, nil, fmt.Errorf("%w: no %s", ErrBundleInvalid, bundleProject)
+	}
+
+	return &manifest, files, nil
+}
+
+// cleanBundleName rejects names that would escape the folder a bundle is imported into
+func cleanBundleName(name string) (string, error) {
+	cleaned := path.Clean(filepath.ToSlash(name))
+	if name == "" || path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") ||
+		filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("%w: unsafe path %q", ErrBundleInvalid, name)
+	}
+	return cleaned, nil
+}
+
+func bundleKind(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	}
+	return ""
+}
+
+// writeBundle writes the archive through a temporary file so a failed export
+// never leaves a partial bundle behind
+func writeBundle(dest, kind string, manifest []byte, files map[string][]byte, names []string) error {
+	var buf bytes.Buffer
+	var err error
+	if kind == "zip" {
+		err = writeZip(&buf, manifest, files, names)
+	} else {
+		err = writeTarGz(&buf, manifest, files, names)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to build bundle: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tempPath := dest + ".tmp"
+	if err := os.WriteFile(tempPath, buf.Bytes(), 0644); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := os.Rename(tempPath, dest); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to finalize bundle: %w", err)
+	}
+	return nil
+}
+
+func writeZip(out io.Writer, manifest []byte, files map[string][]byte, names []string) error {
+	zw := zip.NewWriter(out)
+	add := func(name string, data []byte) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		return err
+	}
+
+	if err := add(bundleManifest, manifest); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := add(name, files[name]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeTarGz(out io.Writer, manifest []byte, files map[string][]byte, names []string) error {
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	add := func(name string, data []byte) error {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	if err := add(bundleManifest, manifest); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := add(name, files[name]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func readZip(src string) (map[string][]byte, error) {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBundleInvalid, err)
+	}
+	defer zr.Close()
+
+	files := make(map[string][]byte, len(zr.File))
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if f.UncompressedSize64 > MaxBundleEntrySize {
+			return nil, entryTooLarge(f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBundleInvalid, err)
+		}
+		data, err := readEntry(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		files[f.Name] = data
+	}
+	return files, nil
+}
+
+func readTarGz(src string) (map[string][]byte, error) {
+	file, err := os.Open(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBundleInvalid, err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBundleInvalid, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Size > MaxBundleEntrySize {
+			return nil, entryTooLarge(header.Name)
+		}
+		data, err := readEntry(header.Name, tr)
+		if err != nil {
+			return nil, err
+		}
+		files[header.Name] = data
+	}
+	return files, nil
+}
+
+// readEntry reads one file of a bundle, refusing to read more than
+// MaxBundleEntrySize bytes whatever the archive claims its size is
+func readEntry(name string, r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxBundleEntrySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBundleInvalid, err)
+	}
+	if len(data) > MaxBundleEntrySize {
+		return nil, entryTooLarge(name)
+	}
+	return data, nil
+}
+
+func entryTooLarge(name string) error {
+	return fmt.Errorf("%w: %s is larger than %d bytes", ErrBundleInvalid, name, MaxBundleEntrySize)
+}
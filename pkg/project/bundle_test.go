@@ -0,0 +1,242 @@
+package project_test
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+)
+
+// bundleAssetPref names a preference holding a file the test collector bundles
+const bundleAssetPref = "bundle_test_asset"
+
+func init() {
+	project.RegisterAssetCollector("bundle_test", func(p *project.Project) ([]project.BundleAsset, error) {
+		source := p.GetPreference(bundleAssetPref)
+		if source == "" {
+			return nil, nil
+		}
+		return []project.BundleAsset{
+			{Name: "output/test/generated/image.png", Source: source},
+			{Name: "output/test/series.json", Data: []byte(`{"suffix":"test"}`)},
+		}, nil
+	})
+}
+
+func bundledProject(t *testing.T) *project.Project {
+	t.Helper()
+
+	image := filepath.Join(t.TempDir(), "image.png")
+	if err := os.WriteFile(image, []byte("not really a png"), 0644); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+
+	p := project.New("Bundled")
+	p.SetPreference(bundleAssetPref, image)
+	if err := p.SetSection(project.NotesSection, project.Notes{Text: "travel with me"}); err != nil {
+		t.Fatalf("Failed to set notes: %v", err)
+	}
+	return p
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	for _, ext := range []string{".zip", ".tar.gz"} {
+		t.Run(ext, func(t *testing.T) {
+			original := bundledProject(t)
+			bundle := filepath.Join(t.TempDir(), "export"+ext)
+
+			manifest, err := project.ExportBundle(original, bundle)
+			if err != nil {
+				t.Fatalf("Export failed: %v", err)
+			}
+			if len(manifest.Files) != 3 || len(manifest.Missing) != 0 {
+				t.Fatalf("Expected project plus two assets, got %+v", manifest)
+			}
+
+			if read, err := project.ReadBundle(bundle); err != nil || read.ProjectID != original.GetID() {
+				t.Fatalf("Failed to read bundle back: %+v (%v)", read, err)
+			}
+
+			dest := t.TempDir()
+			projectPath := filepath.Join(dest, "imported.json")
+			imported, _, err := project.ImportBundle(bundle, projectPath)
+			if err != nil {
+				t.Fatalf("Import failed: %v", err)
+			}
+
+			if imported.GetID() != original.GetID() || imported.GetName() != "Bundled" {
+				t.Errorf("Expected the same project back, got %s/%s", imported.GetID(), imported.GetName())
+			}
+			if imported.GetPath() != projectPath || imported.IsDirty() {
+				t.Error("Imported project should be saved at the requested path")
+			}
+			notes, err := project.GetSection[project.Notes](imported, project.NotesSection)
+			if err != nil || notes.Text != "travel with me" {
+				t.Errorf("Expected notes to survive the round trip, got %q (%v)", notes.Text, err)
+			}
+
+			image, err := os.ReadFile(filepath.Join(dest, "output", "test", "generated", "image.png"))
+			if err != nil || string(image) != "not really a png" {
+				t.Errorf("Expected the image next to the project, got %q (%v)", image, err)
+			}
+			if _, err := os.Stat(filepath.Join(dest, "output", "test", "series.json")); err != nil {
+				t.Errorf("Expected the series file next to the project: %v", err)
+			}
+
+			// The same files may be imported again, but not over different ones
+			if _, _, err := project.ImportBundle(bundle, filepath.Join(dest, "again.json")); err != nil {
+				t.Errorf("Expected a second import over identical files to succeed: %v", err)
+			}
+			_ = os.WriteFile(filepath.Join(dest, "output", "test", "series.json"), []byte(`{"suffix":"mine"}`), 0644)
+			if _, _, err := project.ImportBundle(bundle, filepath.Join(dest, "third.json")); !errors.Is(err, project.ErrBundleAssetExists) {
+				t.Errorf("Expected ErrBundleAssetExists, got %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dest, "third.json")); !os.IsNotExist(err) {
+				t.Error("Nothing should be written when an asset is refused")
+			}
+		})
+	}
+}
+
+func TestBundleListsMissingAssets(t *testing.T) {
+	p := bundledProject(t)
+	p.SetPreference(bundleAssetPref, filepath.Join(t.TempDir(), "gone.png"))
+
+	manifest, err := project.ExportBundle(p, filepath.Join(t.TempDir(), "export.zip"))
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(manifest.Missing) != 1 || manifest.Missing[0] != "output/test/generated/image.png" {
+		t.Errorf("Expected the missing image to be listed, got %v", manifest.Missing)
+	}
+}
+
+func TestBundleRejectsUnsupportedFormat(t *testing.T) {
+	_, err := project.ExportBundle(project.New("Bundled"), filepath.Join(t.TempDir(), "export.rar"))
+	if !errors.Is(err, project.ErrBundleFormat) {
+		t.Errorf("Expected ErrBundleFormat, got %v", err)
+	}
+}
+
+// rewriteZip copies a bundle, passing each file's contents through edit
+func rewriteZip(t *testing.T, src, dest string, edit func(name string, data []byte) (string, []byte)) {
+	t.Helper()
+
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		t.Fatalf("Failed to open bundle: %v", err)
+	}
+	defer zr.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		t.Fatalf("Failed to create bundle: %v", err)
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+
+		name, data := edit(f.Name, data)
+		w, _ := zw.Create(name)
+		_, _ = w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to write bundle: %v", err)
+	}
+}
+
+func TestBundleRejectsTampering(t *testing.T) {
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "export.zip")
+	if _, err := project.ExportBundle(bundledProject(t), bundle); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	t.Run("ChangedFile", func(t *testing.T) {
+		tampered := filepath.Join(dir, "changed.zip")
+		rewriteZip(t, bundle, tampered, func(name string, data []byte) (string, []byte) {
+			if name == "output/test/series.json" {
+				return name, []byte(`{"suffix":"evil"}`)
+			}
+			return name, data
+		})
+
+		projectPath := filepath.Join(dir, "changed.json")
+		if _, _, err := project.ImportBundle(tampered, projectPath); !errors.Is(err, project.ErrBundleChecksum) {
+			t.Errorf("Expected ErrBundleChecksum, got %v", err)
+		}
+		if _, err := os.Stat(projectPath); !os.IsNotExist(err) {
+			t.Error("Nothing should be written from a tampered bundle")
+		}
+	})
+
+	t.Run("EscapingPath", func(t *testing.T) {
+		tampered := filepath.Join(dir, "escaping.zip")
+		rewriteZip(t, bundle, tampered, func(name string, data []byte) (string, []byte) {
+			switch name {
+			case "output/test/series.json":
+				return "../series.json", data
+			case "manifest.json":
+				var manifest project.BundleManifest
+				_ = json.Unmarshal(data, &manifest)
+				for i := range manifest.Files {
+					if manifest.Files[i].Name == "output/test/series.json" {
+						manifest.Files[i].Name = "../series.json"
+						sum := sha256.Sum256([]byte(`{"suffix":"test"}`))
+						manifest.Files[i].SHA256 = hex.EncodeToString(sum[:])
+					}
+				}
+				data, _ = json.Marshal(manifest)
+			}
+			return name, data
+		})
+
+		work := filepath.Join(dir, "work")
+		_, _, err := project.ImportBundle(tampered, filepath.Join(work, "escaping.json"))
+		if !errors.Is(err, project.ErrBundleInvalid) {
+			t.Errorf("Expected ErrBundleInvalid, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "series.json")); !os.IsNotExist(err) {
+			t.Error("A bundle must not write outside the project's folder")
+		}
+	})
+
+	t.Run("OversizedFile", func(t *testing.T) {
+		tampered := filepath.Join(dir, "oversized.zip")
+		big := make([]byte, project.MaxBundleEntrySize+1)
+		rewriteZip(t, bundle, tampered, func(name string, data []byte) (string, []byte) {
+			switch name {
+			case "output/test/series.json":
+				return name, big
+			case "manifest.json":
+				var manifest project.BundleManifest
+				_ = json.Unmarshal(data, &manifest)
+				for i := range manifest.Files {
+					if manifest.Files[i].Name == "output/test/series.json" {
+						manifest.Files[i].Size = int64(len(big))
+						sum := sha256.Sum256(big)
+						manifest.Files[i].SHA256 = hex.EncodeToString(sum[:])
+					}
+				}
+				data, _ = json.Marshal(manifest)
+			}
+			return name, data
+		})
+
+		projectPath := filepath.Join(dir, "oversized.json")
+		if _, _, err := project.ImportBundle(tampered, projectPath); !errors.Is(err, project.ErrBundleInvalid) {
+			t.Errorf("Expected ErrBundleInvalid, got %v", err)
+		}
+	})
+}
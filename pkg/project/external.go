@@ -0,0 +1,275 @@
+// package project contains the data structures and methods for managing project files
+package project
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// ErrModifiedOnDisk is returned by Save when the project file was changed by
+// something else after it was loaded or last saved
+var ErrModifiedOnDisk = errors.New("project file was modified on disk")
+
+// diskState records what the project file looked like the last time this
+// process read or wrote it
+type diskState struct {
+	hash    string
+	modTime time.Time
+	size    int64
+	base    []byte // File contents, the common ancestor for a three-way merge
+}
+
+func hashContents(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// recordDisk remembers data as the current contents of the file at path
+func (p *Project) recordDisk(path string, data []byte) {
+	state := diskState{hash: hashContents(data), base: data}
+	if info, err := os.Stat(path); err == nil {
+		state.modTime = info.ModTime()
+		state.size = info.Size()
+	}
+	p.disk = state
+}
+
+// ModifiedOnDisk returns true if the project file no longer matches what this
+// process last read or wrote. Projects that have never been saved, or whose
+// file has been deleted, are never considered modified.
+func (p *Project) ModifiedOnDisk() bool {
+	defer p.lock()()
+	_, changed := p.checkDisk()
+	return changed
+}
+
+// checkDisk returns the current contents of the project file and whether they
+// differ from the recorded state. The caller must hold the project's lock.
+func (p *Project) checkDisk() ([]byte, bool) {
+	if p.Path == "" || p.disk.hash == "" {
+		return nil, false
+	}
+
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return nil, false
+	}
+	if info.ModTime().Equal(p.disk.modTime) && info.Size() == p.disk.size {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, false
+	}
+	return data, hashContents(data) != p.disk.hash
+}
+
+// Reload discards all in-memory changes and the undo history and reloads the
+// project from its file
+func (p *Project) Reload() error {
+	path := p.GetPath()
+	if path == "" {
+		return fmt.Errorf("cannot reload project with empty path")
+	}
+
+	fresh, err := Load(path)
+	if err != nil {
+		return err
+	}
+
+	defer p.lock()()
+	p.replaceWith(fresh)
+	return nil
+}
+
+// replaceWith takes over the contents of a freshly loaded copy of the project,
+// leaving it clean with no history. The caller must hold the project's lock.
+func (p *Project) replaceWith(fresh *Project) {
+	p.Version = fresh.Version
+	p.Name = fresh.Name
+	p.LastOpened = fresh.LastOpened
+	p.Preferences = fresh.Preferences
+	p.Data = fresh.Data
+	p.disk = fresh.disk
+	p.oldVersion = fresh.oldVersion
+	if p.spill != "" {
+		os.Remove(p.spill)
+		p.spill = ""
+	}
+	p.hist.commands = nil
+	p.hist.position = 0
+	p.hist.savedAt = fresh.hist.savedAt
+	p.Dirty = fresh.hist.isDirty()
+}
+
+// Overwrite saves the project over its file even if the file was changed on disk
+func (p *Project) Overwrite() error {
+	path := p.GetPath()
+	if path == "" {
+		return fmt.Errorf("cannot save project with empty path")
+	}
+	return p.SaveAs(path)
+}
+
+// Merge folds changes made to the project file on disk into the in-memory
+// project using a three-way merge of Preferences and Data, with the contents
+// last read or written as the common ancestor. Keys changed on both sides to
+// different values keep the in-memory value and are returned as conflicts.
+// The merge is an undoable change and leaves the project dirty but savable.
+func (p *Project) Merge() ([]string, error) {
+	defer p.lock()()
+
+	theirsData, changed := p.checkDisk()
+	if !changed {
+		return []string{}, nil
+	}
+	if err := p.resident(); err != nil {
+		return nil, err
+	}
+
+	theirs, _, err := decode(theirsData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse project file on disk: %w", err)
+	}
+
+	base := &Project{Preferences: map[string]string{}, Data: map[string]json.RawMessage{}}
+	if len(p.disk.base) > 0 {
+		if base, _, err = decode(p.disk.base); err != nil {
+			return nil, fmt.Errorf("failed to parse last known project file: %w", err)
+		}
+	}
+
+	conflicts := []string{}
+	prefs, prefConflicts := mergeStrings(base.Preferences, p.Preferences, theirs.Preferences)
+	for _, key := range prefConflicts {
+		conflicts = append(conflicts, "preferences."+key)
+	}
+	data, dataConflicts := mergeRaw(base.Data, p.Data, theirs.Data)
+	for _, key := range dataConflicts {
+		conflicts = append(conflicts, "data."+key)
+	}
+
+	p.run(&mergeCommand{
+		fromPrefs: p.Preferences,
+		fromData:  p.Data,
+		toPrefs:   prefs,
+		toData:    data,
+	})
+	p.recordDisk(p.Path, theirsData)
+	p.hist.markDirty()
+	p.Dirty = true
+
+	return conflicts, nil
+}
+
+// mergeCommand swaps the project's Preferences and Data for their merged versions
+type mergeCommand struct {
+	fromPrefs, toPrefs map[string]string
+	fromData, toData   map[string]json.RawMessage
+}
+
+func (c *mergeCommand) Do(p *Project) {
+	p.Preferences = c.toPrefs
+	p.Data = c.toData
+}
+
+func (c *mergeCommand) Undo(p *Project) {
+	p.Preferences = c.fromPrefs
+	p.Data = c.fromData
+}
+
+func (c *mergeCommand) Label() string { return "Merge changes from disk" }
+
+func mergeStrings(base, mine, theirs map[string]string) (map[string]string, []string) {
+	merged := make(map[string]string)
+	conflicts := []string{}
+	for _, key := range unionKeys(base, mine, theirs) {
+		b, inBase := base[key]
+		m, inMine := mine[key]
+		t, inTheirs := theirs[key]
+
+		switch {
+		case inMine == inTheirs && m == t:
+			if inMine {
+				merged[key] = m
+			}
+		case inMine == inBase && m == b:
+			if inTheirs {
+				merged[key] = t
+			}
+		case inTheirs == inBase && t == b:
+			if inMine {
+				merged[key] = m
+			}
+		default:
+			conflicts = append(conflicts, key)
+			if inMine {
+				merged[key] = m
+			}
+		}
+	}
+	return merged, conflicts
+}
+
+func mergeRaw(base, mine, theirs map[string]json.RawMessage) (map[string]json.RawMessage, []string) {
+	merged := make(map[string]json.RawMessage)
+	conflicts := []string{}
+	for _, key := range unionKeys(base, mine, theirs) {
+		b, inBase := base[key]
+		m, inMine := mine[key]
+		t, inTheirs := theirs[key]
+
+		switch {
+		case inMine == inTheirs && sameJSON(m, t):
+			if inMine {
+				merged[key] = m
+			}
+		case inMine == inBase && sameJSON(m, b):
+			if inTheirs {
+				merged[key] = t
+			}
+		case inTheirs == inBase && sameJSON(t, b):
+			if inMine {
+				merged[key] = m
+			}
+		default:
+			conflicts = append(conflicts, key)
+			if inMine {
+				merged[key] = m
+			}
+		}
+	}
+	return merged, conflicts
+}
+
+// sameJSON compares two JSON values ignoring formatting
+func sameJSON(a, b json.RawMessage) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+func unionKeys[V any](maps ...map[string]V) []string {
+	seen := make(map[string]bool)
+	keys := []string{}
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
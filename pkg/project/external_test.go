@@ -0,0 +1,238 @@
+package project_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+)
+
+// editOnDisk changes the saved project file the way another program would
+func editOnDisk(t *testing.T, path string, edit func(raw map[string]interface{})) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read project file: %v", err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("Failed to parse project file: %v", err)
+	}
+
+	edit(raw)
+	// A program editing the file cannot keep the checksum valid, so it drops
+	// it, which Load accepts
+	delete(raw, "checksum")
+
+	data, err = json.MarshalIndent(raw, "", "    ")
+	if err != nil {
+		t.Fatalf("Failed to serialize project file: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write project file: %v", err)
+	}
+}
+
+func savedProject(t *testing.T) (*project.Project, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "project.json")
+	p := project.New("Shared")
+	p.SetPreference("theme", "dark")
+	p.SetPreference("size", "small")
+	if err := p.SetSection(project.NotesSection, project.Notes{Text: "original"}); err != nil {
+		t.Fatalf("Failed to set notes: %v", err)
+	}
+	if err := p.SaveAs(path); err != nil {
+		t.Fatalf("Failed to save project: %v", err)
+	}
+	return p, path
+}
+
+func TestSaveRefusesExternallyModifiedFile(t *testing.T) {
+	p, path := savedProject(t)
+	if p.ModifiedOnDisk() {
+		t.Fatal("A freshly saved project should match its file")
+	}
+
+	editOnDisk(t, path, func(raw map[string]interface{}) {
+		raw["name"] = "Renamed elsewhere"
+	})
+	if !p.ModifiedOnDisk() {
+		t.Fatal("Expected the external edit to be detected")
+	}
+
+	p.SetPreference("theme", "light")
+	if err := p.Save(); !errors.Is(err, project.ErrModifiedOnDisk) {
+		t.Fatalf("Expected ErrModifiedOnDisk, got %v", err)
+	}
+
+	if err := p.Overwrite(); err != nil {
+		t.Fatalf("Overwrite failed: %v", err)
+	}
+	if p.ModifiedOnDisk() || p.IsDirty() {
+		t.Error("Project should match its file after overwriting")
+	}
+	loaded, err := project.Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load project: %v", err)
+	}
+	if loaded.GetName() != "Shared" || loaded.GetPreference("theme") != "light" {
+		t.Errorf("Overwrite should keep the in-memory project, got %s/%s", loaded.GetName(), loaded.GetPreference("theme"))
+	}
+}
+
+func TestReloadDiscardsLocalChanges(t *testing.T) {
+	p, path := savedProject(t)
+
+	p.SetPreference("theme", "light")
+	editOnDisk(t, path, func(raw map[string]interface{}) {
+		raw["name"] = "Renamed elsewhere"
+	})
+
+	if err := p.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if p.GetName() != "Renamed elsewhere" || p.GetPreference("theme") != "dark" {
+		t.Errorf("Expected the file's contents after reload, got %s/%s", p.GetName(), p.GetPreference("theme"))
+	}
+	if p.IsDirty() || p.CanUndo() || p.ModifiedOnDisk() {
+		t.Error("A reloaded project should be clean with no history")
+	}
+	if err := p.Save(); err != nil {
+		t.Errorf("Save after reload should succeed, got %v", err)
+	}
+}
+
+func TestMergeCombinesBothSides(t *testing.T) {
+	p, path := savedProject(t)
+
+	// Local: change theme, add a key, and change notes
+	p.SetPreference("theme", "light")
+	p.SetPreference("local", "yes")
+	if err := p.SetSection(project.NotesSection, project.Notes{Text: "mine"}); err != nil {
+		t.Fatalf("Failed to set notes: %v", err)
+	}
+
+	// Disk: change size, add a key, and change notes differently
+	editOnDisk(t, path, func(raw map[string]interface{}) {
+		prefs := raw["preferences"].(map[string]interface{})
+		prefs["size"] = "large"
+		prefs["remote"] = "yes"
+		data := raw["data"].(map[string]interface{})
+		data[project.NotesSection] = map[string]interface{}{"text": "theirs"}
+	})
+
+	conflicts, err := p.Merge()
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if !reflect.DeepEqual(conflicts, []string{"data." + project.NotesSection}) {
+		t.Errorf("Expected only the notes to conflict, got %v", conflicts)
+	}
+
+	expected := map[string]string{"theme": "light", "size": "large", "local": "yes", "remote": "yes"}
+	for key, value := range expected {
+		if got := p.GetPreference(key); got != value {
+			t.Errorf("Preference %s: expected %q, got %q", key, value, got)
+		}
+	}
+	notes, _ := project.GetSection[project.Notes](p, project.NotesSection)
+	if notes.Text != "mine" {
+		t.Errorf("A conflicting section should keep the local value, got %q", notes.Text)
+	}
+
+	if !p.IsDirty() {
+		t.Error("A merged project should have unsaved changes")
+	}
+	if err := p.Save(); err != nil {
+		t.Fatalf("Save after merge should succeed, got %v", err)
+	}
+
+	if !p.Undo() || p.GetPreference("size") != "small" {
+		t.Error("Merge should be undoable")
+	}
+}
+
+func TestManagerSavesMergedInactiveProject(t *testing.T) {
+	_, path := savedProject(t)
+	m := project.NewManager()
+	merged, err := m.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open project: %v", err)
+	}
+	active := m.New("Other")
+
+	merged.SetPreference("theme", "light")
+	editOnDisk(t, path, func(raw map[string]interface{}) {
+		raw["preferences"].(map[string]interface{})["size"] = "large"
+	})
+	if _, err := m.Merge(merged.GetID()); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+
+	if err := m.Save(merged.GetID()); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if merged.IsDirty() || merged.ModifiedOnDisk() {
+		t.Error("The merged project should be saved over its file")
+	}
+	if !active.IsDirty() || active.GetPath() != "" {
+		t.Error("The active project should be left alone")
+	}
+}
+
+func TestWatcherReportsExternalChanges(t *testing.T) {
+	changed := make(chan string, 10)
+	msgs.SetEmitter(func(messageType msgs.EventType, msgText string) {
+		if messageType == msgs.EventFileChanged {
+			select {
+			case changed <- msgText:
+			default:
+			}
+		}
+	})
+
+	_, path := savedProject(t)
+
+	m := project.NewManager()
+	opened, err := m.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open project: %v", err)
+	}
+	if err := m.StartWatching(); err != nil {
+		t.Fatalf("Failed to start watching: %v", err)
+	}
+	defer m.StopWatching()
+
+	// Saving from this process must not be reported
+	opened.SetPreference("theme", "light")
+	if err := m.SaveActive(); err != nil {
+		t.Fatalf("Failed to save project: %v", err)
+	}
+	select {
+	case id := <-changed:
+		t.Fatalf("Saving the project should not be reported as an external change to %s", id)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	editOnDisk(t, path, func(raw map[string]interface{}) {
+		raw["name"] = "Renamed elsewhere"
+	})
+
+	select {
+	case id := <-changed:
+		if id != opened.GetID() {
+			t.Errorf("Expected change event for %s, got %s", opened.GetID(), id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the file changed event")
+	}
+}
@@ -0,0 +1,211 @@
+// package project contains the data structures and methods for managing project files
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// maxHistory is the number of commands kept per project before the oldest are dropped
+const maxHistory = 100
+
+// Command is a reversible change to a project
+type Command interface {
+	Do(p *Project)
+	Undo(p *Project)
+	Label() string
+}
+
+// History is a per-project undo/redo stack. A project is dirty whenever the
+// current position in the stack differs from the position at the last save.
+// It is guarded by the project's lock.
+type History struct {
+	commands []Command
+	position int // number of commands currently applied
+	savedAt  int // position at the last save, or -1 if that state is unreachable
+}
+
+// execute applies cmd and records it, discarding anything that could have been redone
+func (h *History) execute(p *Project, cmd Command) {
+	cmd.Do(p)
+
+	if h.savedAt > h.position {
+		h.savedAt = -1
+	}
+	h.commands = append(h.commands[:h.position], cmd)
+	h.position++
+
+	if overflow := len(h.commands) - maxHistory; overflow > 0 {
+		h.commands = h.commands[overflow:]
+		h.position -= overflow
+		if h.savedAt >= 0 {
+			h.savedAt -= overflow
+			if h.savedAt < 0 {
+				h.savedAt = -1
+			}
+		}
+	}
+}
+
+func (h *History) isDirty() bool {
+	return h.position != h.savedAt
+}
+
+func (h *History) markSaved() {
+	h.savedAt = h.position
+}
+
+func (h *History) markDirty() {
+	h.savedAt = -1
+}
+
+// history returns the project's history, creating an empty one on first use.
+// The caller must hold the project's lock.
+func (p *Project) history() *History {
+	if p.hist == nil {
+		p.hist = &History{}
+	}
+	return p.hist
+}
+
+// lock acquires the project's lock and returns the function that releases it.
+// The lock lives on the project itself, so the history can be created under it.
+func (p *Project) lock() func() {
+	p.mutex.Lock()
+	p.history()
+	return p.mutex.Unlock
+}
+
+// run applies cmd through the undo history. The caller must hold the project's lock.
+func (p *Project) run(cmd Command) {
+	h := p.history()
+	h.execute(p, cmd)
+	p.Dirty = h.isDirty()
+}
+
+// CanUndo returns true if there is a change that can be undone
+func (p *Project) CanUndo() bool {
+	defer p.lock()()
+	return p.hist.position > 0
+}
+
+// CanRedo returns true if there is an undone change that can be redone
+func (p *Project) CanRedo() bool {
+	defer p.lock()()
+	return p.hist.position < len(p.hist.commands)
+}
+
+// UndoLabel describes the change that Undo would revert, or returns an empty string
+func (p *Project) UndoLabel() string {
+	defer p.lock()()
+	if p.hist.position == 0 {
+		return ""
+	}
+	return p.hist.commands[p.hist.position-1].Label()
+}
+
+// RedoLabel describes the change that Redo would reapply, or returns an empty string
+func (p *Project) RedoLabel() string {
+	defer p.lock()()
+	if p.hist.position == len(p.hist.commands) {
+		return ""
+	}
+	return p.hist.commands[p.hist.position].Label()
+}
+
+// Undo reverts the most recent change. It returns false if there was nothing to undo.
+func (p *Project) Undo() bool {
+	defer p.lock()()
+	h := p.hist
+	if h.position == 0 || p.resident() != nil {
+		return false
+	}
+	h.position--
+	h.commands[h.position].Undo(p)
+	p.Dirty = h.isDirty()
+	return true
+}
+
+// Redo reapplies the most recently undone change. It returns false if there was nothing to redo.
+func (p *Project) Redo() bool {
+	defer p.lock()()
+	h := p.hist
+	if h.position == len(h.commands) || p.resident() != nil {
+		return false
+	}
+	h.commands[h.position].Do(p)
+	h.position++
+	p.Dirty = h.isDirty()
+	return true
+}
+
+// setNameCommand renames a project
+type setNameCommand struct {
+	from, to string
+}
+
+func (c *setNameCommand) Do(p *Project)   { p.Name = c.to }
+func (c *setNameCommand) Undo(p *Project) { p.Name = c.from }
+func (c *setNameCommand) Label() string   { return "Rename project" }
+
+// setPreferenceCommand sets a single project preference
+type setPreferenceCommand struct {
+	key         string
+	from, to    string
+	fromExisted bool
+}
+
+func (c *setPreferenceCommand) Do(p *Project) {
+	p.Preferences[c.key] = c.to
+}
+
+func (c *setPreferenceCommand) Undo(p *Project) {
+	if c.fromExisted {
+		p.Preferences[c.key] = c.from
+	} else {
+		delete(p.Preferences, c.key)
+	}
+}
+
+func (c *setPreferenceCommand) Label() string { return "Change " + c.key }
+
+// sectionState is the stored form of a section at one point in time
+type sectionState struct {
+	raw    json.RawMessage
+	exists bool
+}
+
+// setSectionCommand replaces or removes a data section
+type setSectionCommand struct {
+	key      string
+	from, to sectionState
+}
+
+func (c *setSectionCommand) Do(p *Project)   { p.applySection(c.key, c.to) }
+func (c *setSectionCommand) Undo(p *Project) { p.applySection(c.key, c.from) }
+func (c *setSectionCommand) Label() string {
+	if !c.to.exists {
+		return "Remove " + c.key
+	}
+	return "Change " + c.key
+}
+
+func (p *Project) currentSection(key string) sectionState {
+	raw, exists := p.Data[key]
+	return sectionState{raw: raw, exists: exists}
+}
+
+func (p *Project) applySection(key string, state sectionState) {
+	if p.Data == nil {
+		p.Data = make(map[string]json.RawMessage)
+	}
+	if !state.exists {
+		delete(p.Data, key)
+		return
+	}
+	p.Data[key] = state.raw
+}
+
+func sameSection(a, b sectionState) bool {
+	return a.exists == b.exists && bytes.Equal(a.raw, b.raw)
+}
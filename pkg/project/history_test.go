@@ -0,0 +1,83 @@
+package project_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+)
+
+// TestUndoRedo verifies that every mutator can be undone and redone
+func TestUndoRedo(t *testing.T) {
+	p := project.New("original")
+
+	p.SetName("renamed")
+	p.SetPreference("theme", "light")
+	if err := p.SetSection(project.NotesSection, project.Notes{Text: "hello"}); err != nil {
+		t.Fatalf("Failed to set notes: %v", err)
+	}
+
+	if got := p.UndoLabel(); got != "Change notes" {
+		t.Errorf("Expected undo label 'Change notes', got %q", got)
+	}
+
+	for p.CanUndo() {
+		p.Undo()
+	}
+
+	if p.GetName() != "original" {
+		t.Errorf("Expected name 'original' after undo, got %q", p.GetName())
+	}
+	if _, exists := p.Preferences["theme"]; exists {
+		t.Error("Expected preference to be removed after undo")
+	}
+	if p.HasSection(project.NotesSection) {
+		t.Error("Expected notes to be removed after undo")
+	}
+
+	for p.CanRedo() {
+		p.Redo()
+	}
+
+	if p.GetName() != "renamed" || p.GetPreference("theme") != "light" {
+		t.Errorf("Expected redo to restore changes, got name %q theme %q", p.GetName(), p.GetPreference("theme"))
+	}
+	notes, err := project.GetSection[project.Notes](p, project.NotesSection)
+	if err != nil || notes.Text != "hello" {
+		t.Errorf("Expected notes 'hello' after redo, got %q (%v)", notes.Text, err)
+	}
+}
+
+// TestDirtyFollowsHistory verifies that a project is clean exactly when its
+// history is back at the position of the last save
+func TestDirtyFollowsHistory(t *testing.T) {
+	p := project.New("history-project")
+	if err := p.SaveAs(filepath.Join(t.TempDir(), "history-project.json")); err != nil {
+		t.Fatalf("Failed to save project: %v", err)
+	}
+
+	p.SetName("changed")
+	if !p.IsDirty() || !p.Dirty {
+		t.Fatal("Project should be dirty after a change")
+	}
+
+	p.Undo()
+	if p.IsDirty() || p.Dirty {
+		t.Fatal("Project should be clean after undoing back to the saved state")
+	}
+
+	p.Redo()
+	if !p.IsDirty() {
+		t.Fatal("Project should be dirty after redoing past the saved state")
+	}
+
+	if err := p.Save(); err != nil {
+		t.Fatalf("Failed to save project: %v", err)
+	}
+	p.Undo()
+	p.SetName("branched")
+	p.Undo()
+	if !p.IsDirty() {
+		t.Fatal("Project should stay dirty once the saved state has been discarded from history")
+	}
+}
@@ -0,0 +1,125 @@
+// package project contains the data structures and methods for managing project files
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrChecksumMismatch is returned when a project file's contents do not match its checksum
+var ErrChecksumMismatch = errors.New("project file checksum mismatch")
+
+// CorruptionError is returned by Load when a project file is damaged. If a
+// copy could be recovered, Load returns it alongside this error, and
+// RecoveredFrom names the file it came from.
+type CorruptionError struct {
+	Path          string `json:"path"`
+	Cause         error  `json:"-"`
+	Problem       string `json:"problem"`       // Cause, as text for the frontend
+	RecoveredFrom string `json:"recoveredFrom"` // Leftover temporary file or backup, empty if nothing could be recovered
+	SavedAt       string `json:"savedAt"`       // When the recovered copy was written
+	Lost          string `json:"lost"`          // What the recovered copy is missing
+}
+
+func (e *CorruptionError) Error() string {
+	if e.RecoveredFrom == "" {
+		return fmt.Sprintf("project file %s is damaged (%s) and could not be recovered", e.Path, e.Problem)
+	}
+	return fmt.Sprintf("project file %s is damaged (%s); recovered from %s, %s", e.Path, e.Problem, e.RecoveredFrom, e.Lost)
+}
+
+func (e *CorruptionError) Unwrap() error {
+	return e.Cause
+}
+
+// contentHash returns the checksum of a serialized project. The checksum
+// field itself is left out and the remaining JSON is put in canonical form,
+// so the hash does not depend on formatting.
+func contentHash(data []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return "", err
+	}
+	delete(fields, "checksum")
+
+	canonical, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	return hashContents(canonical), nil
+}
+
+// verifyChecksum checks the contents of a project file against the checksum
+// it carries. Files written before checksums were added carry none and pass.
+func verifyChecksum(data []byte) error {
+	var stored struct {
+		Checksum string `json:"checksum"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	if stored.Checksum == "" {
+		return nil
+	}
+
+	actual, err := contentHash(data)
+	if err != nil {
+		return err
+	}
+	if actual != stored.Checksum {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// decodeVerified checks and parses the contents of a project file
+func decodeVerified(data []byte) (*Project, string, error) {
+	if err := verifyChecksum(data); err != nil {
+		return nil, "", err
+	}
+	return decode(data)
+}
+
+// recoverDamaged looks for an intact copy of the damaged project file at path,
+// first in a temporary file left by an interrupted save and then in its
+// backups from newest to oldest. It returns nil if there is none.
+func recoverDamaged(path string, cause error) (*Project, *CorruptionError) {
+	report := &CorruptionError{Path: path, Cause: cause, Problem: cause.Error()}
+
+	type candidate struct {
+		path string
+		lost string
+	}
+	candidates := []candidate{{path + ".tmp", "which was left by an interrupted save"}}
+	if backups, err := ListBackups(path); err == nil {
+		for _, b := range backups {
+			candidates = append(candidates, candidate{b.Path, "changes saved after " + b.SavedAt + " are lost"})
+		}
+	}
+
+	for _, c := range candidates {
+		data, err := os.ReadFile(c.path)
+		if err != nil {
+			continue
+		}
+		project, _, err := decodeVerified(data)
+		if err != nil {
+			continue
+		}
+
+		report.RecoveredFrom = c.path
+		report.Lost = c.lost
+		if info, err := os.Stat(c.path); err == nil {
+			report.SavedAt = info.ModTime().Format(time.RFC3339)
+		}
+		return project, report
+	}
+	return nil, report
+}
@@ -0,0 +1,134 @@
+package project_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+)
+
+// saveTwice saves two versions of a project so the first becomes a backup
+func saveTwice(t *testing.T, path string) {
+	t.Helper()
+	p := project.New("Checked")
+	p.SetPreference("state", "first")
+	if err := p.SaveAs(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	p.SetPreference("state", "second")
+	if err := p.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+}
+
+// tamper changes a value in the file without breaking its JSON
+func tamper(t *testing.T, path string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read project file: %v", err)
+	}
+	damaged := bytes.Replace(data, []byte(`"second"`), []byte(`"secXnd"`), 1)
+	if bytes.Equal(data, damaged) {
+		t.Fatal("Expected to find the value to tamper with")
+	}
+	if err := os.WriteFile(path, damaged, 0644); err != nil {
+		t.Fatalf("Failed to write project file: %v", err)
+	}
+}
+
+func TestLoadVerifiesChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "project.json")
+	saveTwice(t, path)
+
+	p, err := project.Load(path)
+	if err != nil {
+		t.Fatalf("An intact file should load: %v", err)
+	}
+	if p.Checksum == "" || p.GetPreference("state") != "second" {
+		t.Errorf("Expected the saved version with its checksum, got %q", p.GetPreference("state"))
+	}
+
+	// Files written before checksums existed still load
+	legacy := filepath.Join(t.TempDir(), "legacy.json")
+	contents := `{"id": "legacy", "version": "1.1", "name": "Legacy", "preferences": {}, "data": {}}`
+	if err := os.WriteFile(legacy, []byte(contents), 0644); err != nil {
+		t.Fatalf("Failed to write legacy file: %v", err)
+	}
+	if _, err := project.Load(legacy); err != nil {
+		t.Errorf("A file without a checksum should load: %v", err)
+	}
+}
+
+func TestLoadRecoversFromBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "project.json")
+	saveTwice(t, path)
+	tamper(t, path)
+
+	p, err := project.Load(path)
+	var damaged *project.CorruptionError
+	if !errors.As(err, &damaged) || !errors.Is(err, project.ErrChecksumMismatch) {
+		t.Fatalf("Expected a CorruptionError for a checksum mismatch, got %v", err)
+	}
+	if p == nil || p.GetPreference("state") != "first" {
+		t.Fatal("Expected the backed up version to be recovered")
+	}
+	if damaged.RecoveredFrom != path+".bak.1" || damaged.Lost == "" {
+		t.Errorf("Expected the report to name the backup and what was lost, got %+v", damaged)
+	}
+	if !p.IsDirty() || p.GetPath() != path {
+		t.Error("A recovered project should be dirty and keep its own path")
+	}
+
+	// Saving the recovered project repairs the file
+	if err := p.Save(); err != nil {
+		t.Fatalf("Saving the recovered project failed: %v", err)
+	}
+	if _, err := project.Load(path); err != nil {
+		t.Errorf("The repaired file should load: %v", err)
+	}
+}
+
+func TestLoadRecoversFromTempFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "project.json")
+	saveTwice(t, path)
+
+	// An interrupted save leaves the complete new version in the temporary file
+	intact, _ := os.ReadFile(path)
+	if err := os.WriteFile(path+".tmp", intact, 0644); err != nil {
+		t.Fatalf("Failed to write temporary file: %v", err)
+	}
+	if err := os.WriteFile(path, intact[:len(intact)/2], 0644); err != nil {
+		t.Fatalf("Failed to truncate project file: %v", err)
+	}
+
+	p, err := project.Load(path)
+	var damaged *project.CorruptionError
+	if !errors.As(err, &damaged) || p == nil {
+		t.Fatalf("Expected a recovered project, got %v", err)
+	}
+	if damaged.RecoveredFrom != path+".tmp" || p.GetPreference("state") != "second" {
+		t.Errorf("Expected the temporary file to be preferred, got %+v", damaged)
+	}
+}
+
+func TestLoadReportsUnrecoverable(t *testing.T) {
+	project.SetBackupGenerations(0)
+	defer project.SetBackupGenerations(project.DefaultBackups)
+
+	path := filepath.Join(t.TempDir(), "project.json")
+	saveTwice(t, path)
+	tamper(t, path)
+
+	p, err := project.Load(path)
+	var damaged *project.CorruptionError
+	if !errors.As(err, &damaged) || p != nil {
+		t.Fatalf("Expected a CorruptionError and no project, got %v", err)
+	}
+	if damaged.RecoveredFrom != "" {
+		t.Errorf("Nothing should have been recovered, got %s", damaged.RecoveredFrom)
+	}
+}
@@ -2,13 +2,15 @@
 package project
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
-	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/google/uuid"
 )
 
 const (
@@ -20,140 +22,281 @@ const (
 	AllProjectsClosed = "all_projects_closed"
 	ProjectSaved      = "project_saved"
 	ProjectSavedAs    = "project_saved_as"
+	ProjectUndone     = "project_undone"
+	ProjectRedone     = "project_redone"
+	ProjectRecovered  = "project_recovered"
+	ProjectReloaded   = "project_reloaded"
+	ProjectMerged     = "project_merged"
+	ProjectRestored   = "project_restored"
 )
 
 // Manager handles multiple Project instances, maintaining a collection of open projects
-// and tracking which one is currently active.
+// and tracking which one is currently active. All access to the collection goes through
+// the Manager's methods, which are safe for concurrent use.
 type Manager struct {
-	OpenProjects map[string]*Project
-	ActiveID     string
+	openProjects map[string]*Project
+	order        []string // IDs of open projects in the order they were opened
+	activeID     string
+	mutex        sync.RWMutex
+	autosave     autosaver
+	watcher      fileWatcher
+	memory       memoryPolicy
 }
 
 // NewManager creates a new project manager with no open projects
 func NewManager() *Manager {
 	return &Manager{
-		OpenProjects: make(map[string]*Project),
-		ActiveID:     "",
+		openProjects: make(map[string]*Project),
+		activeID:     "",
+		autosave: autosaver{
+			written: make(map[string]bool),
+		},
 	}
 }
 
 // Active returns the currently active project, or nil if no project is active
 func (m *Manager) Active() *Project {
-	if m.ActiveID == "" {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.activeID == "" {
 		return nil
 	}
-	return m.OpenProjects[m.ActiveID]
+	return m.openProjects[m.activeID]
+}
+
+// ActiveID returns the ID of the currently active project, or an empty string
+func (m *Manager) ActiveID() string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.activeID
+}
+
+// Snapshot returns a copy of the open projects keyed by ID together with the
+// active ID, both captured under the same lock so they are consistent
+func (m *Manager) Snapshot() (map[string]*Project, string) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	projects := make(map[string]*Project, len(m.openProjects))
+	for id, project := range m.openProjects {
+		projects[id] = project
+	}
+	return projects, m.activeID
 }
 
-// SetActive sets the active project by ID
+// SetActive sets the active project by ID. The project becomes active even
+// if its evicted data cannot be restored, and the error says why.
 func (m *Manager) SetActive(id string) error {
-	if m.ActiveID == id {
+	m.mutex.Lock()
+	if m.activeID == id {
+		m.mutex.Unlock()
 		return nil
 	}
 
-	if _, exists := m.OpenProjects[id]; !exists {
+	project, exists := m.openProjects[id]
+	if !exists {
+		m.mutex.Unlock()
 		return fmt.Errorf("no project with ID %s exists", id)
 	}
 
-	if m.ActiveID != "" {
-		prevProject := m.OpenProjects[m.ActiveID]
-		if prevProject != nil {
-			m.minimizeInactiveProject(prevProject)
-		}
-	}
+	trim := m.activate(id)
+	m.mutex.Unlock()
 
-	m.ActiveID = id
+	err := m.settle(project, trim)
 	msgs.EmitManager(ProjectActivated)
-	return nil
+	return err
 }
 
-// minimizeInactiveProject reduces memory usage of inactive projects
-// This method provides a framework for memory optimization that can be
-// extended in the future as project complexity grows
-func (m *Manager) minimizeInactiveProject(project *Project) {
-	// This is a hook for future memory optimization
-	// For now, we don't have specific memory-intensive structures to clear
-	// but having this framework in place allows for easy extension later
+// activate makes id the active project and lets the previously active one be
+// trimmed. It returns the evictions to pass to settle once the manager's lock
+// is released. The caller must hold the manager's lock.
+func (m *Manager) activate(id string) eviction {
+	prevProject := m.openProjects[m.activeID]
+	m.activeID = id
+	m.touch(id)
+	// With one more project inactive, the least recently active clean
+	// projects may now be over the limit set by SetMemoryLimit
+	if prevProject != nil && prevProject.ID != id {
+		return m.trimResident()
+	}
+	return eviction{}
+}
 
-	// Example memory optimization patterns that could be implemented:
-	// 1. Clear any cached computation results
-	// 2. Clear any non-essential data structures that can be rebuilt
-	// 3. If T implements a ClearCache() method, call it
+// settle does the disk I/O of an activation without the manager's lock: it
+// restores the newly active project's data if it was evicted, which it
+// reports failing to do, and evicts the projects activate picked
+func (m *Manager) settle(active *Project, trim eviction) error {
+	trim.run(m)
+	if err := active.rehydrate(); err != nil {
+		return fmt.Errorf("failed to restore project data: %w", err)
+	}
+	return nil
 }
 
 // New creates a new project with the given name and makes it the active project
 func (m *Manager) New(name string) *Project {
 	project := New(name)
-	id := name
-	m.OpenProjects[id] = project
-	m.ActiveID = id
+	m.addNew(project)
+
 	msgs.EmitManager(ProjectCreated)
 	return project
 }
 
+// addNew adds a newly created project and makes it the active one
+func (m *Manager) addNew(project *Project) {
+	m.mutex.Lock()
+
+	m.openProjects[project.ID] = project
+	m.order = append(m.order, project.ID)
+	trim := m.activate(project.ID)
+	m.mutex.Unlock()
+
+	_ = m.settle(project, trim) // a new project is always resident
+}
+
 // Open loads a project from the specified path and makes it the active project
 func (m *Manager) Open(path string) (*Project, error) {
-	for id, proj := range m.OpenProjects {
-		if proj.Path == path {
-			m.ActiveID = id
-			msgs.EmitManager(ProjectSwitched)
-			return proj, nil
-		}
+	if proj, err := m.activateByPath(path); proj != nil {
+		msgs.EmitManager(ProjectSwitched)
+		return proj, err
 	}
 
+	// Load outside the lock so slow disk reads do not block other callers
 	project, err := Load(path)
 	if err != nil {
-		return nil, err
+		var damaged *CorruptionError
+		if project == nil || !errors.As(err, &damaged) {
+			return nil, err
+		}
+		// The recovered copy opens, and the UI is told what was lost
+		report, _ := json.Marshal(damaged)
+		msgs.EmitFileDamaged(string(report))
 	}
+	project.LastOpened = time.Now().Format(time.RFC3339)
 
-	id := filepath.Base(path)
-	m.OpenProjects[id] = project
-	m.ActiveID = id
+	m.mutex.Lock()
+	// Another caller may have opened the same file while we were loading it
+	for id, proj := range m.openProjects {
+		if proj.GetPath() == path {
+			trim := m.activate(id)
+			m.mutex.Unlock()
+			err := m.settle(proj, trim)
+			msgs.EmitManager(ProjectSwitched)
+			return proj, err
+		}
+	}
 
-	project.LastOpened = time.Now().Format(time.RFC3339)
+	// A copied project file carries the same ID as its original, so give the
+	// copy its own identity rather than letting it shadow the open one
+	if _, exists := m.openProjects[project.ID]; exists {
+		project.ID = uuid.NewString()
+		project.SetDirty(true)
+	}
+
+	id := project.ID
+	m.openProjects[id] = project
+	m.order = append(m.order, id)
+	trim := m.activate(id)
+	m.mutex.Unlock()
 
+	_ = m.settle(project, trim) // a freshly loaded project is always resident
+
+	m.syncWatches()
 	msgs.EmitManager(ProjectOpened)
 	return project, nil
 }
 
+// activateByPath makes the already open project at path the active one and
+// returns it, or returns nil if no open project has that path
+func (m *Manager) activateByPath(path string) (*Project, error) {
+	m.mutex.Lock()
+	for id, proj := range m.openProjects {
+		if proj.GetPath() == path {
+			trim := m.activate(id)
+			m.mutex.Unlock()
+			return proj, m.settle(proj, trim)
+		}
+	}
+	m.mutex.Unlock()
+	return nil, nil
+}
+
 // Close closes the project with the given ID. If it's the active project,
 // the active project becomes nil.
 func (m *Manager) Close(id string) error {
-	if _, exists := m.OpenProjects[id]; !exists {
+	m.mutex.Lock()
+	if _, exists := m.openProjects[id]; !exists {
+		m.mutex.Unlock()
 		return fmt.Errorf("no project with ID %s exists", id)
 	}
 
-	delete(m.OpenProjects, id)
+	closed := m.openProjects[id]
+	delete(m.openProjects, id)
+	m.discardAutosave(id)
+	m.forget(id)
 
-	if m.ActiveID == id {
-		m.ActiveID = ""
-
-		for newID := range m.OpenProjects {
-			m.ActiveID = newID
+	index := 0
+	for i, openID := range m.order {
+		if openID == id {
+			index = i
+			m.order = append(m.order[:i], m.order[i+1:]...)
 			break
 		}
 	}
 
+	// The neighbouring project takes over, as with closing a tab
+	if m.activeID == id {
+		m.activeID = ""
+		if len(m.order) > 0 {
+			m.activeID = m.order[min(index, len(m.order)-1)]
+		}
+	}
+	next := m.openProjects[m.activeID]
+	m.mutex.Unlock()
+
+	// Closing must not leave spill files behind, and the newly active project is used right away
+	closed.discardSpill()
+	if next != nil {
+		if err := next.rehydrate(); err != nil {
+			msgs.EmitError("Restoring project data failed", err)
+		}
+	}
+
+	m.syncWatches()
 	msgs.EmitManager(ProjectClosed)
 	return nil
 }
 
 // CloseAll closes all open projects
 func (m *Manager) CloseAll() {
-	m.OpenProjects = make(map[string]*Project)
-	m.ActiveID = ""
+	m.mutex.Lock()
+	closed := m.openProjects
+	for id := range closed {
+		m.discardAutosave(id)
+	}
+	m.openProjects = make(map[string]*Project)
+	m.order = nil
+	m.activeID = ""
+	m.memory.recent = nil
+	m.mutex.Unlock()
+
+	for _, project := range closed {
+		project.discardSpill()
+	}
+
+	m.syncWatches()
 	msgs.EmitManager(AllProjectsClosed)
 }
 
 // SaveActive saves the currently active project
 func (m *Manager) SaveActive() error {
-	if m.ActiveID == "" {
+	project := m.Active()
+	if project == nil {
 		return errors.New("no active project to save")
 	}
 
-	project := m.OpenProjects[m.ActiveID]
-
-	if strings.HasPrefix(project.Name, "New Project") {
+	if strings.HasPrefix(project.GetName(), "New Project") {
 		return m.SaveActiveAs("")
 	}
 
@@ -166,25 +309,41 @@ func (m *Manager) SaveActive() error {
 	return err
 }
 
+// Save saves the project with the given ID to its file, whether or not it is
+// the active one
+func (m *Manager) Save(id string) error {
+	project := m.GetProjectByID(id)
+	if project == nil {
+		return fmt.Errorf("no project with ID %s exists", id)
+	}
+
+	if err := project.Save(); err != nil {
+		return err
+	}
+
+	msgs.EmitManager(ProjectSaved)
+	return nil
+}
+
 // SaveActiveAs saves the currently active project to a new path
 func (m *Manager) SaveActiveAs(path string) error {
-	if m.ActiveID == "" {
+	project := m.Active()
+	if project == nil {
 		return errors.New("no active project to save")
 	}
 
-	project := m.OpenProjects[m.ActiveID]
-
-	if strings.HasPrefix(project.Name, "New Project") {
-		wasDirty := project.Dirty
-		project.Dirty = true
+	if strings.HasPrefix(project.GetName(), "New Project") {
+		wasDirty := project.IsDirty()
+		project.SetDirty(true)
 
 		err := project.SaveAs(path)
 
 		if !wasDirty && err == nil {
-			project.Dirty = false
+			project.SetDirty(false)
 		}
 
 		if err == nil {
+			m.syncWatches()
 			msgs.EmitManager(ProjectSavedAs)
 		} else {
 			msgs.EmitError("Save project as", err)
@@ -194,6 +353,7 @@ func (m *Manager) SaveActiveAs(path string) error {
 
 	err := project.SaveAs(path)
 	if err == nil {
+		m.syncWatches()
 		msgs.EmitManager(ProjectSavedAs)
 	} else {
 		msgs.EmitError("Save project as", err)
@@ -201,18 +361,43 @@ func (m *Manager) SaveActiveAs(path string) error {
 	return err
 }
 
-// GetOpenProjectIDs returns a slice of IDs for all open projects
+// GetOpenProjectIDs returns the IDs of all open projects in the order they were opened
 func (m *Manager) GetOpenProjectIDs() []string {
-	ids := make([]string, 0, len(m.OpenProjects))
-	for id := range m.OpenProjects {
-		ids = append(ids, id)
-	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	ids := make([]string, len(m.order))
+	copy(ids, m.order)
 	return ids
 }
 
+// OpenPaths returns the files of all open projects in the order they were
+// opened, along with the file of the active project. Projects that have never
+// been saved have no file and are left out.
+func (m *Manager) OpenPaths() ([]string, string) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	paths := make([]string, 0, len(m.order))
+	for _, id := range m.order {
+		if path := m.openProjects[id].GetPath(); path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	activePath := ""
+	if active := m.openProjects[m.activeID]; active != nil {
+		activePath = active.GetPath()
+	}
+	return paths, activePath
+}
+
 // HasUnsavedChanges returns true if any open project has unsaved changes
 func (m *Manager) HasUnsavedChanges() bool {
-	for _, project := range m.OpenProjects {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, project := range m.openProjects {
 		if project.IsDirty() {
 			return true
 		}
@@ -220,17 +405,111 @@ func (m *Manager) HasUnsavedChanges() bool {
 	return false
 }
 
-// GetProjectByID returns the project with the given ID, or nil if it doesn't exist
+// GetProjectByID returns the project with the given ID, or nil if it doesn't
+// exist. The data of an evicted project is brought back into memory.
 func (m *Manager) GetProjectByID(id string) *Project {
-	return m.OpenProjects[id]
+	m.mutex.RLock()
+	project := m.openProjects[id]
+	m.mutex.RUnlock()
+
+	if project != nil {
+		if err := project.rehydrate(); err != nil {
+			msgs.EmitError("Restoring project data failed", err)
+		}
+	}
+	return project
 }
 
 // GetProjectByPath returns the project with the given path, or nil if it doesn't exist
 func (m *Manager) GetProjectByPath(path string) *Project {
-	for _, project := range m.OpenProjects {
-		if project.Path == path {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, project := range m.openProjects {
+		if project.GetPath() == path {
 			return project
 		}
 	}
 	return nil
 }
+
+// Reload discards the unsaved changes of the project with the given ID and
+// rereads it from its file
+func (m *Manager) Reload(id string) error {
+	project := m.GetProjectByID(id)
+	if project == nil {
+		return fmt.Errorf("no project with ID %s exists", id)
+	}
+
+	if err := project.Reload(); err != nil {
+		return err
+	}
+
+	m.discardAutosave(id)
+	msgs.EmitManager(ProjectReloaded)
+	return nil
+}
+
+// Overwrite saves the project with the given ID over its file, replacing any
+// changes made to the file by another program
+func (m *Manager) Overwrite(id string) error {
+	project := m.GetProjectByID(id)
+	if project == nil {
+		return fmt.Errorf("no project with ID %s exists", id)
+	}
+
+	if err := project.Overwrite(); err != nil {
+		return err
+	}
+
+	msgs.EmitManager(ProjectSaved)
+	return nil
+}
+
+// Merge folds changes made to the file of the project with the given ID into
+// the open project and returns the keys that changed on both sides
+func (m *Manager) Merge(id string) ([]string, error) {
+	project := m.GetProjectByID(id)
+	if project == nil {
+		return nil, fmt.Errorf("no project with ID %s exists", id)
+	}
+
+	conflicts, err := project.Merge()
+	if err != nil {
+		return nil, err
+	}
+
+	msgs.EmitManager(ProjectMerged)
+	return conflicts, nil
+}
+
+// NewFromTemplate creates a project named name from the template t, creating
+// the template's folders under folderRoot, and makes it the active project
+func (m *Manager) NewFromTemplate(name string, t Template, folderRoot string) (*Project, error) {
+	project, err := newFromTemplate(name, t, folderRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	m.addNew(project)
+
+	msgs.EmitManager(ProjectCreated)
+	return project, nil
+}
+
+// RestoreBackup rolls the file of the project with the given ID back to a
+// backup generation and reloads the project, discarding unsaved changes
+func (m *Manager) RestoreBackup(id string, generation int) error {
+	project := m.GetProjectByID(id)
+	if project == nil {
+		return fmt.Errorf("no project with ID %s exists", id)
+	}
+
+	if err := project.RestoreBackup(generation); err != nil {
+		return err
+	}
+
+	m.discardAutosave(id)
+	msgs.EmitManager(ProjectRestored)
+	return nil
+}
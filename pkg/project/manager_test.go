@@ -1,8 +1,10 @@
 package project_test
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
@@ -64,7 +66,7 @@ func TestManager(t *testing.T) {
 	}
 
 	// Close the active project
-	activeID := manager.ActiveID
+	activeID := manager.ActiveID()
 	if err := manager.Close(activeID); err != nil {
 		t.Fatalf("Failed to close project: %v", err)
 	}
@@ -82,3 +84,96 @@ func TestManager(t *testing.T) {
 		t.Errorf("Expected 0 open projects, got %d", len(manager.GetOpenProjectIDs()))
 	}
 }
+
+// TestManagerConcurrentAccess exercises the manager from many goroutines at once.
+// Run with -race to detect unsynchronized access to the open projects.
+func TestManagerConcurrentAccess(t *testing.T) {
+	manager := project.NewManager()
+
+	tempDir := t.TempDir()
+	paths := make([]string, 4)
+	for i := range paths {
+		paths[i] = filepath.Join(tempDir, fmt.Sprintf("project-%d.json", i))
+		if err := project.New(fmt.Sprintf("project-%d", i)).SaveAs(paths[i]); err != nil {
+			t.Fatalf("Failed to save project: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				id := manager.New(fmt.Sprintf("worker-%d-%d", n, j)).GetID()
+				if _, err := manager.Open(paths[j%len(paths)]); err != nil {
+					t.Errorf("Failed to open project: %v", err)
+					return
+				}
+				_ = manager.SetActive(id)
+				projects, activeID := manager.Snapshot()
+				if activeID != "" && projects[activeID] == nil {
+					t.Errorf("Snapshot active ID %s is not among the open projects", activeID)
+				}
+				_ = manager.Active()
+				_ = manager.HasUnsavedChanges()
+				_ = manager.GetProjectByPath(paths[0])
+				_ = manager.Close(id)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := len(manager.GetOpenProjectIDs()); got != len(paths) {
+		t.Errorf("Expected %d open projects, got %d", len(paths), got)
+	}
+}
+
+// TestManagerKeysByProjectID verifies that projects with the same file name in
+// different folders, or copies of the same file, are tracked separately
+func TestManagerKeysByProjectID(t *testing.T) {
+	manager := project.NewManager()
+
+	tempDir := t.TempDir()
+	pathA := filepath.Join(tempDir, "a", "project.json")
+	pathB := filepath.Join(tempDir, "b", "project.json")
+
+	original := project.New("original")
+	if err := original.SaveAs(pathA); err != nil {
+		t.Fatalf("Failed to save project: %v", err)
+	}
+	if err := original.SaveAs(pathB); err != nil {
+		t.Fatalf("Failed to save copy of project: %v", err)
+	}
+
+	projA, err := manager.Open(pathA)
+	if err != nil {
+		t.Fatalf("Failed to open project: %v", err)
+	}
+	projB, err := manager.Open(pathB)
+	if err != nil {
+		t.Fatalf("Failed to open copy of project: %v", err)
+	}
+
+	if projA.GetID() == projB.GetID() {
+		t.Fatal("Copies of a project should not share an ID once both are open")
+	}
+	if len(manager.GetOpenProjectIDs()) != 2 {
+		t.Fatalf("Expected 2 open projects, got %d", len(manager.GetOpenProjectIDs()))
+	}
+
+	projA.SetName("renamed")
+	if manager.GetProjectByID(projA.GetID()) != projA {
+		t.Error("Renaming a project should not change how the manager finds it")
+	}
+
+	if err := manager.SetActive(projA.GetID()); err != nil {
+		t.Fatalf("Failed to activate project by ID: %v", err)
+	}
+	if err := manager.Close(projB.GetID()); err != nil {
+		t.Fatalf("Failed to close project by ID: %v", err)
+	}
+	if manager.ActiveID() != projA.GetID() {
+		t.Error("Closing an inactive project should not change the active project")
+	}
+}
@@ -0,0 +1,216 @@
+// package project contains the data structures and methods for managing project files
+package project
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// spillFile is the on-disk form of an evicted project's data
+type spillFile struct {
+	Data map[string][]byte `json:"data"` // Kept as bytes so the sections round-trip exactly
+	Base []byte            `json:"base,omitempty"`
+}
+
+// IsResident returns false if the project's data has been evicted from memory
+func (p *Project) IsResident() bool {
+	defer p.lock()()
+	return p.spill == ""
+}
+
+// spillName names a project's spill file after a hash of its path and ID.
+// IDs come from project files, so one could otherwise leave the spill
+// folder, and copies of a project file share theirs.
+func spillName(path, id string) string {
+	sum := sha256.Sum256([]byte(path + "\x00" + id))
+	return hex.EncodeToString(sum[:16]) + ".spill.json"
+}
+
+// evict writes the project's data to a spill file in dir and releases it from
+// memory. Projects with unsaved changes are never evicted.
+func (p *Project) evict(dir string) error {
+	defer p.lock()()
+	if p.spill != "" || p.hist.isDirty() {
+		return nil
+	}
+
+	spilled := spillFile{Data: make(map[string][]byte, len(p.Data)), Base: p.disk.base}
+	for key, raw := range p.Data {
+		spilled.Data[key] = raw
+	}
+	contents, err := json.Marshal(spilled)
+	if err != nil {
+		return fmt.Errorf("failed to serialize project data: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create spill folder: %w", err)
+	}
+
+	path := filepath.Join(dir, spillName(p.Path, p.ID))
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, contents, 0644); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write spill file: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to finalize spill file: %w", err)
+	}
+
+	p.spill = path
+	p.Data = nil
+	p.disk.base = nil
+	return nil
+}
+
+// discardSpill removes the spill file of a project that is being closed
+// without reading it back. Should the project be used after all, its data is
+// restored from its unchanged project file.
+func (p *Project) discardSpill() {
+	defer p.lock()()
+	if p.spill != "" {
+		os.Remove(p.spill)
+	}
+}
+
+// rehydrate brings an evicted project's data back into memory
+func (p *Project) rehydrate() error {
+	defer p.lock()()
+	return p.resident()
+}
+
+// resident makes sure the project's data is in memory, reading it back from
+// the spill file or, failing that, from the unchanged project file. The caller
+// must hold the project's lock.
+func (p *Project) resident() error {
+	if p.spill == "" {
+		return nil
+	}
+
+	var spilled spillFile
+	contents, err := os.ReadFile(p.spill)
+	if err == nil {
+		err = json.Unmarshal(contents, &spilled)
+	}
+	if err != nil {
+		// The project was clean when it was evicted, so its file holds the same data
+		fromFile, fileErr := os.ReadFile(p.Path)
+		if p.Path == "" || fileErr != nil || hashContents(fromFile) != p.disk.hash {
+			return fmt.Errorf("failed to restore data of project %s: %w", p.Name, err)
+		}
+		fresh, _, decodeErr := decode(fromFile)
+		if decodeErr != nil {
+			return fmt.Errorf("failed to restore data of project %s: %w", p.Name, decodeErr)
+		}
+		p.Data = fresh.Data
+		spilled.Base = fromFile
+	} else {
+		p.Data = make(map[string]json.RawMessage, len(spilled.Data))
+		for key, raw := range spilled.Data {
+			p.Data[key] = raw
+		}
+	}
+
+	os.Remove(p.spill)
+	p.spill = ""
+	p.disk.base = spilled.Base
+	return nil
+}
+
+// eviction is the spill I/O chosen under the manager's lock and carried out
+// by run once the lock is released
+type eviction struct {
+	dir      string
+	projects []*Project
+}
+
+// memoryPolicy limits how many open projects keep their data in memory. It is
+// guarded by the manager's lock.
+type memoryPolicy struct {
+	maxResident int      // zero or less means no limit
+	spillDir    string   // where evicted data is written
+	recent      []string // project IDs, least recently active first
+}
+
+// SetMemoryLimit keeps at most maxResident open projects fully in memory,
+// writing the data of the least recently active clean projects to spillDir.
+// Evicted data is restored transparently when the project is used again.
+// A limit of zero or less keeps every project resident.
+func (m *Manager) SetMemoryLimit(maxResident int, spillDir string) {
+	m.mutex.Lock()
+	m.memory.maxResident = maxResident
+	m.memory.spillDir = spillDir
+	trim := m.trimResident()
+	m.mutex.Unlock()
+
+	trim.run(m)
+}
+
+// touch records id as the most recently active project. The caller must hold the manager's lock.
+func (m *Manager) touch(id string) {
+	m.forget(id)
+	m.memory.recent = append(m.memory.recent, id)
+}
+
+// forget drops id from the activity order. The caller must hold the manager's lock.
+func (m *Manager) forget(id string) {
+	for i, recentID := range m.memory.recent {
+		if recentID == id {
+			m.memory.recent = append(m.memory.recent[:i], m.memory.recent[i+1:]...)
+			return
+		}
+	}
+}
+
+// trimResident picks the least recently active clean projects to evict so no
+// more than the configured number remain resident. The active project is
+// never picked. The caller must hold the manager's lock.
+func (m *Manager) trimResident() eviction {
+	trim := eviction{dir: m.memory.spillDir}
+	if m.memory.maxResident <= 0 || m.memory.spillDir == "" {
+		return trim
+	}
+
+	// The active project counts as resident, as settle restores it after the lock is released
+	resident := 0
+	for id, project := range m.openProjects {
+		if id == m.activeID || project.IsResident() {
+			resident++
+		}
+	}
+
+	for _, id := range m.memory.recent {
+		if resident <= m.memory.maxResident {
+			break
+		}
+		project := m.openProjects[id]
+		if id == m.activeID || project == nil || !project.IsResident() || project.IsDirty() {
+			continue
+		}
+		trim.projects = append(trim.projects, project)
+		resident--
+	}
+	return trim
+}
+
+// run writes the picked projects to spill files. It is called without the
+// manager's lock, so a project closed in the meantime has its spill file
+// removed again, and a project changed in the meantime is not evicted.
+func (e eviction) run(m *Manager) {
+	for _, project := range e.projects {
+		if err := project.evict(e.dir); err != nil {
+			continue
+		}
+		m.mutex.RLock()
+		open := m.openProjects[project.ID] == project
+		m.mutex.RUnlock()
+		if !open {
+			project.discardSpill()
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package project_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+)
+
+// cloneData copies a project's encoded sections so they can be compared after eviction
+func cloneData(data map[string]json.RawMessage) map[string]json.RawMessage {
+	clone := make(map[string]json.RawMessage, len(data))
+	for key, raw := range data {
+		clone[key] = append(json.RawMessage(nil), raw...)
+	}
+	return clone
+}
+
+func TestMemoryLimitEvictsAndRestoresLosslessly(t *testing.T) {
+	dir := t.TempDir()
+	spillDir := filepath.Join(dir, "spill")
+
+	m := project.NewManager()
+	m.SetMemoryLimit(1, spillDir)
+
+	var ids []string
+	originals := map[string]map[string]json.RawMessage{}
+	for i := 0; i < 3; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("project%d.json", i))
+		p := project.New(fmt.Sprintf("Project %d", i))
+		if err := p.SetSection(project.NotesSection, project.Notes{Text: fmt.Sprintf("notes for %d", i)}); err != nil {
+			t.Fatalf("Failed to set notes: %v", err)
+		}
+		if err := p.SaveAs(path); err != nil {
+			t.Fatalf("Failed to save project: %v", err)
+		}
+
+		opened, err := m.Open(path)
+		if err != nil {
+			t.Fatalf("Failed to open project: %v", err)
+		}
+		ids = append(ids, opened.GetID())
+		originals[opened.GetID()] = cloneData(opened.Data)
+	}
+
+	// Only the active, most recently opened project stays resident
+	projects, activeID := m.Snapshot()
+	for id, p := range projects {
+		if resident := p.IsResident(); resident != (id == activeID) {
+			t.Errorf("Project %s: expected resident=%v, got %v", p.GetName(), id == activeID, resident)
+		}
+	}
+	if files, _ := os.ReadDir(spillDir); len(files) != 2 {
+		t.Errorf("Expected two spill files, got %d", len(files))
+	}
+
+	// Activating an evicted project brings its data back exactly as it was
+	if err := m.SetActive(ids[0]); err != nil {
+		t.Fatalf("Failed to activate project: %v", err)
+	}
+	first := projects[ids[0]]
+	if !first.IsResident() {
+		t.Fatal("The active project should be resident")
+	}
+	if !reflect.DeepEqual(first.Data, originals[ids[0]]) {
+		t.Errorf("Data changed across eviction:\n got %s\nwant %s", first.Data, originals[ids[0]])
+	}
+	if projects[ids[2]].IsResident() {
+		t.Error("The previously active project should have been evicted")
+	}
+
+	// Looking a project up by ID restores it too
+	second := m.GetProjectByID(ids[1])
+	if !second.IsResident() || !reflect.DeepEqual(second.Data, originals[ids[1]]) {
+		t.Error("GetProjectByID should restore the project's data")
+	}
+	notes, err := project.GetSection[project.Notes](second, project.NotesSection)
+	if err != nil || notes.Text != "notes for 1" {
+		t.Errorf("Expected restored notes, got %q (%v)", notes.Text, err)
+	}
+
+	m.CloseAll()
+	if files, _ := os.ReadDir(spillDir); len(files) != 0 {
+		t.Errorf("Closing should remove spill files, %d left", len(files))
+	}
+}
+
+func TestMemoryLimitKeepsUnsavedChanges(t *testing.T) {
+	dir := t.TempDir()
+	m := project.NewManager()
+	m.SetMemoryLimit(1, filepath.Join(dir, "spill"))
+
+	unsaved := m.New("New Project 1")
+	if err := unsaved.SetSection(project.NotesSection, project.Notes{Text: "unsaved"}); err != nil {
+		t.Fatalf("Failed to set notes: %v", err)
+	}
+
+	saved := m.New("New Project 2")
+	if err := saved.SaveAs(filepath.Join(dir, "saved.json")); err != nil {
+		t.Fatalf("Failed to save project: %v", err)
+	}
+	m.New("New Project 3")
+
+	if !unsaved.IsResident() {
+		t.Error("A project with unsaved changes must never be evicted")
+	}
+	if saved.IsResident() {
+		t.Fatal("A clean inactive project should be evicted")
+	}
+
+	// Using an evicted project directly restores it before anything reads its data
+	if err := saved.SetSection(project.NotesSection, project.Notes{Text: "edited"}); err != nil {
+		t.Fatalf("Failed to edit evicted project: %v", err)
+	}
+	if !saved.Undo() || saved.HasSection(project.NotesSection) {
+		t.Error("Undo should work on a restored project")
+	}
+}
+
+func TestSetActiveReportsFailedRestore(t *testing.T) {
+	dir := t.TempDir()
+	spillDir := filepath.Join(dir, "spill")
+	m := project.NewManager()
+	m.SetMemoryLimit(1, spillDir)
+
+	var evicted *project.Project
+	for i := 0; i < 2; i++ {
+		p := project.New(fmt.Sprintf("Project %d", i))
+		path := filepath.Join(dir, fmt.Sprintf("project%d.json", i))
+		if err := p.SaveAs(path); err != nil {
+			t.Fatalf("Failed to save project: %v", err)
+		}
+		opened, err := m.Open(path)
+		if err != nil {
+			t.Fatalf("Failed to open project: %v", err)
+		}
+		if i == 0 {
+			evicted = opened
+		}
+	}
+	if evicted.IsResident() {
+		t.Fatal("Expected the first project to be evicted")
+	}
+
+	// With the spill file gone and the project file changed, nothing is left to restore from
+	_ = os.RemoveAll(spillDir)
+	_ = os.WriteFile(evicted.GetPath(), []byte(`{"name": "changed"}`), 0644)
+
+	if err := m.SetActive(evicted.GetID()); err == nil {
+		t.Error("Expected SetActive to report the failed restore")
+	}
+	if m.ActiveID() != evicted.GetID() {
+		t.Error("The project should still be active")
+	}
+}
+
+func TestSpillFilesStayInFolder(t *testing.T) {
+	dir := t.TempDir()
+	spill := filepath.Join(dir, "spill")
+	m := project.NewManager()
+	m.SetMemoryLimit(1, spill)
+
+	// The ID comes from the file and tries to leave the spill folder
+	path := filepath.Join(dir, "crafted.json")
+	contents := `{"id": "../../escaped", "name": "Crafted", "version": "` + project.CurrentVersion + `", "data": {"notes": {"text": "kept"}}}`
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	crafted, err := m.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open the crafted project: %v", err)
+	}
+	m.New("Active")
+
+	entries, _ := os.ReadDir(spill)
+	if crafted.IsResident() || len(entries) != 1 {
+		t.Fatalf("Expected the crafted project spilled into the folder, got %d files", len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped.spill.json")); !os.IsNotExist(err) {
+		t.Error("A spill file was written outside the spill folder")
+	}
+	notes, err := project.GetSection[project.Notes](crafted, project.NotesSection)
+	if err != nil || notes.Text != "kept" {
+		t.Errorf("Expected the notes to be restored, got %+v (%v)", notes, err)
+	}
+}
@@ -0,0 +1,112 @@
+// package project contains the data structures and methods for managing project files
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
+	"github.com/google/uuid"
+)
+
+// CurrentVersion is the schema version written by this build of the app
+const CurrentVersion = "1.2"
+
+// ErrNewerVersion is returned when a project file was written by a newer version of the app
+var ErrNewerVersion = errors.New("project file is from a newer version")
+
+// MigrationFunc upgrades the raw JSON fields of a project file in place
+type MigrationFunc func(fields map[string]interface{}) error
+
+type migration struct {
+	to    string
+	apply MigrationFunc
+}
+
+// migrations maps a schema version to the step that upgrades it to the next version
+var migrations = map[string]migration{}
+
+// RegisterMigration adds a step to the migration chain that upgrades project
+// files from version `from` to version `to`
+func RegisterMigration(from, to string, fn MigrationFunc) {
+	if _, exists := migrations[from]; exists {
+		panic(fmt.Sprintf("project migration from version %s is already registered", from))
+	}
+	migrations[from] = migration{to: to, apply: fn}
+}
+
+func init() {
+	// 1.0 -> 1.1: projects carry a persistent unique ID
+	RegisterMigration("1.0", "1.1", func(fields map[string]interface{}) error {
+		if id, _ := fields["id"].(string); id == "" {
+			fields["id"] = uuid.NewString()
+		}
+		return nil
+	})
+
+	// 1.1 -> 1.2: notes hold their text and when it was last changed
+	RegisterMigration("1.1", "1.2", func(fields map[string]interface{}) error {
+		data, _ := fields["data"].(map[string]interface{})
+		if text, isString := data[NotesSection].(string); isString {
+			data[NotesSection] = map[string]interface{}{"text": text}
+		}
+		return nil
+	})
+}
+
+// migrate upgrades the raw contents of a project file to CurrentVersion. It
+// returns the upgraded bytes and the version the file was written with.
+func migrate(data []byte) ([]byte, string, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, "", err
+	}
+
+	// Files written before versioning was introduced are treated as 1.0
+	original, _ := fields["version"].(string)
+	if original == "" {
+		original = "1.0"
+	}
+
+	cmp, err := validation.CompareVersions(original, CurrentVersion)
+	if err != nil {
+		return nil, original, err
+	}
+	if cmp > 0 {
+		return nil, original, fmt.Errorf("%w: file is version %s, this app supports up to %s", ErrNewerVersion, original, CurrentVersion)
+	}
+	if cmp == 0 {
+		return data, original, nil
+	}
+
+	version := original
+	for version != CurrentVersion {
+		step, exists := migrations[version]
+		if !exists {
+			return nil, original, fmt.Errorf("no migration registered from version %s", version)
+		}
+		if err := step.apply(fields); err != nil {
+			return nil, original, fmt.Errorf("migration from version %s to %s failed: %w", version, step.to, err)
+		}
+		version = step.to
+		fields["version"] = version
+	}
+
+	upgraded, err := json.Marshal(fields)
+	if err != nil {
+		return nil, original, err
+	}
+	return upgraded, original, nil
+}
+
+// backupBeforeMigration copies the original contents of a project file aside
+// so an upgrade can always be undone by hand
+func backupBeforeMigration(path, version string, data []byte) (string, error) {
+	backupPath := fmt.Sprintf("%s.v%s.bak", path, version)
+	if err := os.WriteFile(backupPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to back up project file before migration: %w", err)
+	}
+	return backupPath, nil
+}
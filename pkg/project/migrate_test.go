@@ -0,0 +1,116 @@
+package project
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// copyFixture copies a file from testdata into a temporary directory so Load can rewrite it
+func copyFixture(t *testing.T, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture %s: %v", name, err)
+	}
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to copy fixture %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadMigratesHistoricVersions(t *testing.T) {
+	tests := []struct {
+		fixture string
+		version string
+	}{
+		{"v1.0.json", "1.0"},
+		{"v1.1.json", "1.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			path := copyFixture(t, tt.fixture)
+			original, _ := os.ReadFile(path)
+
+			p, err := Load(path)
+			if err != nil {
+				t.Fatalf("Failed to load version %s fixture: %v", tt.version, err)
+			}
+
+			if p.Version != CurrentVersion {
+				t.Errorf("Expected version %s after load, got %s", CurrentVersion, p.Version)
+			}
+			if p.ID == "" {
+				t.Error("Expected project to have an ID after load")
+			}
+			if p.Name != "fixture-project" || p.GetPreference("theme") != "dark" {
+				t.Errorf("Fixture contents were not preserved: %+v", p)
+			}
+			// Notes were a plain string before 1.2
+			if notes, err := GetSection[Notes](p, NotesSection); err != nil || notes.Text != "written by version "+tt.version {
+				t.Errorf("Expected the fixture's notes, got %+v and %v", notes, err)
+			}
+
+			// Loading alone writes nothing, but the upgrade is unsaved
+			if !p.IsDirty() {
+				t.Error("An upgraded project should be dirty until it is saved")
+			}
+			backupPath := path + ".v" + tt.version + ".bak"
+			if _, err := os.Stat(backupPath); !os.IsNotExist(err) {
+				t.Error("Did not expect a backup before the project is saved")
+			}
+			if current, _ := os.ReadFile(path); string(current) != string(original) {
+				t.Error("Loading should leave the file as it was")
+			}
+
+			if err := p.Save(); err != nil {
+				t.Fatalf("Failed to save the upgraded project: %v", err)
+			}
+			backup, err := os.ReadFile(backupPath)
+			if err != nil {
+				t.Fatalf("Expected backup at %s: %v", backupPath, err)
+			}
+			if string(backup) != string(original) {
+				t.Error("Backup should match the original file contents")
+			}
+
+			reloaded, err := Load(path)
+			if err != nil {
+				t.Fatalf("Failed to reload migrated project: %v", err)
+			}
+			if reloaded.ID != p.ID || reloaded.IsDirty() {
+				t.Errorf("Expected migrated ID %s to persist in a clean file, got %s", p.ID, reloaded.ID)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "future.json")
+	if err := os.WriteFile(path, []byte(`{"version": "99.0", "name": "future"}`), 0644); err != nil {
+		t.Fatalf("Failed to write project: %v", err)
+	}
+
+	_, err := Load(path)
+	if !errors.Is(err, ErrNewerVersion) {
+		t.Fatalf("Expected ErrNewerVersion, got: %v", err)
+	}
+}
+
+func TestMigrationChainReachesCurrentVersion(t *testing.T) {
+	for from := range migrations {
+		version := from
+		for steps := 0; version != CurrentVersion; steps++ {
+			step, exists := migrations[version]
+			if !exists || steps > len(migrations) {
+				t.Fatalf("Migration chain from %s does not reach %s", from, CurrentVersion)
+			}
+			version = step.to
+		}
+	}
+}
@@ -0,0 +1,15 @@
+// package project contains the data structures and methods for managing project files
+package project
+
+// NotesSection is the key under which free-form project notes are stored
+const NotesSection = "notes"
+
+// Notes holds free-form text the user keeps alongside a project
+type Notes struct {
+	Text    string `json:"text"`
+	Updated string `json:"updated,omitempty"`
+}
+
+func init() {
+	RegisterSection(Section[Notes]{Key: NotesSection})
+}
@@ -3,37 +3,54 @@ package project
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // Project represents a single project with its metadata and data.
 type Project struct {
-	Version     string                 `json:"version"`
-	Name        string                 `json:"name"`
-	LastOpened  string                 `json:"last_opened"`
-	Preferences map[string]string      `json:"preferences"`
-	Dirty       bool                   `json:"dirty"`
-	Data        map[string]interface{} `json:"data"`
-	Path        string                 `json:"-"` // Not serialized, in-memory only
+	ID          string                     `json:"id"`
+	Version     string                     `json:"version"`
+	Name        string                     `json:"name"`
+	LastOpened  string                     `json:"last_opened"`
+	Preferences map[string]string          `json:"preferences"`
+	Dirty       bool                       `json:"dirty"`              // Mirrors IsDirty, maintained by the history
+	Data        map[string]json.RawMessage `json:"data"`               // Encoded sections, see RegisterSection
+	Checksum    string                     `json:"checksum,omitempty"` // Hash of the other fields when saved, see Load
+	Path        string                     `json:"-"`                  // Not serialized, in-memory only
+	hist        *History                   // Undo/redo history, in-memory only
+	disk        diskState                  // File contents last read or written, in-memory only
+	spill       string                     // Spill file holding Data while evicted, in-memory only
+	oldVersion  string                     // Version of the file before Load upgraded it, until the next save
+	mutex       sync.Mutex                 // Guards the project against background readers such as autosave, see lock
 }
 
 // New creates a new project with default values
 func New(name string) *Project {
 	return &Project{
-		Version:     "1.0",
+		ID:          uuid.NewString(),
+		Version:     CurrentVersion,
 		Name:        name,
 		LastOpened:  time.Now().Format(time.RFC3339),
 		Preferences: make(map[string]string),
 		Dirty:       true,
-		Data:        make(map[string]interface{}),
+		Data:        make(map[string]json.RawMessage),
+		hist:        &History{savedAt: -1},
 	}
 }
 
-// Load loads a project from the specified file path with optimized deserialization
+// Load loads a project from the specified file path with optimized deserialization.
+// If the file fails its checksum or cannot be parsed, Load recovers what it can
+// from a leftover temporary file or the newest intact backup and returns the
+// recovered project, marked dirty, together with a *CorruptionError. Files
+// without a checksum, such as those edited by other programs, are accepted.
 func Load(path string) (*Project, error) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return nil, fmt.Errorf("project file does not exist: %s", path)
@@ -53,34 +70,97 @@ func Load(path string) (*Project, error) {
 		return nil, fmt.Errorf("failed to read project file: %w", err)
 	}
 
-	var project Project
-	if err := json.Unmarshal(data, &project); err != nil {
-		return nil, fmt.Errorf("failed to parse project file: %w", err)
+	project, fromVersion, err := decodeVerified(data)
+	if err != nil {
+		if errors.Is(err, ErrNewerVersion) {
+			return nil, fmt.Errorf("cannot open %s: %w", path, err)
+		}
+		return loadRecovered(path, data, err)
 	}
 
 	// Set in-memory fields
 	project.Path = path
+	project.recordDisk(path, data)
+
+	// A file that needed upgrading is only marked dirty, so reading it, as
+	// the CLI and a reload do, never writes it. The next save backs up the
+	// original and writes the upgrade.
+	if fromVersion != CurrentVersion {
+		project.oldVersion = fromVersion
+		project.SetDirty(true)
+	}
+
+	if project.ID == "" {
+		project.ID = uuid.NewString()
+		project.SetDirty(true)
+	}
+
+	return project, nil
+}
+
+// decode upgrades and parses the contents of a project file into a clean,
+// in-memory project. It returns the version the contents were written with.
+func decode(data []byte) (*Project, string, error) {
+	upgraded, fromVersion, err := migrate(data)
+	if err != nil {
+		return nil, fromVersion, err
+	}
+
+	var project Project
+	if err := json.Unmarshal(upgraded, &project); err != nil {
+		return nil, fromVersion, err
+	}
+
 	project.Dirty = false
+	project.hist = &History{}
 
-	// Ensure preferences map exists
+	// Ensure preferences and data maps exist
 	if project.Preferences == nil {
 		project.Preferences = make(map[string]string)
 	}
+	if project.Data == nil {
+		project.Data = make(map[string]json.RawMessage)
+	}
+
+	return &project, fromVersion, nil
+}
+
+// loadRecovered stands in for Load when the file at path is damaged. The
+// damaged contents are recorded as the disk state so the recovered project
+// can be saved over them, which keeps them as the newest backup.
+func loadRecovered(path string, data []byte, cause error) (*Project, error) {
+	project, report := recoverDamaged(path, cause)
+	if project == nil {
+		return nil, report
+	}
 
-	return &project, nil
+	project.Path = path
+	project.recordDisk(path, data)
+	if project.ID == "" {
+		project.ID = uuid.NewString()
+	}
+	project.SetDirty(true)
+	return project, report
 }
 
-// Save persists the project to its file path
+// Save persists the project to its file path. It refuses with ErrModifiedOnDisk
+// if the file has been changed by something else since it was loaded or saved.
 func (p *Project) Save() error {
-	if p.Path == "" {
+	path := p.GetPath()
+	if path == "" {
 		return fmt.Errorf("cannot save project with empty path")
 	}
-	return p.SaveAs(p.Path)
+	if p.ModifiedOnDisk() {
+		return fmt.Errorf("%w: %s", ErrModifiedOnDisk, path)
+	}
+	return p.SaveAs(path)
 }
 
 // SaveAs saves the project to a new file path and updates the project's path
 // with optimized serialization for better performance
 func (p *Project) SaveAs(path string) error {
+	defer p.lock()()
+
 	// Ensure the directory exists
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -90,10 +170,56 @@ func (p *Project) SaveAs(path string) error {
 	// Update last opened timestamp
 	p.LastOpened = time.Now().Format(time.RFC3339)
 
+	if p.ID == "" {
+		p.ID = uuid.NewString()
+	}
+
+	// Overwriting a file Load upgraded keeps the original aside first, so the
+	// upgrade can always be undone by hand
+	if p.oldVersion != "" && path == p.Path {
+		if original, err := os.ReadFile(path); err == nil {
+			if _, err := backupBeforeMigration(path, p.oldVersion, original); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := rotateBackups(path); err != nil {
+		return err
+	}
+
+	if err := p.writeFile(path); err != nil {
+		return err
+	}
+
+	// Update in-memory state
+	p.oldVersion = ""
+	p.Path = path
+	p.hist.markSaved()
+	p.Dirty = false
+
+	return nil
+}
+
+// writeFile serializes the project to path through a temporary file so a
+// failed write never leaves a truncated project behind
+func (p *Project) writeFile(path string) error {
+	if err := p.resident(); err != nil {
+		return err
+	}
+
 	// Create a temporary file for safe writing
 	tempPath := path + ".tmp"
 
-	// Optimize serialization with a single marshal operation
+	// The checksum covers everything else, so it is computed before it is set
+	p.Checksum = ""
+	unsummed, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize project: %w", err)
+	}
+	if p.Checksum, err = contentHash(unsummed); err != nil {
+		return fmt.Errorf("failed to checksum project: %w", err)
+	}
 	data, err := json.MarshalIndent(p, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to serialize project: %w", err)
@@ -113,61 +239,78 @@ func (p *Project) SaveAs(path string) error {
 		return fmt.Errorf("failed to finalize project file: %w", err)
 	}
 
-	// Update in-memory state
-	p.Path = path
-	p.Dirty = false
-
+	p.recordDisk(path, data)
 	return nil
 }
 
-// IsDirty returns whether the project has unsaved changes
+// IsDirty returns whether the project has unsaved changes, that is whether its
+// position in the undo history differs from the position when it was last saved
 func (p *Project) IsDirty() bool {
-	return p.Dirty
+	defer p.lock()()
+	return p.hist.isDirty()
 }
 
-// SetDirty marks the project as having unsaved changes
+// SetDirty marks the project as having unsaved changes, or marks its current
+// state as the saved one
 func (p *Project) SetDirty(dirty bool) {
+	defer p.lock()()
+	if dirty {
+		p.hist.markDirty()
+	} else {
+		p.hist.markSaved()
+	}
 	p.Dirty = dirty
 }
 
+// GetID returns the persistent unique identifier of the project
+func (p *Project) GetID() string {
+	defer p.lock()()
+	return p.ID
+}
+
 // GetPath returns the file path of the project
 func (p *Project) GetPath() string {
+	defer p.lock()()
 	return p.Path
 }
 
 // GetName returns the name of the project
 func (p *Project) GetName() string {
+	defer p.lock()()
 	return p.Name
 }
 
-// SetName updates the project name and marks it as dirty
+// SetName updates the project name as an undoable change
 func (p *Project) SetName(name string) {
+	defer p.lock()()
 	if p.Name != name {
-		p.Name = name
-		p.Dirty = true
+		p.run(&setNameCommand{from: p.Name, to: name})
 	}
 }
 
 // GetPreference retrieves a project preference by key
 func (p *Project) GetPreference(key string) string {
+	defer p.lock()()
 	return p.Preferences[key]
 }
 
-// SetPreference sets a project preference and marks the project as dirty
-func (p *Project) SetPreference(key, value string) {
-	if p.Preferences[key] != value {
-		p.Preferences[key] = value
-		p.Dirty = true
+// GetPreferences returns a copy of all of the project's preferences
+func (p *Project) GetPreferences() map[string]string {
+	defer p.lock()()
+	prefs := make(map[string]string, len(p.Preferences))
+	for key, value := range p.Preferences {
+		prefs[key] = value
 	}
+	return prefs
 }
 
-// GetData returns the project data
-func (p *Project) GetData() map[string]interface{} {
-	return p.Data
-}
-
-// SetData updates the project data and marks it as dirty
-func (p *Project) SetData(data map[string]interface{}) {
-	p.Data = data
-	p.Dirty = true
+// SetPreference sets a project preference as an undoable change
+func (p *Project) SetPreference(key, value string) {
+	defer p.lock()()
+	if p.Preferences == nil {
+		p.Preferences = make(map[string]string)
+	}
+	if old, existed := p.Preferences[key]; !existed || old != value {
+		p.run(&setPreferenceCommand{key: key, from: old, to: value, fromExisted: existed})
+	}
 }
@@ -14,10 +14,9 @@ func TestProject(t *testing.T) {
 	p := project.New("test-project")
 
 	// Set some data
-	p.SetData(map[string]interface{}{
-		"key1": "value1",
-		"key2": 123,
-	})
+	if err := p.SetSection(project.NotesSection, project.Notes{Text: "value1"}); err != nil {
+		t.Fatalf("Failed to set notes: %v", err)
+	}
 
 	// Verify initial state
 	if !p.IsDirty() {
@@ -66,3 +65,61 @@ func TestProject(t *testing.T) {
 		t.Errorf("Expected project name '%s', got '%s'", "renamed-project", loadedProject.GetName())
 	}
 }
+
+// TestProjectIDIsStable verifies that a project keeps its ID across save, rename and load
+func TestProjectIDIsStable(t *testing.T) {
+	p := project.New("test-project")
+	if p.GetID() == "" {
+		t.Fatal("New project should have an ID")
+	}
+
+	tempPath := filepath.Join(t.TempDir(), "test-project.json")
+	if err := p.SaveAs(tempPath); err != nil {
+		t.Fatalf("Failed to save project: %v", err)
+	}
+
+	p.SetName("renamed-project")
+	if err := p.Save(); err != nil {
+		t.Fatalf("Failed to save renamed project: %v", err)
+	}
+
+	loaded, err := project.Load(tempPath)
+	if err != nil {
+		t.Fatalf("Failed to load project: %v", err)
+	}
+
+	if loaded.GetID() != p.GetID() {
+		t.Errorf("Expected ID %s after reload, got %s", p.GetID(), loaded.GetID())
+	}
+}
+
+// TestLoadAssignsMissingID verifies that files written without an ID are migrated on load and keep it once saved
+func TestLoadAssignsMissingID(t *testing.T) {
+	tempPath := filepath.Join(t.TempDir(), "legacy.json")
+	legacy := `{"version": "1.0", "name": "legacy", "preferences": {}, "data": {}}`
+	if err := os.WriteFile(tempPath, []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write legacy project: %v", err)
+	}
+
+	first, err := project.Load(tempPath)
+	if err != nil {
+		t.Fatalf("Failed to load legacy project: %v", err)
+	}
+	if first.GetID() == "" {
+		t.Fatal("Expected legacy project to be assigned an ID")
+	}
+	if !first.IsDirty() {
+		t.Error("Migrated project should be dirty until the ID is saved")
+	}
+	if err := first.Save(); err != nil {
+		t.Fatalf("Failed to save migrated project: %v", err)
+	}
+
+	second, err := project.Load(tempPath)
+	if err != nil {
+		t.Fatalf("Failed to reload migrated project: %v", err)
+	}
+	if second.GetID() != first.GetID() {
+		t.Errorf("Expected migrated ID %s to persist, got %s", first.GetID(), second.GetID())
+	}
+}
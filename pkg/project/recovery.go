@@ -0,0 +1,167 @@
+// package project contains the data structures and methods for managing project files
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RecoveryEntry describes an autosaved copy of a project with unsaved changes
+type RecoveryEntry struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Path    string `json:"path"` // Original project file, empty if never saved
+	SavedAt string `json:"savedAt"`
+}
+
+// recoveryFile is the on-disk form of an autosaved project
+type recoveryFile struct {
+	RecoveryEntry
+	Project json.RawMessage `json:"project"`
+}
+
+// snapshot serializes the project and describes it, under the project's lock
+func (p *Project) snapshot() (RecoveryEntry, []byte, error) {
+	defer p.lock()()
+	if err := p.resident(); err != nil {
+		return RecoveryEntry{}, nil, err
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return RecoveryEntry{}, nil, err
+	}
+
+	entry := RecoveryEntry{
+		ID:      p.ID,
+		Name:    p.Name,
+		Path:    p.Path,
+		SavedAt: time.Now().Format(time.RFC3339),
+	}
+	return entry, data, nil
+}
+
+// ErrInvalidRecoveryID is returned for a project ID that cannot name a file
+// in the recovery folder
+var ErrInvalidRecoveryID = errors.New("invalid recovery id")
+
+// recoveryPath returns the recovery file for id. IDs come from project files
+// and the frontend, so one that would leave dir is refused.
+func recoveryPath(dir, id string) (string, error) {
+	if id == "" || id == "." || id == ".." || filepath.Base(id) != id || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidRecoveryID, id)
+	}
+	return filepath.Join(dir, id+".json"), nil
+}
+
+// WriteRecovery stores an autosaved copy of the project in dir
+func WriteRecovery(dir string, p *Project) error {
+	entry, data, err := p.snapshot()
+	if err != nil {
+		return fmt.Errorf("failed to serialize project for recovery: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create recovery folder: %w", err)
+	}
+
+	contents, err := json.MarshalIndent(recoveryFile{RecoveryEntry: entry, Project: data}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize recovery file: %w", err)
+	}
+
+	path, err := recoveryPath(dir, entry.ID)
+	if err != nil {
+		return err
+	}
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, contents, 0644); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write recovery file: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to finalize recovery file: %w", err)
+	}
+	return nil
+}
+
+// ListRecovery returns the autosaved projects found in dir, newest first
+func ListRecovery(dir string) ([]RecoveryEntry, error) {
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []RecoveryEntry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	entries := make([]RecoveryEntry, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		rf, err := readRecovery(filepath.Join(dir, f.Name()))
+		if err != nil {
+			continue // A damaged recovery file is not worth failing startup over
+		}
+		entries = append(entries, rf.RecoveryEntry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].SavedAt > entries[j].SavedAt
+	})
+	return entries, nil
+}
+
+func readRecovery(path string) (recoveryFile, error) {
+	var rf recoveryFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rf, err
+	}
+	if err := json.Unmarshal(data, &rf); err != nil {
+		return rf, fmt.Errorf("failed to parse recovery file: %w", err)
+	}
+	return rf, nil
+}
+
+// LoadRecovery rebuilds the autosaved project with the given ID from dir. The
+// returned project keeps its original path and is marked as having unsaved changes.
+func LoadRecovery(dir, id string) (*Project, error) {
+	path, err := recoveryPath(dir, id)
+	if err != nil {
+		return nil, err
+	}
+	rf, err := readRecovery(path)
+	if err != nil {
+		return nil, fmt.Errorf("no recovery available for project %s: %w", id, err)
+	}
+
+	project, _, err := decode(rf.Project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse recovered project: %w", err)
+	}
+
+	project.Path = rf.Path
+	project.SetDirty(true)
+
+	return project, nil
+}
+
+// RemoveRecovery deletes the autosaved copy of the project with the given ID, if any
+func RemoveRecovery(dir, id string) error {
+	path, err := recoveryPath(dir, id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
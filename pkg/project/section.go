@@ -0,0 +1,215 @@
+// package project contains the data structures and methods for managing project files
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ErrUnknownSection is returned when a section key has not been registered
+var ErrUnknownSection = errors.New("unknown project section")
+
+// Section describes a typed block of project data that a subsystem stores
+// under its own key in the project file. Encode and Decode default to plain
+// JSON and Validate is optional. Paths is only needed for sections that refer
+// to files: it returns the value with each file path passed through move, so
+// bundles can point the paths at the copies they carry.
+type Section[T any] struct {
+	Key      string
+	Encode   func(value T) ([]byte, error)
+	Decode   func(data []byte) (T, error)
+	Validate func(value T) error
+	Paths    func(value T, move func(path string) string) T
+}
+
+// sectionCodec is the type-erased form of a registered Section
+type sectionCodec struct {
+	typ      reflect.Type
+	encode   func(value interface{}) (json.RawMessage, error)
+	decode   func(data json.RawMessage) (interface{}, error)
+	validate func(value interface{}) error
+	paths    func(value interface{}, move func(path string) string) interface{}
+}
+
+var (
+	sectionRegistry = map[string]sectionCodec{}
+	sectionMutex    sync.RWMutex
+)
+
+// RegisterSection makes a typed section available to every project. It is
+// normally called from the init function of the package that owns the data.
+func RegisterSection[T any](s Section[T]) {
+	if s.Key == "" {
+		panic("project section must have a key")
+	}
+
+	encode := s.Encode
+	if encode == nil {
+		encode = func(value T) ([]byte, error) { return json.Marshal(value) }
+	}
+	decode := s.Decode
+	if decode == nil {
+		decode = func(data []byte) (T, error) {
+			var value T
+			err := json.Unmarshal(data, &value)
+			return value, err
+		}
+	}
+	validate := s.Validate
+	if validate == nil {
+		validate = func(T) error { return nil }
+	}
+
+	codec := sectionCodec{
+		typ: reflect.TypeOf((*T)(nil)).Elem(),
+		encode: func(value interface{}) (json.RawMessage, error) {
+			return encode(value.(T))
+		},
+		decode: func(data json.RawMessage) (interface{}, error) {
+			return decode(data)
+		},
+		validate: func(value interface{}) error {
+			return validate(value.(T))
+		},
+	}
+
+	if s.Paths != nil {
+		codec.paths = func(value interface{}, move func(path string) string) interface{} {
+			return s.Paths(value.(T), move)
+		}
+	}
+
+	sectionMutex.Lock()
+	defer sectionMutex.Unlock()
+	if _, exists := sectionRegistry[s.Key]; exists {
+		panic(fmt.Sprintf("project section %s is already registered", s.Key))
+	}
+	sectionRegistry[s.Key] = codec
+}
+
+func lookupSection(key string) (sectionCodec, error) {
+	sectionMutex.RLock()
+	defer sectionMutex.RUnlock()
+
+	codec, exists := sectionRegistry[key]
+	if !exists {
+		return sectionCodec{}, fmt.Errorf("%w: %s", ErrUnknownSection, key)
+	}
+	return codec, nil
+}
+
+// movePaths returns a copy of data in which the file paths of every section
+// that declares them have been passed through move
+func movePaths(data map[string]json.RawMessage, move func(path string) string) (map[string]json.RawMessage, error) {
+	moved := make(map[string]json.RawMessage, len(data))
+	for key, raw := range data {
+		moved[key] = raw
+		codec, err := lookupSection(key)
+		if err != nil || codec.paths == nil {
+			continue
+		}
+		value, err := codec.decode(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode section %s: %w", key, err)
+		}
+		if moved[key], err = codec.encode(codec.paths(value, move)); err != nil {
+			return nil, fmt.Errorf("failed to encode section %s: %w", key, err)
+		}
+	}
+	return moved, nil
+}
+
+// GetSection returns the typed value stored under key. If the project has no
+// data for the section the zero value of T is returned. The value is the
+// caller's own copy, so changing it leaves the project and its undo history
+// alone until it is passed to SetSection.
+func GetSection[T any](p *Project, key string) (T, error) {
+	var zero T
+
+	codec, err := lookupSection(key)
+	if err != nil {
+		return zero, err
+	}
+	if codec.typ != reflect.TypeOf((*T)(nil)).Elem() {
+		return zero, fmt.Errorf("section %s holds %s, not %T", key, codec.typ, zero)
+	}
+
+	defer p.lock()()
+	if err := p.resident(); err != nil {
+		return zero, err
+	}
+	raw, exists := p.Data[key]
+	if !exists {
+		return zero, nil
+	}
+
+	// Decoding every time hands each caller a value of its own
+	value, err := codec.decode(raw)
+	if err != nil {
+		return zero, fmt.Errorf("failed to decode section %s: %w", key, err)
+	}
+	if err := codec.validate(value); err != nil {
+		return zero, fmt.Errorf("invalid section %s: %w", key, err)
+	}
+	return value.(T), nil
+}
+
+// HasSection returns true if the project has data stored under key
+func (p *Project) HasSection(key string) bool {
+	defer p.lock()()
+	if p.resident() != nil {
+		return false
+	}
+	_, exists := p.Data[key]
+	return exists
+}
+
+// SetSection validates and stores a typed value under key as an undoable change
+func (p *Project) SetSection(key string, value interface{}) error {
+	codec, err := lookupSection(key)
+	if err != nil {
+		return err
+	}
+	if reflect.TypeOf(value) != codec.typ {
+		return fmt.Errorf("section %s holds %s, not %T", key, codec.typ, value)
+	}
+	if err := codec.validate(value); err != nil {
+		return fmt.Errorf("invalid section %s: %w", key, err)
+	}
+
+	// The project keeps only the encoded form, so the caller's value stays the caller's
+	raw, err := codec.encode(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode section %s: %w", key, err)
+	}
+
+	defer p.lock()()
+	if err := p.resident(); err != nil {
+		return err
+	}
+	from := p.currentSection(key)
+	to := sectionState{raw: raw, exists: true}
+	if sameSection(from, to) {
+		p.applySection(key, to)
+		return nil
+	}
+
+	p.run(&setSectionCommand{key: key, from: from, to: to})
+	return nil
+}
+
+// RemoveSection deletes the data stored under key as an undoable change
+func (p *Project) RemoveSection(key string) {
+	defer p.lock()()
+	if p.resident() != nil {
+		return
+	}
+	from := p.currentSection(key)
+	if !from.exists {
+		return
+	}
+	p.run(&setSectionCommand{key: key, from: from})
+}
@@ -0,0 +1,125 @@
+package project_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/validation"
+)
+
+type testSection struct {
+	Items []string `json:"items"`
+	Count int      `json:"count"`
+}
+
+const testSectionKey = "test-section"
+
+func init() {
+	project.RegisterSection(project.Section[testSection]{
+		Key: testSectionKey,
+		Validate: func(s testSection) error {
+			if s.Count != len(s.Items) {
+				return validation.ValidationError{Field: "count", Problem: "must match number of items"}
+			}
+			return nil
+		},
+	})
+}
+
+// TestSectionRoundTrip verifies that a typed section survives a save and load unchanged
+func TestSectionRoundTrip(t *testing.T) {
+	p := project.New("section-project")
+	tempPath := filepath.Join(t.TempDir(), "section-project.json")
+	if err := p.SaveAs(tempPath); err != nil {
+		t.Fatalf("Failed to save project: %v", err)
+	}
+
+	value := testSection{Items: []string{"a", "b"}, Count: 2}
+	if err := p.SetSection(testSectionKey, value); err != nil {
+		t.Fatalf("Failed to set section: %v", err)
+	}
+	if !p.IsDirty() {
+		t.Error("Setting a section should mark the project dirty")
+	}
+	if err := p.Save(); err != nil {
+		t.Fatalf("Failed to save project: %v", err)
+	}
+
+	loaded, err := project.Load(tempPath)
+	if err != nil {
+		t.Fatalf("Failed to load project: %v", err)
+	}
+
+	got, err := project.GetSection[testSection](loaded, testSectionKey)
+	if err != nil {
+		t.Fatalf("Failed to get section: %v", err)
+	}
+	if got.Count != 2 || len(got.Items) != 2 || got.Items[0] != "a" || got.Items[1] != "b" {
+		t.Errorf("Section did not round trip, got %+v", got)
+	}
+
+	notes, err := project.GetSection[project.Notes](loaded, project.NotesSection)
+	if err != nil {
+		t.Fatalf("Failed to get missing section: %v", err)
+	}
+	if notes.Text != "" || loaded.HasSection(project.NotesSection) {
+		t.Error("A section that was never set should be empty")
+	}
+}
+
+// TestSectionRejectsBadValues verifies validation, type and registration checks
+func TestSectionRejectsBadValues(t *testing.T) {
+	p := project.New("section-project")
+	p.SetDirty(false)
+
+	var vErr validation.ValidationError
+	if err := p.SetSection(testSectionKey, testSection{Items: []string{"a"}, Count: 3}); !errors.As(err, &vErr) {
+		t.Errorf("Expected a validation error, got: %v", err)
+	}
+
+	if err := p.SetSection(testSectionKey, "not a section"); err == nil {
+		t.Error("Expected an error when setting a section to the wrong type")
+	}
+
+	if err := p.SetSection("never-registered", testSection{}); !errors.Is(err, project.ErrUnknownSection) {
+		t.Errorf("Expected ErrUnknownSection, got: %v", err)
+	}
+
+	if _, err := project.GetSection[project.Notes](p, testSectionKey); err == nil {
+		t.Error("Expected an error when reading a section as the wrong type")
+	}
+
+	if p.IsDirty() {
+		t.Error("Rejected changes should not mark the project dirty")
+	}
+}
+
+// TestSectionValuesAreCopies verifies that changing a value passed to or
+// returned by the project does not change the project or its undo history
+func TestSectionValuesAreCopies(t *testing.T) {
+	p := project.New("copy-project")
+
+	value := testSection{Items: []string{"a"}, Count: 1}
+	if err := p.SetSection(testSectionKey, value); err != nil {
+		t.Fatalf("Failed to set section: %v", err)
+	}
+	value.Items[0] = "changed by the caller"
+
+	got, _ := project.GetSection[testSection](p, testSectionKey)
+	if got.Items[0] != "a" {
+		t.Errorf("Changing the value set should not change the project, got %v", got.Items)
+	}
+	got.Items[0] = "changed by the reader"
+
+	if err := p.SetSection(testSectionKey, testSection{Items: []string{"a", "b"}, Count: 2}); err != nil {
+		t.Fatalf("Failed to set section: %v", err)
+	}
+	if !p.Undo() {
+		t.Fatal("Expected a change to undo")
+	}
+	if again, _ := project.GetSection[testSection](p, testSectionKey); len(again.Items) != 1 || again.Items[0] != "a" {
+		t.Errorf("Expected the original value after undo, got %v", again.Items)
+	}
+}
@@ -0,0 +1,205 @@
+// package project contains the data structures and methods for managing project files
+package project
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+)
+
+// ErrUnknownTemplate is returned when no built-in or user template has the requested ID
+var ErrUnknownTemplate = errors.New("unknown project template")
+
+// templateName is the placeholder replaced by the new project's name in a
+// template's preferences, data and folders
+const templateName = "{name}"
+
+//go:embed templates/*.json
+var builtinTemplates embed.FS
+
+// Template seeds a new project with preferences, data sections and a folder
+// layout. Built-in templates ship with the app; user templates are JSON files
+// in the user's templates folder, named by their ID.
+type Template struct {
+	ID          string                     `json:"id"`
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Preferences map[string]string          `json:"preferences"`
+	Data        map[string]json.RawMessage `json:"data"`
+	Folders     []string                   `json:"folders"` // Slash-separated, relative to the folder root
+	BuiltIn     bool                       `json:"builtIn"`
+}
+
+// ListTemplates returns the built-in templates followed by the user templates
+// found in userDir, each group sorted by name. A user template with the same
+// ID as a built-in one replaces it.
+func ListTemplates(userDir string) ([]Template, error) {
+	builtins, err := readBuiltinTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := readUserTemplates(userDir)
+	if err != nil {
+		return nil, err
+	}
+
+	overridden := make(map[string]bool, len(users))
+	for _, t := range users {
+		overridden[t.ID] = true
+	}
+
+	templates := make([]Template, 0, len(builtins)+len(users))
+	for _, t := range builtins {
+		if !overridden[t.ID] {
+			templates = append(templates, t)
+		}
+	}
+	return append(templates, users...), nil
+}
+
+// FindTemplate returns the template with the given ID from ListTemplates
+func FindTemplate(userDir, id string) (Template, error) {
+	templates, err := ListTemplates(userDir)
+	if err != nil {
+		return Template{}, err
+	}
+	for _, t := range templates {
+		if t.ID == id {
+			return t, nil
+		}
+	}
+	return Template{}, fmt.Errorf("%w: %s", ErrUnknownTemplate, id)
+}
+
+func readBuiltinTemplates() ([]Template, error) {
+	entries, err := builtinTemplates.ReadDir("templates")
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make([]Template, 0, len(entries))
+	for _, entry := range entries {
+		data, err := builtinTemplates.ReadFile(path.Join("templates", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		t, err := parseTemplate(entry.Name(), data)
+		if err != nil {
+			return nil, err
+		}
+		t.BuiltIn = true
+		templates = append(templates, t)
+	}
+	sortTemplates(templates)
+	return templates, nil
+}
+
+func readUserTemplates(dir string) ([]Template, error) {
+	if dir == "" {
+		return []Template{}, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []Template{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	templates := make([]Template, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		t, err := parseTemplate(entry.Name(), data)
+		if err != nil {
+			// One broken user template should not hide the others
+			msgs.EmitWarning("Project templates", err.Error())
+			continue
+		}
+		templates = append(templates, t)
+	}
+	sortTemplates(templates)
+	return templates, nil
+}
+
+func parseTemplate(filename string, data []byte) (Template, error) {
+	var t Template
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Template{}, fmt.Errorf("failed to parse template %s: %w", filename, err)
+	}
+
+	t.ID = strings.TrimSuffix(filename, ".json")
+	if t.Name == "" {
+		t.Name = t.ID
+	}
+	if t.Preferences == nil {
+		t.Preferences = make(map[string]string)
+	}
+	if t.Data == nil {
+		t.Data = make(map[string]json.RawMessage)
+	}
+	if t.Folders == nil {
+		t.Folders = []string{}
+	}
+	return t, nil
+}
+
+func sortTemplates(templates []Template) {
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+}
+
+// newFromTemplate builds a project named name from t, with every data section
+// validated, and creates the template's folders under folderRoot
+func newFromTemplate(name string, t Template, folderRoot string) (*Project, error) {
+	escaped, _ := json.Marshal(name)
+	jsonName := string(escaped[1 : len(escaped)-1])
+
+	project := New(name)
+	for key, value := range t.Preferences {
+		project.Preferences[key] = strings.ReplaceAll(value, templateName, name)
+	}
+
+	for key, raw := range t.Data {
+		codec, err := lookupSection(key)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", t.ID, err)
+		}
+		seeded := json.RawMessage(strings.ReplaceAll(string(raw), templateName, jsonName))
+		value, err := codec.decode(seeded)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: failed to decode section %s: %w", t.ID, key, err)
+		}
+		if err := codec.validate(value); err != nil {
+			return nil, fmt.Errorf("template %s: invalid section %s: %w", t.ID, key, err)
+		}
+		project.Data[key] = seeded
+	}
+
+	for _, folder := range t.Folders {
+		cleaned := path.Clean(strings.ReplaceAll(folder, templateName, name))
+		if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+			return nil, fmt.Errorf("template %s: folder %q is outside the project", t.ID, folder)
+		}
+		if err := os.MkdirAll(filepath.Join(folderRoot, filepath.FromSlash(cleaned)), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create folder: %w", err)
+		}
+	}
+
+	return project, nil
+}
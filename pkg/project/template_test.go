@@ -0,0 +1,112 @@
+package project_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/project"
+)
+
+func writeTemplate(t *testing.T, dir, id, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create templates folder: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, id+".json"), []byte(contents), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+}
+
+func TestListTemplates(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "templates")
+
+	builtins, err := project.ListTemplates(dir)
+	if err != nil {
+		t.Fatalf("Failed to list templates: %v", err)
+	}
+	ids := map[string]bool{}
+	for _, tmpl := range builtins {
+		if !tmpl.BuiltIn {
+			t.Errorf("Template %s should be built in", tmpl.ID)
+		}
+		ids[tmpl.ID] = true
+	}
+	for _, id := range []string{"blank", "notebook", "dalledress"} {
+		if !ids[id] {
+			t.Errorf("Expected built-in template %s", id)
+		}
+	}
+
+	writeTemplate(t, dir, "notebook", `{"name": "My Notebook", "data": {"notes": {"text": "mine"}}}`)
+	writeTemplate(t, dir, "research", `{"name": "Research", "preferences": {"topic": "{name}"}}`)
+	writeTemplate(t, dir, "broken", `{not json`)
+
+	all, err := project.ListTemplates(dir)
+	if err != nil {
+		t.Fatalf("Failed to list templates: %v", err)
+	}
+	if len(all) != len(builtins)+1 {
+		t.Fatalf("Expected the user notebook to replace the built-in one and the broken template to be skipped, got %d templates", len(all))
+	}
+
+	notebook, err := project.FindTemplate(dir, "notebook")
+	if err != nil || notebook.BuiltIn || notebook.Name != "My Notebook" {
+		t.Errorf("Expected the user notebook template, got %+v (%v)", notebook, err)
+	}
+	if _, err := project.FindTemplate(dir, "missing"); !errors.Is(err, project.ErrUnknownTemplate) {
+		t.Errorf("Expected ErrUnknownTemplate, got %v", err)
+	}
+}
+
+func TestNewFromTemplate(t *testing.T) {
+	root := t.TempDir()
+	tmpl := project.Template{
+		ID:          "test",
+		Preferences: map[string]string{"topic": "all about {name}"},
+		Data:        map[string]json.RawMessage{project.NotesSection: json.RawMessage(`{"text": "notes on {name}"}`)},
+		Folders:     []string{"output/{name}/generated", "output/{name}/annotated"},
+	}
+
+	m := project.NewManager()
+	p, err := m.NewFromTemplate(`Quoted "Name"`, tmpl, root)
+	if err != nil {
+		t.Fatalf("Failed to create project from template: %v", err)
+	}
+
+	if m.Active() != p {
+		t.Error("The new project should be active")
+	}
+	if got := p.GetPreference("topic"); got != `all about Quoted "Name"` {
+		t.Errorf("Expected the name to be filled into preferences, got %q", got)
+	}
+	notes, err := project.GetSection[project.Notes](p, project.NotesSection)
+	if err != nil || notes.Text != `notes on Quoted "Name"` {
+		t.Errorf("Expected seeded notes, got %q (%v)", notes.Text, err)
+	}
+	for _, folder := range []string{"generated", "annotated"} {
+		if info, err := os.Stat(filepath.Join(root, "output", `Quoted "Name"`, folder)); err != nil || !info.IsDir() {
+			t.Errorf("Expected folder %s to be created: %v", folder, err)
+		}
+	}
+}
+
+func TestNewFromTemplateRejectsBadTemplates(t *testing.T) {
+	m := project.NewManager()
+
+	unknown := project.Template{ID: "unknown", Data: map[string]json.RawMessage{"nonsense": json.RawMessage(`{}`)}}
+	if _, err := m.NewFromTemplate("Bad", unknown, t.TempDir()); !errors.Is(err, project.ErrUnknownSection) {
+		t.Errorf("Expected ErrUnknownSection, got %v", err)
+	}
+
+	escaping := project.Template{ID: "escaping", Folders: []string{"../outside"}}
+	if _, err := m.NewFromTemplate("Bad", escaping, t.TempDir()); err == nil {
+		t.Error("Expected a folder outside the root to be rejected")
+	}
+
+	if len(m.GetOpenProjectIDs()) != 0 {
+		t.Error("A failed template should not open a project")
+	}
+}
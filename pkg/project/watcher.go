@@ -0,0 +1,110 @@
+// package project contains the data structures and methods for managing project files
+package project
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/TrueBlocks/trueblocks-codegen/pkg/msgs"
+	"github.com/fsnotify/fsnotify"
+)
+
+// fileWatcher tracks the folders holding open project files
+type fileWatcher struct {
+	fs    *fsnotify.Watcher
+	dirs  map[string]bool
+	mutex sync.Mutex
+}
+
+// StartWatching watches the files of all open projects and emits a file
+// changed event naming the project's ID when one is modified by another
+// program. Projects opened or saved later are picked up automatically.
+func (m *Manager) StartWatching() error {
+	m.watcher.mutex.Lock()
+	if m.watcher.fs != nil {
+		m.watcher.mutex.Unlock()
+		return nil
+	}
+
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		m.watcher.mutex.Unlock()
+		return err
+	}
+	m.watcher.fs = fs
+	m.watcher.dirs = make(map[string]bool)
+	m.watcher.mutex.Unlock()
+
+	go m.watchLoop(fs)
+	m.syncWatches()
+	return nil
+}
+
+// StopWatching stops watching project files
+func (m *Manager) StopWatching() {
+	m.watcher.mutex.Lock()
+	defer m.watcher.mutex.Unlock()
+
+	if m.watcher.fs != nil {
+		m.watcher.fs.Close()
+		m.watcher.fs = nil
+		m.watcher.dirs = nil
+	}
+}
+
+// syncWatches adjusts the watched folders to match the open projects. Folders
+// are watched rather than files because saving replaces the file by renaming.
+// It must be called without the manager's lock held.
+func (m *Manager) syncWatches() {
+	projects, _ := m.Snapshot()
+
+	wanted := make(map[string]bool)
+	for _, project := range projects {
+		if path := project.GetPath(); path != "" {
+			wanted[filepath.Dir(path)] = true
+		}
+	}
+
+	m.watcher.mutex.Lock()
+	defer m.watcher.mutex.Unlock()
+
+	if m.watcher.fs == nil {
+		return
+	}
+	for dir := range m.watcher.dirs {
+		if !wanted[dir] {
+			_ = m.watcher.fs.Remove(dir)
+			delete(m.watcher.dirs, dir)
+		}
+	}
+	for dir := range wanted {
+		if !m.watcher.dirs[dir] {
+			if err := m.watcher.fs.Add(dir); err == nil {
+				m.watcher.dirs[dir] = true
+			}
+		}
+	}
+}
+
+func (m *Manager) watchLoop(fs *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-fs.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			project := m.GetProjectByPath(filepath.Clean(event.Name))
+			if project != nil && project.ModifiedOnDisk() {
+				msgs.EmitFileChanged(project.GetID())
+			}
+		case err, ok := <-fs.Errors:
+			if !ok {
+				return
+			}
+			msgs.EmitError("Watching project files failed", err)
+		}
+	}
+}
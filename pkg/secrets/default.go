@@ -0,0 +1,79 @@
+// package secrets keeps API keys and credentials in an encrypted file and
+// keeps them out of logs and events
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	defaultStore *Store
+	defaultMutex sync.RWMutex
+)
+
+// SetDefault makes store the keystore that Lookup reads and whose secrets
+// Redact hides. Passing nil clears it.
+func SetDefault(store *Store) {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	defaultStore = store
+}
+
+// OpenDefault opens the keystore in folder and makes it the default. A
+// passphrase protected keystore is unlocked with the passphrase in the
+// environment variable passphraseVar if it is set, and otherwise stays locked.
+func OpenDefault(folder, passphraseVar string) (*Store, error) {
+	store, err := Open(filepath.Join(folder, FileName))
+	if err != nil {
+		return nil, err
+	}
+	if passphrase := os.Getenv(passphraseVar); passphrase != "" && store.Locked() {
+		if err := store.Unlock(passphrase); err != nil {
+			return nil, err
+		}
+	}
+	SetDefault(store)
+	return store, nil
+}
+
+// Default returns the keystore set with SetDefault, or nil
+func Default() *Store {
+	defaultMutex.RLock()
+	defer defaultMutex.RUnlock()
+	return defaultStore
+}
+
+// Lookup returns the secret called name from the default keystore. It
+// reports false if there is no default keystore, it is locked, or it holds no
+// such secret.
+func Lookup(name string) (string, bool) {
+	store := Default()
+	if store == nil {
+		return "", false
+	}
+	value, err := store.Get(name)
+	return value, err == nil && value != ""
+}
+
+// ImportEnv copies the environment variables of the given names into store
+// unless it already holds them, so keys kept in a .env file move into the
+// keystore. It returns the names imported.
+func ImportEnv(store *Store, names ...string) ([]string, error) {
+	var imported []string
+	for _, name := range names {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		if _, err := store.Get(name); err == nil {
+			continue
+		}
+		if err := store.Set(name, value); err != nil {
+			return imported, err
+		}
+		imported = append(imported, name)
+	}
+	return imported, nil
+}
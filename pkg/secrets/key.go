@@ -0,0 +1,62 @@
+// package secrets keeps API keys and credentials in an encrypted file and
+// keeps them out of logs and events
+package secrets
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"os"
+	"os/user"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// keyIterations is the PBKDF2 work factor for both kinds of key
+const keyIterations = 200_000
+
+func newSalt() ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// passphraseKey derives a 256 bit key from a passphrase
+func passphraseKey(passphrase string, salt []byte) []byte {
+	return deriveKey([]byte(passphrase), salt, keyIterations)
+}
+
+// machineKey derives a 256 bit key from the identity of the machine and the
+// user. It keeps a copied keystore from opening elsewhere, but anything
+// running as the same user on the same machine can derive it too.
+func machineKey(salt []byte) []byte {
+	parts := []string{machineID()}
+	if u, err := user.Current(); err == nil {
+		parts = append(parts, u.Uid, u.Username)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		parts = append(parts, home)
+	}
+	return deriveKey([]byte(strings.Join(parts, "\x00")), salt, keyIterations)
+}
+
+// machineID returns an identifier that stays the same for the life of the
+// machine's installation, falling back to the host name where there is none
+func machineID() string {
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if data, err := os.ReadFile(path); err == nil {
+			if id := strings.TrimSpace(string(data)); id != "" {
+				return id
+			}
+		}
+	}
+	host, _ := os.Hostname()
+	return host
+}
+
+// deriveKey is PBKDF2 with HMAC-SHA256 (RFC 8018) producing a 32 byte key
+func deriveKey(password, salt []byte, iterations int) []byte {
+	return pbkdf2.Key(password, salt, iterations, 32, sha256.New)
+}
@@ -0,0 +1,28 @@
+package secrets
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// Known answers for PBKDF2-HMAC-SHA256, truncated to the 32 byte key we use.
+// A change here would leave every existing keystore unreadable.
+func TestDeriveKeyVectors(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iterations     int
+		expected       string
+	}{
+		{"password", "salt", 1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 2, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"password", "salt", 4096, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(deriveKey([]byte(tt.password), []byte(tt.salt), tt.iterations))
+		if got != tt.expected {
+			t.Errorf("deriveKey(%q, %q, %d) = %s, expected %s", tt.password, tt.salt, tt.iterations, got, tt.expected)
+		}
+	}
+}
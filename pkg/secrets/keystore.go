@@ -0,0 +1,328 @@
+// package secrets keeps API keys and credentials in an encrypted file and
+// keeps them out of logs and events
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FileName is the name of the keystore file in the app's config folder
+const FileName = "keystore.json"
+
+const keystoreVersion = "1.0"
+
+var (
+	// ErrLocked is returned when a passphrase protected keystore has not been unlocked
+	ErrLocked = errors.New("keystore is locked")
+	// ErrWrongPassphrase is returned when the keystore cannot be decrypted with the given key
+	ErrWrongPassphrase = errors.New("wrong passphrase for keystore")
+	// ErrNotFound is returned when the keystore holds no secret of the given name
+	ErrNotFound = errors.New("secret not found")
+)
+
+// Mode says how the key that encrypts a keystore is made
+type Mode string
+
+const (
+	// ModeMachine keystores are encrypted with a key derived from the machine
+	// and user. They open without asking, but only on the machine that wrote them.
+	ModeMachine Mode = "machine"
+	// ModePassphrase keystores are encrypted with a key derived from a
+	// passphrase and stay locked until Unlock is called with it
+	ModePassphrase Mode = "passphrase"
+)
+
+// keystoreFile is the format of the keystore on disk. Neither the secrets nor
+// their names are stored in the clear.
+type keystoreFile struct {
+	Version string `json:"version"`
+	Mode    Mode   `json:"mode"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// Store is an encrypted keystore file. Every change is written right away.
+type Store struct {
+	path    string
+	mode    Mode
+	salt    []byte
+	key     []byte // Nil while locked
+	secrets map[string]string
+	sealed  keystoreFile // The file as read, kept until the store is unlocked
+	mutex   sync.Mutex
+}
+
+// Open reads the keystore at path. A missing file gives an empty, machine
+// keyed store that is created on the first change. A machine keyed store is
+// unlocked right away; a passphrase protected one stays locked until Unlock.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, mode: ModeMachine, secrets: make(map[string]string)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		salt, err := newSalt()
+		if err != nil {
+			return nil, err
+		}
+		s.salt = salt
+		s.key = machineKey(salt)
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.sealed); err != nil {
+		return nil, fmt.Errorf("failed to parse keystore %s: %w", path, err)
+	}
+	if s.sealed.Version != keystoreVersion {
+		return nil, fmt.Errorf("unsupported keystore version %q", s.sealed.Version)
+	}
+	s.mode = s.sealed.Mode
+	s.salt = s.sealed.Salt
+
+	switch s.mode {
+	case ModeMachine:
+		if err := s.open(machineKey(s.salt)); errors.Is(err, ErrWrongPassphrase) {
+			return nil, fmt.Errorf("%w: the keystore was written on another machine or by another user", err)
+		} else if err != nil {
+			return nil, err
+		}
+	case ModePassphrase:
+	default:
+		return nil, fmt.Errorf("unknown keystore mode %q", s.mode)
+	}
+	return s, nil
+}
+
+// Mode returns how the keystore's key is made
+func (s *Store) Mode() Mode {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.mode
+}
+
+// Locked reports whether the keystore is waiting for its passphrase
+func (s *Store) Locked() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.key == nil
+}
+
+// Unlock decrypts a passphrase protected keystore. Unlocking a store that is
+// already unlocked checks nothing and succeeds.
+func (s *Store) Unlock(passphrase string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.key != nil {
+		return nil
+	}
+	return s.open(passphraseKey(passphrase, s.salt))
+}
+
+// SetPassphrase encrypts the keystore with a new passphrase, or with the
+// machine key if passphrase is empty
+func (s *Store) SetPassphrase(passphrase string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.key == nil {
+		return ErrLocked
+	}
+
+	salt, err := newSalt()
+	if err != nil {
+		return err
+	}
+	mode, key := ModeMachine, machineKey(salt)
+	if passphrase != "" {
+		mode, key = ModePassphrase, passphraseKey(passphrase, salt)
+	}
+
+	oldMode, oldSalt, oldKey := s.mode, s.salt, s.key
+	s.mode, s.salt, s.key = mode, salt, key
+	if err := s.save(); err != nil {
+		s.mode, s.salt, s.key = oldMode, oldSalt, oldKey
+		return err
+	}
+	return nil
+}
+
+// Names returns the names of the secrets, sorted
+func (s *Store) Names() ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.key == nil {
+		return nil, ErrLocked
+	}
+
+	names := make([]string, 0, len(s.secrets))
+	for name := range s.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Get returns the secret called name
+func (s *Store) Get(name string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.key == nil {
+		return "", ErrLocked
+	}
+
+	value, ok := s.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return value, nil
+}
+
+// Set adds or replaces the secret called name and saves the keystore
+func (s *Store) Set(name, value string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("secret name cannot be empty")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.key == nil {
+		return ErrLocked
+	}
+
+	previous, existed := s.secrets[name]
+	s.secrets[name] = value
+	if err := s.save(); err != nil {
+		if existed {
+			s.secrets[name] = previous
+		} else {
+			delete(s.secrets, name)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes the secret called name and saves the keystore
+func (s *Store) Delete(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.key == nil {
+		return ErrLocked
+	}
+
+	previous, ok := s.secrets[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	delete(s.secrets, name)
+	if err := s.save(); err != nil {
+		s.secrets[name] = previous
+		return err
+	}
+	return nil
+}
+
+// values returns the secrets' values, for redaction. A locked store has none
+// in memory to leak.
+func (s *Store) values() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	values := make([]string, 0, len(s.secrets))
+	for _, value := range s.secrets {
+		values = append(values, value)
+	}
+	return values
+}
+
+// open decrypts the sealed file with key. The caller must hold the lock.
+func (s *Store) open(key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	plain, err := gcm.Open(nil, s.sealed.Nonce, s.sealed.Data, []byte(s.sealed.Mode))
+	if err != nil {
+		return ErrWrongPassphrase
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return fmt.Errorf("failed to parse keystore contents: %w", err)
+	}
+	s.secrets = secrets
+	s.key = key
+	s.sealed = keystoreFile{}
+	return nil
+}
+
+// save encrypts the secrets and replaces the keystore file through a
+// temporary file. The caller must hold the lock.
+func (s *Store) save() error {
+	plain, err := json.Marshal(s.secrets)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(s.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	file := keystoreFile{
+		Version: keystoreVersion,
+		Mode:    s.mode,
+		Salt:    s.salt,
+		Nonce:   nonce,
+		Data:    gcm.Seal(nil, nonce, plain, []byte(s.mode)),
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(s.path), "keystore-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	// Only the user may read the keystore
+	if err := os.Chmod(temp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), s.path)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
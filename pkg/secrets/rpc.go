@@ -0,0 +1,168 @@
+// package secrets keeps API keys and credentials in an encrypted file and
+// keeps them out of logs and events
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ErrNoKeystore is returned when credentials must go into the default
+// keystore but none has been opened
+var ErrNoKeystore = errors.New("no keystore is open")
+
+// rpcSecretPrefix starts the name of every secret taken out of an RPC URL
+const rpcSecretPrefix = "RPC_"
+
+// rpcReference matches the reference StashURL leaves in a URL in place of a
+// credential. "$" needs no escaping in the user info, path or query.
+var rpcReference = regexp.MustCompile(`\$(` + rpcSecretPrefix + `[0-9a-f]{16})`)
+
+// pathKey matches a path segment that looks like an API key, such as the
+// project ID at the end of an Infura or Alchemy URL
+var pathKey = regexp.MustCompile(`^[A-Za-z0-9_-]{20,}$`)
+
+// HasURLCredentials reports whether rawURL holds a user name and password,
+// an API key or a token that StashURL would move into a keystore
+func HasURLCredentials(rawURL string) bool {
+	found := false
+	_, _ = rewriteURL(rawURL, func(string) (string, error) {
+		found = true
+		return "", nil
+	})
+	return found
+}
+
+// StashURL moves the user name and password, API keys and tokens in rawURL
+// into store and returns the URL with a reference to each in its place. A
+// credential already in store is referenced rather than saved twice.
+func StashURL(store *Store, rawURL string) (string, error) {
+	if store == nil {
+		if HasURLCredentials(rawURL) {
+			return "", ErrNoKeystore
+		}
+		return rawURL, nil
+	}
+	return rewriteURL(rawURL, func(secret string) (string, error) {
+		name, err := store.stash(rpcSecretPrefix, secret)
+		if err != nil {
+			return "", err
+		}
+		return "$" + name, nil
+	})
+}
+
+// ResolveURL puts the credentials StashURL moved out of rawURL back from the
+// default keystore. References it cannot resolve, because the keystore is
+// locked or lacks them, are left in place so the URL fails rather than
+// reaching the server without its credentials.
+func ResolveURL(rawURL string) string {
+	return rpcReference.ReplaceAllStringFunc(rawURL, func(reference string) string {
+		if value, ok := Lookup(reference[1:]); ok {
+			return value
+		}
+		return reference
+	})
+}
+
+// rewriteURL calls replace with each credential in rawURL, still escaped,
+// and returns the URL with the credentials replaced by what it returns. URLs
+// that do not parse are returned unchanged for validation to report.
+func rewriteURL(rawURL string, replace func(secret string) (string, error)) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL, nil
+	}
+
+	changed := false
+	swap := func(secret string) (string, error) {
+		if secret == "" || rpcReference.MatchString(secret) {
+			return secret, nil
+		}
+		changed = true
+		return replace(secret)
+	}
+
+	if u.User != nil {
+		reference, err := swap(u.User.String())
+		if err != nil {
+			return "", err
+		}
+		u.User = url.User(reference)
+	}
+
+	segments := strings.Split(u.EscapedPath(), "/")
+	for i, segment := range segments {
+		if pathKey.MatchString(segment) && strings.ContainsAny(segment, "0123456789") {
+			if segments[i], err = swap(segment); err != nil {
+				return "", err
+			}
+		}
+	}
+	if escaped := strings.Join(segments, "/"); escaped != u.EscapedPath() {
+		if u.Path, err = url.PathUnescape(escaped); err != nil {
+			return "", err
+		}
+		u.RawPath = escaped
+	}
+
+	params := strings.Split(u.RawQuery, "&")
+	for i, param := range params {
+		key, value, found := strings.Cut(param, "=")
+		if found && isCredentialParam(key) {
+			if value, err = swap(value); err != nil {
+				return "", err
+			}
+			params[i] = key + "=" + value
+		}
+	}
+	u.RawQuery = strings.Join(params, "&")
+
+	if !changed {
+		return rawURL, nil
+	}
+	return u.String(), nil
+}
+
+// isCredentialParam reports whether a query parameter carries a credential
+func isCredentialParam(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range []string{"key", "token", "secret", "password", "auth"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// stash saves value under a new name starting with prefix, or returns the
+// name it is already saved under
+func (s *Store) stash(prefix, value string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.key == nil {
+		return "", ErrLocked
+	}
+
+	for name, existing := range s.secrets {
+		if strings.HasPrefix(name, prefix) && existing == value {
+			return name, nil
+		}
+	}
+
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	name := prefix + hex.EncodeToString(random)
+	s.secrets[name] = value
+	if err := s.save(); err != nil {
+		delete(s.secrets, name)
+		return "", err
+	}
+	return name, nil
+}
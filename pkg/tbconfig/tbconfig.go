@@ -0,0 +1,338 @@
+// package tbconfig reads the chains in the TrueBlocks config file and writes
+// RPC providers back to it, leaving the rest of the file as it was
+package tbconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FileName is the name of the TrueBlocks config file in chifra's root config folder
+const FileName = "trueBlocks.toml"
+
+// ErrMalformed is returned for a chain section the file cannot be edited in
+var ErrMalformed = errors.New("malformed TrueBlocks config")
+
+// Chain is a chain section of the TrueBlocks config file
+type Chain struct {
+	Chain          string   `json:"chain"`
+	ChainId        uint64   `json:"chainId"`
+	RemoteExplorer string   `json:"remoteExplorer"`
+	RpcProviders   []string `json:"rpcProviders"`
+	Symbol         string   `json:"symbol"`
+}
+
+// Path returns the config file in chifra's root config folder
+func Path(rootConfig string) string {
+	return filepath.Join(rootConfig, FileName)
+}
+
+var (
+	headerRe  = regexp.MustCompile(`^\s*\[\s*([^\]]+?)\s*\]\s*(#.*)?$`)
+	keyRe     = regexp.MustCompile(`^(\s*)([A-Za-z0-9_-]+)\s*=\s*(.*)$`)
+	bareKeyRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// section is a [chains.<name>] table, by line number
+type section struct {
+	name  string
+	start int // The header line
+	end   int // The line after the last line of the table
+}
+
+// ReadChains returns the chains in the config file at path in the order they appear
+func ReadChains(path string) ([]Chain, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := splitLines(data)
+
+	var chains []Chain
+	for _, s := range sections(lines) {
+		chain := Chain{Chain: s.name}
+		for i := s.start + 1; i < s.end; i++ {
+			m := keyRe.FindStringSubmatch(lines[i])
+			if m == nil {
+				continue
+			}
+			value, last := joinValue(lines, i, s.end)
+			i = last
+			switch m[2] {
+			case "chain":
+				chain.Chain = parseString(value)
+			case "chainId":
+				id, err := strconv.ParseUint(parseString(value), 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("%w: chain %s has chainId %s", ErrMalformed, s.name, value)
+				}
+				chain.ChainId = id
+			case "remoteExplorer":
+				chain.RemoteExplorer = parseString(value)
+			case "symbol":
+				chain.Symbol = parseString(value)
+			case "rpcProvider":
+				if rpc := parseString(value); rpc != "" {
+					chain.RpcProviders = []string{rpc}
+				}
+			case "rpcProviders":
+				chain.RpcProviders = parseArray(value)
+			}
+		}
+		chains = append(chains, chain)
+	}
+	return chains, nil
+}
+
+// SetRpcProviders writes the chain's RPC providers to the config file at
+// path. An existing chain keeps its other settings and its layout, so a
+// chain with an rpcProviders array gets them all. Otherwise chifra reads a
+// single rpcProvider, which gets the first provider, as does a chain the
+// file does not have, which is added with all of its settings. The previous
+// file is kept next to it with a .bak extension.
+func SetRpcProviders(path string, chain Chain) error {
+	if chain.Chain == "" || len(chain.RpcProviders) == 0 {
+		return fmt.Errorf("%w: chain %q has no name or RPC providers", ErrMalformed, chain.Chain)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	lines := splitLines(data)
+
+	var found *section
+	for _, s := range sections(lines) {
+		if s.name == chain.Chain {
+			found = &s
+			break
+		}
+	}
+
+	if found == nil {
+		lines = append(lines, newSection(chain)...)
+	} else {
+		lines = setRpcLines(lines, *found, chain.RpcProviders)
+	}
+
+	if err := os.WriteFile(path+".bak", data, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return writeFile(path, []byte(strings.Join(lines, "\n")), info.Mode().Perm())
+}
+
+// setRpcLines replaces the RPC setting of the chain in s, or adds one after
+// its header if it has none
+func setRpcLines(lines []string, s section, rpcs []string) []string {
+	indent := "  "
+	for i := s.start + 1; i < s.end; i++ {
+		m := keyRe.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		indent = m[1]
+		if m[2] != "rpcProvider" && m[2] != "rpcProviders" {
+			continue
+		}
+		_, last := joinValue(lines, i, s.end)
+		line := m[1] + "rpcProvider = " + quote(rpcs[0])
+		if m[2] == "rpcProviders" {
+			line = m[1] + "rpcProviders = " + formatArray(rpcs)
+		}
+		return append(lines[:i], append([]string{line}, lines[last+1:]...)...)
+	}
+
+	line := indent + "rpcProvider = " + quote(rpcs[0])
+	return append(lines[:s.start+1], append([]string{line}, lines[s.start+1:]...)...)
+}
+
+// newSection returns the lines of a chain table for a chain the file does not have
+func newSection(chain Chain) []string {
+	lines := []string{
+		"",
+		"[chains." + tableKey(chain.Chain) + "]",
+		"  chain = " + quote(chain.Chain),
+		"  chainId = " + quote(strconv.FormatUint(chain.ChainId, 10)),
+	}
+	if chain.RemoteExplorer != "" {
+		lines = append(lines, "  remoteExplorer = "+quote(chain.RemoteExplorer))
+	}
+	lines = append(lines, "  rpcProvider = "+quote(chain.RpcProviders[0]))
+	if chain.Symbol != "" {
+		lines = append(lines, "  symbol = "+quote(chain.Symbol))
+	}
+	return lines
+}
+
+// tableKey returns name as a TOML key, quoted unless it is a bare key
+func tableKey(name string) string {
+	if bareKeyRe.MatchString(name) {
+		return name
+	}
+	return quote(name)
+}
+
+// chainName returns the chain a table header such as chains.mainnet or
+// chains."my chain" names. Sub-tables such as chains.mainnet.scrape are not chains.
+func chainName(header string) (string, bool) {
+	rest, ok := strings.CutPrefix(header, "chains")
+	if !ok {
+		return "", false
+	}
+	rest = strings.TrimSpace(rest)
+	if rest, ok = strings.CutPrefix(rest, "."); !ok {
+		return "", false
+	}
+	rest = strings.TrimSpace(rest)
+
+	switch {
+	case strings.HasPrefix(rest, `"`):
+		for i := 1; i < len(rest); i++ {
+			if rest[i] == '\\' {
+				i++
+			} else if rest[i] == '"' {
+				name, err := strconv.Unquote(rest[:i+1])
+				return name, err == nil && strings.TrimSpace(rest[i+1:]) == ""
+			}
+		}
+		return "", false
+	case strings.HasPrefix(rest, "'"):
+		name, after, found := strings.Cut(rest[1:], "'")
+		return name, found && strings.TrimSpace(after) == ""
+	default:
+		return rest, bareKeyRe.MatchString(rest)
+	}
+}
+
+// sections finds the [chains.<name>] tables. A table ends at the next header,
+// including a sub-table such as [chains.mainnet.scrape].
+func sections(lines []string) []section {
+	var found []section
+	current := -1
+	for i, line := range lines {
+		m := headerRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if current >= 0 {
+			found[current].end = i
+			current = -1
+		}
+		if name, ok := chainName(m[1]); ok {
+			found = append(found, section{name: name, start: i, end: len(lines)})
+			current = len(found) - 1
+		}
+	}
+	return found
+}
+
+// joinValue returns the value of the key on line i, which for an array may
+// continue on later lines, and the last line it uses
+func joinValue(lines []string, i, end int) (string, int) {
+	value := stripComment(keyRe.FindStringSubmatch(lines[i])[3])
+	if !strings.HasPrefix(value, "[") {
+		return value, i
+	}
+	last := i
+	for !strings.HasSuffix(value, "]") && last+1 < end {
+		last++
+		value += " " + stripComment(lines[last])
+	}
+	return value, last
+}
+
+// stripComment removes a trailing comment outside of quotes
+func stripComment(s string) string {
+	inQuote := rune(0)
+	for i, r := range s {
+		switch {
+		case inQuote != 0 && r == inQuote:
+			inQuote = 0
+		case inQuote == 0 && (r == '"' || r == '\''):
+			inQuote = r
+		case inQuote == 0 && r == '#':
+			return strings.TrimSpace(s[:i])
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+func parseString(value string) string {
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+	return strings.Trim(value, `'`)
+}
+
+func parseArray(value string) []string {
+	inner := strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	var items []string
+	for _, item := range strings.Split(inner, ",") {
+		if item = parseString(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func formatArray(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = quote(item)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// quote returns s as a TOML basic string
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func splitLines(data []byte) []string {
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// writeFile replaces the file at path through a temporary file so chifra
+// never reads half of it
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "trueBlocks-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,174 @@
+package tbconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sample = `[version]
+  current = "v5.0.0"
+
+[chains]
+
+[chains.mainnet]
+  chain = "mainnet"
+  chainId = "1"
+  remoteExplorer = "https://etherscan.io"
+  rpcProviders = [
+    "http://localhost:8545", # the archive node
+  ]
+  symbol = "ETH"
+
+[chains.mainnet.scrape]
+  appsPerChunk = 2000000
+
+[chains.sepolia]
+  chain = "sepolia"
+  chainId = 11155111 # written by hand
+  rpcProvider = "https://sepolia.example.com"
+  symbol = "ETH"
+`
+
+func writeSample(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte(sample), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadChains(t *testing.T) {
+	chains, err := ReadChains(writeSample(t))
+	if err != nil {
+		t.Fatalf("ReadChains failed: %v", err)
+	}
+	expected := []Chain{
+		{Chain: "mainnet", ChainId: 1, RemoteExplorer: "https://etherscan.io", RpcProviders: []string{"http://localhost:8545"}, Symbol: "ETH"},
+		{Chain: "sepolia", ChainId: 11155111, RpcProviders: []string{"https://sepolia.example.com"}, Symbol: "ETH"},
+	}
+	if !reflect.DeepEqual(chains, expected) {
+		t.Errorf("Unexpected chains\n got: %+v\nwant: %+v", chains, expected)
+	}
+}
+
+func TestSetRpcProviders(t *testing.T) {
+	path := writeSample(t)
+
+	rpcs := []string{"https://rpc.example.com", "http://localhost:8545"}
+	if err := SetRpcProviders(path, Chain{Chain: "mainnet", ChainId: 1, RpcProviders: rpcs}); err != nil {
+		t.Fatalf("SetRpcProviders failed: %v", err)
+	}
+	if err := SetRpcProviders(path, Chain{Chain: "sepolia", ChainId: 11155111, RpcProviders: rpcs}); err != nil {
+		t.Fatalf("SetRpcProviders failed: %v", err)
+	}
+	gnosis := Chain{Chain: "gnosis", ChainId: 100, RpcProviders: []string{"https://gnosis.example.com"}, Symbol: "XDAI"}
+	if err := SetRpcProviders(path, gnosis); err != nil {
+		t.Fatalf("SetRpcProviders failed: %v", err)
+	}
+
+	chains, err := ReadChains(path)
+	if err != nil || len(chains) != 3 {
+		t.Fatalf("Expected three chains, got %+v and %v", chains, err)
+	}
+	if !reflect.DeepEqual(chains[0].RpcProviders, rpcs) || chains[0].RemoteExplorer != "https://etherscan.io" {
+		t.Errorf("Expected mainnet's providers replaced and the rest kept, got %+v", chains[0])
+	}
+	// A file with a single provider per chain keeps that layout
+	if !reflect.DeepEqual(chains[1].RpcProviders, rpcs[:1]) {
+		t.Errorf("Expected sepolia's first provider only, got %v", chains[1].RpcProviders)
+	}
+	if !reflect.DeepEqual(chains[2], gnosis) {
+		t.Errorf("Expected gnosis to be added, got %+v", chains[2])
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "appsPerChunk = 2000000") || !strings.Contains(string(data), `current = "v5.0.0"`) {
+		t.Errorf("Expected the other settings to be kept:\n%s", data)
+	}
+	if backup, _ := os.ReadFile(path + ".bak"); len(backup) == 0 {
+		t.Error("Expected a backup of the previous file")
+	}
+
+	if err := SetRpcProviders(path, Chain{Chain: "mainnet"}); err == nil {
+		t.Error("Expected an error for a chain without providers")
+	}
+}
+
+// The fixture is laid out as chifra v5 writes its config, with a single
+// rpcProvider per chain, which is the only form that version reads
+func TestSetRpcProvidersChifraConfig(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rpcs := []string{"https://rpc.example.com", "http://localhost:8545"}
+	if err := SetRpcProviders(path, Chain{Chain: "mainnet", ChainId: 1, RpcProviders: rpcs}); err != nil {
+		t.Fatalf("SetRpcProviders failed: %v", err)
+	}
+	odd := Chain{Chain: `my "test" chain.v2`, ChainId: 424242, RpcProviders: rpcs, Symbol: "TST"}
+	if err := SetRpcProviders(path, odd); err != nil {
+		t.Fatalf("SetRpcProviders failed: %v", err)
+	}
+
+	written, _ := os.ReadFile(path)
+	if strings.Contains(string(written), "rpcProviders") {
+		t.Errorf("Expected only the rpcProvider key chifra reads:\n%s", written)
+	}
+	if !strings.Contains(string(written), `[chains."my \"test\" chain.v2"]`) {
+		t.Errorf("Expected the odd chain name to be quoted:\n%s", written)
+	}
+	if !strings.Contains(string(written), "[chains.mainnet.scrape]") || !strings.Contains(string(written), "snapToGrid = 250000") {
+		t.Errorf("Expected the scrape settings to be kept:\n%s", written)
+	}
+
+	chains, err := ReadChains(path)
+	if err != nil || len(chains) != 2 {
+		t.Fatalf("Expected two chains, got %+v and %v", chains, err)
+	}
+	if !reflect.DeepEqual(chains[0].RpcProviders, rpcs[:1]) || chains[0].RemoteExplorer != "https://etherscan.io" {
+		t.Errorf("Expected mainnet's first provider, got %+v", chains[0])
+	}
+	odd.RpcProviders = rpcs[:1]
+	if !reflect.DeepEqual(chains[1], odd) {
+		t.Errorf("Expected the odd chain to read back, got %+v", chains[1])
+	}
+
+	// Saving it again finds the quoted table instead of adding another
+	if err := SetRpcProviders(path, Chain{Chain: odd.Chain, RpcProviders: []string{"https://other.example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	if chains, _ = ReadChains(path); len(chains) != 2 || chains[1].RpcProviders[0] != "https://other.example.com" {
+		t.Errorf("Expected the quoted table to be updated, got %+v", chains)
+	}
+}
+
+func TestChainName(t *testing.T) {
+	tests := []struct {
+		header string
+		name   string
+		ok     bool
+	}{
+		{"chains.mainnet", "mainnet", true},
+		{`chains."my chain.v2"`, "my chain.v2", true},
+		{`chains . 'literal'`, "literal", true},
+		{"chains.mainnet.scrape", "", false},
+		{`chains."my chain".scrape`, "", false},
+		{"chains", "", false},
+		{"settings", "", false},
+	}
+	for _, tt := range tests {
+		name, ok := chainName(tt.header)
+		if ok != tt.ok || (ok && name != tt.name) {
+			t.Errorf("chainName(%s) = %q, %v, expected %q, %v", tt.header, name, ok, tt.name, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+func ValidChoice(field, input string, choices ...string) error {
+	for _, choice := range choices {
+		if input == choice {
+			return nil
+		}
+	}
+	return ValidationError{field, fmt.Sprintf("must be one of %s", strings.Join(choices, ", "))}
+}
@@ -0,0 +1,21 @@
+package validation
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// ValidFileName returns a ValidationError for field unless input names a
+// single file or folder, so joining it to a folder cannot leave that folder
+func ValidFileName(field, input string) error {
+	if strings.TrimSpace(input) == "" {
+		return ValidationError{field, "cannot be empty"}
+	}
+	if strings.ContainsAny(input, `/\`) || strings.Contains(input, "..") {
+		return ValidationError{field, "cannot contain a path separator or .."}
+	}
+	if filepath.Clean(input) != input || filepath.Base(input) != input {
+		return ValidationError{field, "must be a plain file name"}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package validation
+
+import (
+	"net/url"
+	"strings"
+)
+
+func ValidURL(field, input string) error {
+	str := strings.TrimSpace(input)
+	if str == "" {
+		return ValidationError{field, "cannot be empty"}
+	}
+
+	u, err := url.Parse(str)
+	if err != nil {
+		return ValidationError{field, "not a valid URL"}
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ValidationError{field, "must be an http or https URL"}
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package validation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CompareVersions compares two "major.minor" file format versions, returning
+// -1, 0 or 1. A missing minor number counts as zero.
+func CompareVersions(a, b string) (int, error) {
+	pa, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range pa {
+		if pa[i] < pb[i] {
+			return -1, nil
+		} else if pa[i] > pb[i] {
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func parseVersion(v string) ([2]int, error) {
+	var ret [2]int
+	parts := strings.Split(v, ".")
+	if len(parts) > 2 {
+		return ret, ValidationError{"version", fmt.Sprintf("%q is not a major.minor version", v)}
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return ret, ValidationError{"version", fmt.Sprintf("%q is not a major.minor version", v)}
+		}
+		ret[i] = n
+	}
+	return ret, nil
+}